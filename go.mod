@@ -8,6 +8,7 @@ require (
 	github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue v1.13.0
 	github.com/aws/aws-sdk-go-v2/service/dynamodb v1.30.0
 	github.com/awslabs/aws-lambda-go-api-proxy v0.16.1
+	golang.org/x/crypto v0.31.0
 )
 
 require (
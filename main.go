@@ -2,19 +2,34 @@ package main
 
 import (
 	"context"
+	"crypto/hmac"
 	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
 	"embed"
 	"encoding/base32"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"html/template"
 	"log"
+	"math"
 	mathrand "math/rand"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
+	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
+	_ "time/tzdata"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/aws/aws-lambda-go/lambda"
 	"github.com/aws/aws-sdk-go-v2/config"
@@ -22,20 +37,84 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 	"github.com/awslabs/aws-lambda-go-api-proxy/httpadapter"
+	"golang.org/x/crypto/bcrypt"
 )
 
 const (
-	defaultTableName = "bff-hang"
+	defaultTableName       = "bff-hang"
+	defaultDataPath        = "bff-hang.json"
+	maxWriteAttempts       = 3
+	maxTransactionAttempts = 5
+	// DynamoDB caps TransactWriteItems at 100 actions.
+	maxTransactionItems = 100
+	// Stats counters are spread over this many items so writes to unrelated polls rarely collide.
+	statsShards           = 10
+	statsDays             = 30
+	statsWeeks            = 12
+	adminCookieName       = "bffhang_admin"
+	adminSessionTTL       = 12 * time.Hour
+	adminLoginWindow      = 15 * time.Minute
+	maxAdminLoginFailures = 5
+	csrfCookieName        = "bffhang_csrf"
+	csrfFormField         = "csrf_token"
+	csrfHeader            = "X-CSRF-Token"
+	timeZoneCookieName    = "bffhang_tz"
+	maxRateLimitBuckets   = 10000
+	sweepInterval         = time.Hour
+)
+
+const (
+	maxTitleLength            = 120
+	maxNameLength             = 60
+	maxVenueTitleLength       = 100
+	maxVenueURLLength         = 2000
+	maxVenueDescriptionLength = 300
+	maxPollDays               = 90
+	maxPollVenues             = 25
+	maxPollResponses          = 200
+	maxPollSlots              = 6
+	maxSlotNameLength         = 40
+	maxTimeZoneLength         = 64
+	maxDaysAhead              = 366
+	// The calendar picker shows at least this many months, starting with the current one.
+	calendarMonthsShown = 3
+	// Results list this many recommended days.
+	maxRecommendations = 3
+	// Trips span from 2 to this many consecutive days; results list the best windows.
+	maxTripLength = 14
+	maxWindows    = 5
+)
+
+// Day preferences as posted by the response form.
+const (
+	preferenceAvailable   = "available"
+	preferenceIfNeedBe    = "if-need-be"
+	preferenceUnavailable = "unavailable"
+)
+
+const (
+	availableWeight = 2
+	ifNeedBeWeight  = 1
 )
 
 type Storage interface {
 	CreatePoll(ctx context.Context, poll Poll) error
 	GetPoll(ctx context.Context, pollID string) (Poll, []Response, error)
 	AddResponse(ctx context.Context, pollID string, response Response) error
-	UpdatePollDays(ctx context.Context, pollID string, days []string) error
-	UpdatePollVenues(ctx context.Context, pollID string, venues []Venue) error
+	UpdatePollDays(ctx context.Context, pollID string, version int, days []string, slots []TimeSlot, expiresAt time.Time, responses []Response) error
+	UpdatePollVenues(ctx context.Context, pollID string, version int, venues []Venue, responses []Response) error
+	UpdatePollSettings(ctx context.Context, pollID string, version int, settings PollSettings) error
+	ArchivePoll(ctx context.Context, pollID string, version int, archivedAt time.Time) error
+	UpdatePollCredentials(ctx context.Context, pollID string, version int, credentials PollCredentials, creatorResponse *Response) error
+	AddVenueWriteIn(ctx context.Context, pollID string, venue Venue, response Response) error
 	DeleteResponse(ctx context.Context, pollID string, responseID string) error
 	GetStats(ctx context.Context) (Stats, error)
+	GetStatsSeries(ctx context.Context, from string, to string) ([]StatsPeriod, error)
+}
+
+// Sweeper archives ended polls and deletes expired ones on backends without a TTL.
+type Sweeper interface {
+	SweepPolls(ctx context.Context, now time.Time) (int, int, error)
 }
 
 type Venue struct {
@@ -45,34 +124,155 @@ type Venue struct {
 	Description string `dynamodbav:"description"`
 }
 
+// TimeSlot is a time of day offered on every poll day; Start and End are optional HH:MM times.
+type TimeSlot struct {
+	ID    string `dynamodbav:"id"`
+	Name  string `dynamodbav:"name"`
+	Start string `dynamodbav:"start"`
+	End   string `dynamodbav:"end"`
+}
+
 type Poll struct {
 	ID           string
 	Title        string
 	Days         []string
+	Slots        []TimeSlot
 	Venues       []Venue
 	CreatorToken string
+	AdminToken   string
+	RecoveryHash string
+	// Response IDs of people the creator marked as must attend.
+	RequiredAttendees []string
+	// The fewest people who must be able to make a day for it to be recommended; 0 means no minimum.
+	Quorum int
+	// Number of consecutive days a trip needs; 0 for a single-day hangout.
+	TripLength   int
+	ClosedAt     time.Time
+	Deadline     time.Time
+	FinalDay     string
+	FinalVenueID string
+	TimeZone     string
+	ArchivedAt   time.Time
+	ExpiresAt    time.Time
 	CreatedAt    time.Time
+	Version      int
+}
+
+// PollCredentials holds the secrets that identify and authorize a poll's creator.
+type PollCredentials struct {
+	CreatorToken string
+	AdminToken   string
+	RecoveryHash string
 }
 
+// PollSettings holds the organizer-set poll fields that are saved without touching responses.
+type PollSettings struct {
+	RequiredAttendees []string
+	Quorum            int
+	TripLength        int
+	ClosedAt          time.Time
+	Deadline          time.Time
+	FinalDay          string
+	FinalVenueID      string
+	TimeZone          string
+}
+
+// pollSettings returns a copy of the poll's current settings.
+func pollSettings(poll Poll) PollSettings {
+	return PollSettings{
+		RequiredAttendees: cloneStrings(poll.RequiredAttendees),
+		Quorum:            poll.Quorum,
+		TripLength:        poll.TripLength,
+		ClosedAt:          poll.ClosedAt,
+		Deadline:          poll.Deadline,
+		FinalDay:          poll.FinalDay,
+		FinalVenueID:      poll.FinalVenueID,
+		TimeZone:          poll.TimeZone,
+	}
+}
+
+// Response is one person's answer; poll days in neither Days nor IfNeedBeDays are unavailable.
 type Response struct {
-	ID         string
-	Name       string
-	Days       []string
-	VenueVotes []string
-	UserToken  string
-	CreatedAt  time.Time
+	ID           string
+	Name         string
+	Days         []string
+	IfNeedBeDays []string
+	VenueVotes   []string
+	UserToken    string
+	// Set while the creator has made this responder a co-organizer; it is their management secret.
+	OrganizerToken string
+	CreatedAt      time.Time
+	Version        int
 }
 
-type DayOption struct {
-	Date  string
+// CalendarMonth is a month of the calendar picker in Monday-first weeks.
+type CalendarMonth struct {
+	Key   string
 	Label string
+	Weeks [][]CalendarDay
+}
+
+type CalendarDay struct {
+	Date     string
+	Day      int
+	Disabled bool
+}
+
+// DateRange is a start/end range with optional weekdays, expanded into poll days.
+type DateRange struct {
+	Start    string
+	End      string
+	Weekdays map[string]bool
 }
 
+// DaySummary aggregates one poll option; Key is the day, or day and slot on polls with slots.
 type DaySummary struct {
-	Date         string
-	Label        string
-	Names        []string
-	AllAvailable bool
+	Date          string
+	Key           string
+	Label         string
+	SlotLabel     string
+	Names         []string
+	IfNeedBeNames []string
+	Score         int
+	Elapsed       bool
+	AllAvailable  bool
+	AllIfNeedBe   bool
+}
+
+// FinalChoice is the finalized day and venue with who can and cannot make it.
+type FinalChoice struct {
+	Date          string
+	Label         string
+	Venue         *Venue
+	Names         []string
+	IfNeedBeNames []string
+	Missing       []string
+}
+
+// DayRecommendation is a poll option ranked by recommendDays.
+type DayRecommendation struct {
+	Key             string
+	Label           string
+	Attending       int
+	IfNeedBe        int
+	Total           int
+	Missing         []string
+	MissingRequired []string
+	MeetsQuorum     bool
+	Explanation     string
+}
+
+// WindowSummary is a run of TripLength consecutive poll days ranked by summarizeWindows.
+type WindowSummary struct {
+	Start         string
+	End           string
+	Label         string
+	Names         []string
+	IfNeedBeNames []string
+	Missing       []string
+	Free          int
+	Total         int
+	AllFree       bool
 }
 
 type VenueSummary struct {
@@ -82,29 +282,124 @@ type VenueSummary struct {
 }
 
 type PollView struct {
-	Poll               Poll
-	Responses          []Response
-	Summaries          []DaySummary
-	VenueSummaries     []VenueSummary
-	TotalResponse      int
-	Error              string
-	ShareURL           string
-	ViewerToken        string
-	ViewerName         string
-	PlaceholderName    string
-	SelectedDays       map[string]bool
-	SelectedVenueVotes map[string]bool
-	AllAvailableDays   map[string]bool
-	IsCreator          bool
-	EditDays           []DayOption
-	EditVenues         []Venue
-	PollDaySet         map[string]bool
-	HasVenueOptions    bool
+	Poll                 Poll
+	Responses            []Response
+	Summaries            []DaySummary
+	Recommendations      []DayRecommendation
+	Windows              []WindowSummary
+	VenueSummaries       []VenueSummary
+	TotalResponse        int
+	Error                string
+	ShareURL             string
+	ViewerToken          string
+	ViewerName           string
+	PlaceholderName      string
+	SelectedDays         map[string]bool
+	SelectedIfNeedBeDays map[string]bool
+	SelectedVenueVotes   map[string]bool
+	AllAvailableDays     map[string]bool
+	AllIfNeedBeDays      map[string]bool
+	IsCreator            bool
+	Closed               bool
+	Archived             bool
+	ReadOnly             bool
+	ClosedMessage        string
+	DeadlineLabel        string
+	DeadlineInput        string
+	TimeZone             string
+	TimeZoneInput        string
+	Final                *FinalChoice
+	SuggestedDay         string
+	SuggestedVenueID     string
+	CSRFToken            string
+	Errors               FieldErrors
+	WriteIn              Venue
+	RecoveryCode         string
+	RecoverURL           string
+	CanManage            bool
+	Organizers           map[string]bool
+	// Management URLs of co-organizers by response ID, only filled in for the creator.
+	OrganizerLinks    map[string]string
+	RequiredAttendees map[string]bool
+	Quorum            int
+	QuorumInput       string
+	TripLength        int
+	TripLengthInput   string
+	FormURL           string
+	ManageURL         string
+	EditMonths        []CalendarMonth
+	EditRange         DateRange
+	EditSlots         []TimeSlot
+	EditVenues        []Venue
+	PollDaySet        map[string]bool
+	HasVenueOptions   bool
+}
+
+// FieldErrors maps form field names to the message shown next to that field.
+type FieldErrors map[string]string
+
+type HomeView struct {
+	Months          []CalendarMonth
+	Range           DateRange
+	TimeZone        string
+	Message         string
+	PlaceholderName string
+	CSRFToken       string
+	Errors          FieldErrors
+	Title           string
+	Creator         string
+	SelectedDays    map[string]bool
+	Venues          []Venue
 }
 
 type Stats struct {
-	PollCount     int
-	ResponseCount int
+	PollCount     int           `json:"poll_count"`
+	ResponseCount int           `json:"response_count"`
+	WriteInCount  int           `json:"write_in_count"`
+	DeletionCount int           `json:"deletion_count"`
+	Daily         []StatsPeriod `json:"daily,omitempty"`
+	Weekly        []StatsPeriod `json:"weekly,omitempty"`
+}
+
+type StatsCounters struct {
+	Polls      int `json:"polls"`
+	VenuePolls int `json:"venue_polls"`
+	Responses  int `json:"responses"`
+	WriteIns   int `json:"write_ins"`
+	Deletions  int `json:"deletions"`
+}
+
+type StatsPeriod struct {
+	Start string `json:"start"`
+	StatsCounters
+	RespondentsPerPoll float64 `json:"respondents_per_poll"`
+	VenueShare         float64 `json:"venue_share"`
+	WriteInRate        float64 `json:"write_in_rate"`
+}
+
+type StatsView struct {
+	Stats
+	DailyCharts  []StatsChart
+	WeeklyCharts []StatsChart
+	CSRFToken    string
+}
+
+type StatsChart struct {
+	Title      string
+	Width      int
+	Height     int
+	Max        string
+	FirstLabel string
+	LastLabel  string
+	Bars       []StatsBar
+}
+
+type StatsBar struct {
+	X      float64
+	Y      float64
+	Width  float64
+	Height float64
+	Label  string
 }
 
 type DynamoDBStorage struct {
@@ -113,32 +408,106 @@ type DynamoDBStorage struct {
 }
 
 type PollItem struct {
+	PK           string     `dynamodbav:"pk"`
+	SK           string     `dynamodbav:"sk"`
+	Type         string     `dynamodbav:"type"`
+	ID           string     `dynamodbav:"id"`
+	Title        string     `dynamodbav:"title"`
+	Days         []string   `dynamodbav:"days"`
+	Slots        []TimeSlot `dynamodbav:"slots,omitempty"`
+	Venues       []Venue    `dynamodbav:"venues"`
+	CreatorToken string     `dynamodbav:"creator_token"`
+	AdminToken   string     `dynamodbav:"admin_token"`
+	RecoveryHash string     `dynamodbav:"recovery_hash"`
+	Required     []string   `dynamodbav:"required_attendees,omitempty"`
+	Quorum       int        `dynamodbav:"quorum,omitempty"`
+	TripLength   int        `dynamodbav:"trip_length,omitempty"`
+	ClosedAt     string     `dynamodbav:"closed_at,omitempty"`
+	Deadline     string     `dynamodbav:"deadline,omitempty"`
+	FinalDay     string     `dynamodbav:"final_day,omitempty"`
+	FinalVenueID string     `dynamodbav:"final_venue_id,omitempty"`
+	TimeZone     string     `dynamodbav:"time_zone,omitempty"`
+	ArchivedAt   string     `dynamodbav:"archived_at,omitempty"`
+	ExpiresAt    int64      `dynamodbav:"expires_at,omitempty"`
+	CreatedAt    string     `dynamodbav:"created_at"`
+	Version      int        `dynamodbav:"version"`
+}
+
+type ResponseItem struct {
 	PK           string   `dynamodbav:"pk"`
 	SK           string   `dynamodbav:"sk"`
 	Type         string   `dynamodbav:"type"`
 	ID           string   `dynamodbav:"id"`
-	Title        string   `dynamodbav:"title"`
+	Name         string   `dynamodbav:"name"`
 	Days         []string `dynamodbav:"days"`
-	Venues       []Venue  `dynamodbav:"venues"`
-	CreatorToken string   `dynamodbav:"creator_token"`
+	IfNeedBeDays []string `dynamodbav:"if_need_be_days,omitempty"`
+	VenueVotes   []string `dynamodbav:"venue_votes"`
+	UserToken    string   `dynamodbav:"user_token"`
+	Organizer    string   `dynamodbav:"organizer_token,omitempty"`
+	ExpiresAt    int64    `dynamodbav:"expires_at,omitempty"`
 	CreatedAt    string   `dynamodbav:"created_at"`
+	Version      int      `dynamodbav:"version"`
 }
 
-type ResponseItem struct {
-	PK         string   `dynamodbav:"pk"`
-	SK         string   `dynamodbav:"sk"`
-	Type       string   `dynamodbav:"type"`
-	ID         string   `dynamodbav:"id"`
-	Name       string   `dynamodbav:"name"`
-	Days       []string `dynamodbav:"days"`
-	VenueVotes []string `dynamodbav:"venue_votes"`
-	UserToken  string   `dynamodbav:"user_token"`
-	CreatedAt  string   `dynamodbav:"created_at"`
+type StatsItem struct {
+	PK            string `dynamodbav:"pk"`
+	SK            string `dynamodbav:"sk"`
+	Type          string `dynamodbav:"type"`
+	PollCount     int    `dynamodbav:"poll_count"`
+	ResponseCount int    `dynamodbav:"response_count"`
+	WriteInCount  int    `dynamodbav:"write_in_count"`
+	DeletionCount int    `dynamodbav:"deletion_count"`
+	BackfilledAt  string `dynamodbav:"backfilled_at"`
+}
+
+type RateLimitItem struct {
+	PK        string  `dynamodbav:"pk"`
+	SK        string  `dynamodbav:"sk"`
+	Type      string  `dynamodbav:"type"`
+	Tokens    float64 `dynamodbav:"tokens"`
+	UpdatedAt int64   `dynamodbav:"updated_at"`
+	ExpiresAt int64   `dynamodbav:"expires_at"`
+}
+
+type StatsDayItem struct {
+	PK         string `dynamodbav:"pk"`
+	SK         string `dynamodbav:"sk"`
+	Type       string `dynamodbav:"type"`
+	Day        string `dynamodbav:"day"`
+	Polls      int    `dynamodbav:"poll_count"`
+	VenuePolls int    `dynamodbav:"venue_poll_count"`
+	Responses  int    `dynamodbav:"response_count"`
+	WriteIns   int    `dynamodbav:"write_in_count"`
+	Deletions  int    `dynamodbav:"deletion_count"`
+}
+
+// statsChange holds the totals and per-day (UTC) counters a single write adds.
+type statsChange struct {
+	total StatsCounters
+	days  map[string]StatsCounters
 }
 
 type MemoryStorage struct {
+	mu        sync.RWMutex
 	polls     map[string]Poll
 	responses map[string][]Response
+	writeIns  int
+	deletions int
+	daily     map[string]StatsCounters
+}
+
+type FileStorage struct {
+	mu     sync.RWMutex
+	path   string
+	memory *MemoryStorage
+}
+
+type fileSnapshot struct {
+	Polls     []Poll                   `json:"polls"`
+	Responses map[string][]Response    `json:"responses"`
+	WriteIns  int                      `json:"write_ins"`
+	Deletions int                      `json:"deletions"`
+	Daily     map[string]StatsCounters `json:"daily,omitempty"`
 }
 
 type App struct {
@@ -146,6 +515,48 @@ type App struct {
 	templates       *template.Template
 	baseURL         string
 	reloadTemplates bool
+	admin           *AdminAuth
+	csrfKey         []byte
+	limiter         RateLimiter
+	rateLimits      map[string]RateLimit
+	trustForwarded  bool
+	retention       time.Duration
+}
+
+// RateLimit is a token bucket of Requests tokens that refills completely over Per.
+type RateLimit struct {
+	Requests int
+	Per      time.Duration
+}
+
+// RateLimiter takes one token for key, or reports how long until the next one is available.
+type RateLimiter interface {
+	Allow(ctx context.Context, key string, limit RateLimit, now time.Time) (bool, time.Duration, error)
+}
+
+// rateBucket is the stored state of one rate limit key.
+type rateBucket struct {
+	tokens  float64
+	updated time.Time
+	expires time.Time
+}
+
+type MemoryRateLimiter struct {
+	mu      sync.Mutex
+	buckets map[string]rateBucket
+}
+
+type DynamoDBRateLimiter struct {
+	client *dynamodb.Client
+	Table  string
+}
+
+// defaultRateLimits maps "{route}.{scope}" names to their limits.
+var defaultRateLimits = map[string]RateLimit{
+	"create-poll.ip": {Requests: 10, Per: time.Hour},
+	"respond.ip":     {Requests: 30, Per: time.Minute},
+	"respond.poll":   {Requests: 300, Per: time.Hour},
+	"recover.ip":     {Requests: 10, Per: time.Hour},
 }
 
 //go:embed templates/*.html
@@ -162,50 +573,152 @@ func main() {
 		log.Fatalf("failed to parse templates: %v", err)
 	}
 
+	admin, err := newAdminAuth(os.Getenv("ADMIN_PASSWORD_HASH"), os.Getenv("ADMIN_SESSION_SECRET"))
+	if err != nil {
+		log.Fatalf("failed to configure admin auth: %v", err)
+	}
+
+	csrfKey, err := newCSRFKey(os.Getenv("CSRF_SECRET"))
+	if err != nil {
+		log.Fatalf("failed to configure CSRF protection: %v", err)
+	}
+
+	rateLimits, err := parseRateLimits(os.Getenv("RATE_LIMITS"))
+	if err != nil {
+		log.Fatalf("failed to parse RATE_LIMITS: %v", err)
+	}
+	retention, err := parseRetention(os.Getenv("ARCHIVE_RETENTION"))
+	if err != nil {
+		log.Fatalf("failed to parse ARCHIVE_RETENTION: %v", err)
+	}
+	trustForwarded := os.Getenv("AWS_LAMBDA_FUNCTION_NAME") != ""
+	if value := os.Getenv("TRUST_FORWARDED_FOR"); value != "" {
+		trustForwarded = value == "true"
+	}
+
 	app := &App{
 		storage:         storage,
 		templates:       templates,
 		baseURL:         os.Getenv("APP_BASE_URL"),
 		reloadTemplates: os.Getenv("DEV_RELOAD_TEMPLATES") == "true",
+		admin:           admin,
+		csrfKey:         csrfKey,
+		limiter:         newRateLimiter(storage),
+		rateLimits:      rateLimits,
+		trustForwarded:  trustForwarded,
+		retention:       retention,
 	}
 
 	mux := http.NewServeMux()
 	mux.HandleFunc("/", app.handleHome)
 	mux.HandleFunc("/polls", app.handleCreatePoll)
 	mux.HandleFunc("/poll/", app.handlePoll)
-	mux.HandleFunc("/admin/stats", app.handleStats)
+	mux.HandleFunc("/admin/login", app.handleAdminLogin)
+	mux.HandleFunc("/admin/logout", app.handleAdminLogout)
+	mux.Handle("/admin/", app.requireAdmin(app.adminRoutes()))
+	handler := app.limitRate(app.protectCSRF(mux))
 
 	if os.Getenv("AWS_LAMBDA_FUNCTION_NAME") != "" {
-		adapter := httpadapter.NewV2(mux)
+		adapter := httpadapter.NewV2(handler)
 		lambda.Start(adapter.ProxyWithContext)
 		return
 	}
 
+	if sweeper, ok := storage.(Sweeper); ok {
+		go app.runSweeper(sweeper)
+	}
+
 	addr := ":8080"
 	log.Printf("starting server on %s", addr)
-	if err := http.ListenAndServe(addr, mux); err != nil {
+	if err := http.ListenAndServe(addr, handler); err != nil {
 		log.Fatalf("server failed: %v", err)
 	}
 }
 
-func newStorage(ctx context.Context) (Storage, error) {
-	if os.Getenv("USE_MEMORY_STORE") == "true" {
-		return &MemoryStorage{
-			polls:     make(map[string]Poll),
-			responses: make(map[string][]Response),
-		}, nil
+// runSweeper sweeps polls at startup and then hourly.
+func (a *App) runSweeper(sweeper Sweeper) {
+	ticker := time.NewTicker(sweepInterval)
+	defer ticker.Stop()
+	for {
+		archived, deleted, err := sweeper.SweepPolls(context.Background(), time.Now())
+		if err != nil {
+			log.Printf("failed to sweep polls: %v", err)
+		} else if archived > 0 || deleted > 0 {
+			log.Printf("archived %d and deleted %d polls", archived, deleted)
+		}
+		<-ticker.C
 	}
+}
 
-	cfg, err := config.LoadDefaultConfig(ctx)
-	if err != nil {
-		return nil, err
+// parseRetention parses ARCHIVE_RETENTION; empty means ended polls are kept forever.
+func parseRetention(value string) (time.Duration, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, nil
+	}
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		count, err := strconv.Atoi(days)
+		if err != nil || count <= 0 {
+			return 0, fmt.Errorf("invalid retention %q: want a duration such as 90d or 720h", value)
+		}
+		return time.Duration(count) * 24 * time.Hour, nil
+	}
+	retention, err := time.ParseDuration(value)
+	if err != nil || retention <= 0 {
+		return 0, fmt.Errorf("invalid retention %q: want a duration such as 90d or 720h", value)
+	}
+	return retention, nil
+}
+
+// adminRoutes returns the admin pages, all behind requireAdmin.
+func (a *App) adminRoutes() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/admin/stats", a.handleStats)
+	mux.HandleFunc("/admin/stats.json", a.handleStats)
+	return mux
+}
+
+// newRateLimiter keeps buckets in the table when storage is DynamoDB.
+func newRateLimiter(storage Storage) RateLimiter {
+	if dynamo, ok := storage.(*DynamoDBStorage); ok {
+		return &DynamoDBRateLimiter{client: dynamo.client, Table: dynamo.Table}
+	}
+	return newMemoryRateLimiter()
+}
+
+func newStorage(ctx context.Context) (Storage, error) {
+	switch storageBackend() {
+	case "memory":
+		return newMemoryStorage(), nil
+	case "file":
+		path := os.Getenv("DATA_PATH")
+		if path == "" {
+			path = defaultDataPath
+		}
+		return newFileStorage(path)
+	case "dynamodb":
+	default:
+		return nil, fmt.Errorf("unknown STORAGE backend %q", os.Getenv("STORAGE"))
 	}
 
-	client := dynamodb.NewFromConfig(cfg)
 	table := os.Getenv("DYNAMODB_TABLE")
 	if table == "" {
 		table = defaultTableName
 	}
+	return newDynamoDBStorage(ctx, table, os.Getenv("DYNAMODB_ENDPOINT"))
+}
+
+func newDynamoDBStorage(ctx context.Context, table string, endpoint string) (*DynamoDBStorage, error) {
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	client := dynamodb.NewFromConfig(cfg, func(o *dynamodb.Options) {
+		if endpoint != "" {
+			o.BaseEndpoint = awsString(endpoint)
+		}
+	})
 
 	return &DynamoDBStorage{
 		client: client,
@@ -213,6 +726,16 @@ func newStorage(ctx context.Context) (Storage, error) {
 	}, nil
 }
 
+func storageBackend() string {
+	if backend := strings.ToLower(strings.TrimSpace(os.Getenv("STORAGE"))); backend != "" {
+		return backend
+	}
+	if os.Getenv("USE_MEMORY_STORE") == "true" {
+		return "memory"
+	}
+	return "dynamodb"
+}
+
 func (s *DynamoDBStorage) CreatePoll(ctx context.Context, poll Poll) error {
 	item := PollItem{
 		PK:           pollPartitionKey(poll.ID),
@@ -221,9 +744,23 @@ func (s *DynamoDBStorage) CreatePoll(ctx context.Context, poll Poll) error {
 		ID:           poll.ID,
 		Title:        poll.Title,
 		Days:         poll.Days,
+		Slots:        poll.Slots,
 		Venues:       poll.Venues,
 		CreatorToken: poll.CreatorToken,
+		AdminToken:   poll.AdminToken,
+		RecoveryHash: poll.RecoveryHash,
+		Required:     poll.RequiredAttendees,
+		Quorum:       poll.Quorum,
+		TripLength:   poll.TripLength,
+		ClosedAt:     formatOptionalTime(poll.ClosedAt),
+		Deadline:     formatOptionalTime(poll.Deadline),
+		FinalDay:     poll.FinalDay,
+		FinalVenueID: poll.FinalVenueID,
+		TimeZone:     poll.TimeZone,
+		ArchivedAt:   formatOptionalTime(poll.ArchivedAt),
+		ExpiresAt:    optionalUnix(poll.ExpiresAt),
 		CreatedAt:    poll.CreatedAt.Format(time.RFC3339),
+		Version:      poll.Version,
 	}
 
 	av, err := attributevalue.MarshalMap(item)
@@ -231,67 +768,60 @@ func (s *DynamoDBStorage) CreatePoll(ctx context.Context, poll Poll) error {
 		return err
 	}
 
-	_, err = s.client.PutItem(ctx, &dynamodb.PutItemInput{
-		TableName:           &s.Table,
-		Item:                av,
-		ConditionExpression: awsString("attribute_not_exists(pk)"),
-	})
+	err = s.transact(ctx, append([]types.TransactWriteItem{
+		{Put: &types.Put{
+			TableName:           &s.Table,
+			Item:                av,
+			ConditionExpression: awsString("attribute_not_exists(pk)"),
+		}},
+	}, s.statsUpdates(statsChange{}.with(poll.CreatedAt, pollCounters(poll)))...))
+	if transactionConditionFailed(err, 0) {
+		return errConflict
+	}
 	return err
 }
 
 func (s *DynamoDBStorage) GetPoll(ctx context.Context, pollID string) (Poll, []Response, error) {
 	pk := pollPartitionKey(pollID)
-	out, err := s.client.Query(ctx, &dynamodb.QueryInput{
-		TableName:              &s.Table,
-		KeyConditionExpression: awsString("pk = :pk"),
-		ExpressionAttributeValues: map[string]types.AttributeValue{
-			":pk": &types.AttributeValueMemberS{Value: pk},
-		},
-	})
-	if err != nil {
-		return Poll{}, nil, err
-	}
-	if len(out.Items) == 0 {
-		return Poll{}, nil, errNotFound
-	}
-
 	var poll Poll
 	var responses []Response
-	for _, item := range out.Items {
-		var typeHolder struct {
-			Type string `dynamodbav:"type"`
-		}
-		if err := attributevalue.UnmarshalMap(item, &typeHolder); err != nil {
+	var startKey map[string]types.AttributeValue
+	for {
+		out, err := s.client.Query(ctx, &dynamodb.QueryInput{
+			TableName:              &s.Table,
+			KeyConditionExpression: awsString("pk = :pk"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":pk": &types.AttributeValueMemberS{Value: pk},
+			},
+			ExclusiveStartKey: startKey,
+		})
+		if err != nil {
 			return Poll{}, nil, err
 		}
-		switch typeHolder.Type {
-		case "poll":
-			var pollItem PollItem
-			if err := attributevalue.UnmarshalMap(item, &pollItem); err != nil {
-				return Poll{}, nil, err
-			}
-			poll = Poll{
-				ID:           pollItem.ID,
-				Title:        pollItem.Title,
-				Days:         pollItem.Days,
-				Venues:       pollItem.Venues,
-				CreatorToken: pollItem.CreatorToken,
-				CreatedAt:    parseTime(pollItem.CreatedAt),
+		for _, item := range out.Items {
+			var typeHolder struct {
+				Type string `dynamodbav:"type"`
 			}
-		case "response":
-			var respItem ResponseItem
-			if err := attributevalue.UnmarshalMap(item, &respItem); err != nil {
+			if err := attributevalue.UnmarshalMap(item, &typeHolder); err != nil {
 				return Poll{}, nil, err
 			}
-			responses = append(responses, Response{
-				ID:         respItem.ID,
-				Name:       respItem.Name,
-				Days:       respItem.Days,
-				VenueVotes: normalizeVenueVotes(respItem.VenueVotes),
-				UserToken:  respItem.UserToken,
-				CreatedAt:  parseTime(respItem.CreatedAt),
-			})
+			switch typeHolder.Type {
+			case "poll":
+				if poll, err = pollFromItem(item); err != nil {
+					return Poll{}, nil, err
+				}
+			case "response":
+				response, err := responseFromItem(item)
+				if err != nil {
+					return Poll{}, nil, err
+				}
+				responses = append(responses, response)
+			}
+		}
+		if len(out.LastEvaluatedKey) == 0 {
+			break
 		}
+		startKey = out.LastEvaluatedKey
 	}
 
 	if poll.ID == "" {
@@ -305,185 +835,1093 @@ func (s *DynamoDBStorage) GetPoll(ctx context.Context, pollID string) (Poll, []R
 	return poll, responses, nil
 }
 
+func (c *StatsCounters) add(other StatsCounters) {
+	c.Polls += other.Polls
+	c.VenuePolls += other.VenuePolls
+	c.Responses += other.Responses
+	c.WriteIns += other.WriteIns
+	c.Deletions += other.Deletions
+}
+
+func pollCounters(poll Poll) StatsCounters {
+	counters := StatsCounters{Polls: 1}
+	if len(poll.Venues) > 0 {
+		counters.VenuePolls = 1
+	}
+	return counters
+}
+
+func (c statsChange) with(at time.Time, counters StatsCounters) statsChange {
+	next := statsChange{total: c.total, days: make(map[string]StatsCounters, len(c.days)+1)}
+	for day, dayCounters := range c.days {
+		next.days[day] = dayCounters
+	}
+	next.total.add(counters)
+	day := at.UTC().Format("2006-01-02")
+	dayCounters := next.days[day]
+	dayCounters.add(counters)
+	next.days[day] = dayCounters
+	return next
+}
+
+func (item StatsItem) stats() Stats {
+	return Stats{
+		PollCount:     item.PollCount,
+		ResponseCount: item.ResponseCount,
+		WriteInCount:  item.WriteInCount,
+		DeletionCount: item.DeletionCount,
+	}
+}
+
+func pollFromItem(item map[string]types.AttributeValue) (Poll, error) {
+	var pollItem PollItem
+	if err := attributevalue.UnmarshalMap(item, &pollItem); err != nil {
+		return Poll{}, err
+	}
+	return Poll{
+		ID:                pollItem.ID,
+		Title:             pollItem.Title,
+		Days:              pollItem.Days,
+		Slots:             pollItem.Slots,
+		Venues:            pollItem.Venues,
+		CreatorToken:      pollItem.CreatorToken,
+		AdminToken:        pollItem.AdminToken,
+		RecoveryHash:      pollItem.RecoveryHash,
+		RequiredAttendees: pollItem.Required,
+		Quorum:            pollItem.Quorum,
+		TripLength:        pollItem.TripLength,
+		ClosedAt:          parseOptionalTime(pollItem.ClosedAt),
+		Deadline:          parseOptionalTime(pollItem.Deadline),
+		FinalDay:          pollItem.FinalDay,
+		FinalVenueID:      pollItem.FinalVenueID,
+		TimeZone:          pollItem.TimeZone,
+		ArchivedAt:        parseOptionalTime(pollItem.ArchivedAt),
+		ExpiresAt:         parseOptionalUnix(pollItem.ExpiresAt),
+		CreatedAt:         parseTime(pollItem.CreatedAt),
+		Version:           pollItem.Version,
+	}, nil
+}
+
+func responseFromItem(item map[string]types.AttributeValue) (Response, error) {
+	var respItem ResponseItem
+	if err := attributevalue.UnmarshalMap(item, &respItem); err != nil {
+		return Response{}, err
+	}
+	return Response{
+		ID:             respItem.ID,
+		Name:           respItem.Name,
+		Days:           respItem.Days,
+		IfNeedBeDays:   respItem.IfNeedBeDays,
+		VenueVotes:     normalizeVenueVotes(respItem.VenueVotes),
+		UserToken:      respItem.UserToken,
+		OrganizerToken: respItem.Organizer,
+		CreatedAt:      parseTime(respItem.CreatedAt),
+		Version:        respItem.Version,
+	}, nil
+}
+
+// AddResponse saves a response that copies the poll's expires_at.
 func (s *DynamoDBStorage) AddResponse(ctx context.Context, pollID string, response Response) error {
+	expiresAt, err := s.pollExpiresAt(ctx, pollID)
+	if err != nil {
+		return err
+	}
+	for attempt := 1; attempt <= maxWriteAttempts; attempt++ {
+		err = s.putResponse(ctx, pollID, response, expiresAt, s.pollExpiryCheck(pollID, expiresAt), statsChange{})
+		if !transactionConditionFailed(err, 0) {
+			break
+		}
+		reason := cancellationReason(err, 0)
+		if reason == nil || len(reason.Item) == 0 {
+			return errNotFound
+		}
+		expiresAt = itemExpiresAt(reason.Item)
+	}
+	if transactionConditionFailed(err, 0) || transactionConditionFailed(err, 1) {
+		return errConflict
+	}
+	return err
+}
+
+// putResponse writes a response, creating it first when its version is 0.
+func (s *DynamoDBStorage) putResponse(ctx context.Context, pollID string, response Response, expiresAt int64, first types.TransactWriteItem, change statsChange) error {
+	creating := response.Version == 0
+	for {
+		put, err := s.responsePut(pollID, response, creating, expiresAt)
+		if err != nil {
+			return err
+		}
+		itemChange := change
+		if creating {
+			itemChange = change.with(response.CreatedAt, StatsCounters{Responses: 1})
+		}
+		err = s.transact(ctx, append([]types.TransactWriteItem{first, put}, s.statsUpdates(itemChange)...))
+		if creating && transactionConditionFailed(err, 1) {
+			creating = false
+			continue
+		}
+		return err
+	}
+}
+
+func (s *DynamoDBStorage) responsePut(pollID string, response Response, creating bool, expiresAt int64) (types.TransactWriteItem, error) {
 	item := ResponseItem{
-		PK:         pollPartitionKey(pollID),
-		SK:         "RESP#" + response.ID,
-		Type:       "response",
-		ID:         response.ID,
-		Name:       response.Name,
-		Days:       response.Days,
-		VenueVotes: normalizeVenueVotes(response.VenueVotes),
-		UserToken:  response.UserToken,
-		CreatedAt:  response.CreatedAt.Format(time.RFC3339),
+		PK:           pollPartitionKey(pollID),
+		SK:           "RESP#" + response.ID,
+		Type:         "response",
+		ID:           response.ID,
+		Name:         response.Name,
+		Days:         response.Days,
+		IfNeedBeDays: response.IfNeedBeDays,
+		VenueVotes:   normalizeVenueVotes(response.VenueVotes),
+		UserToken:    response.UserToken,
+		Organizer:    response.OrganizerToken,
+		ExpiresAt:    expiresAt,
+		CreatedAt:    response.CreatedAt.Format(time.RFC3339),
+		Version:      response.Version + 1,
 	}
 	av, err := attributevalue.MarshalMap(item)
 	if err != nil {
-		return err
+		return types.TransactWriteItem{}, err
 	}
-	_, err = s.client.PutItem(ctx, &dynamodb.PutItemInput{
-		TableName: &s.Table,
-		Item:      av,
-	})
-	return err
+	if creating {
+		return types.TransactWriteItem{Put: &types.Put{
+			TableName:           &s.Table,
+			Item:                av,
+			ConditionExpression: awsString("attribute_not_exists(pk)"),
+		}}, nil
+	}
+	return types.TransactWriteItem{Put: &types.Put{
+		TableName:                 &s.Table,
+		Item:                      av,
+		ConditionExpression:       awsString("attribute_exists(pk) AND " + versionCondition(response.Version)),
+		ExpressionAttributeNames:  versionAttributeNames(),
+		ExpressionAttributeValues: versionAttributeValues(response.Version),
+	}}, nil
 }
 
-func (s *DynamoDBStorage) GetStats(ctx context.Context) (Stats, error) {
-	pollCount, err := s.countByType(ctx, "poll")
+// AddVenueWriteIn appends the venue and saves the response in one transaction.
+func (s *DynamoDBStorage) AddVenueWriteIn(ctx context.Context, pollID string, venue Venue, response Response) error {
+	venueAttr, err := attributevalue.Marshal([]Venue{venue})
 	if err != nil {
-		return Stats{}, err
+		return err
 	}
-	responseCount, err := s.countByType(ctx, "response")
+	expiresAt, err := s.pollExpiresAt(ctx, pollID)
 	if err != nil {
-		return Stats{}, err
+		return err
+	}
+	hasVenueList := true
+	for attempt := 1; attempt <= maxWriteAttempts; attempt++ {
+		values := map[string]types.AttributeValue{
+			":venue": venueAttr,
+			":zero":  &types.AttributeValueMemberN{Value: "0"},
+			":one":   &types.AttributeValueMemberN{Value: "1"},
+		}
+		update := &types.Update{
+			TableName: &s.Table,
+			Key: map[string]types.AttributeValue{
+				"pk": &types.AttributeValueMemberS{Value: pollPartitionKey(pollID)},
+				"sk": &types.AttributeValueMemberS{Value: "POLL"},
+			},
+			ExpressionAttributeNames:            versionAttributeNames(),
+			ExpressionAttributeValues:           values,
+			ReturnValuesOnConditionCheckFailure: types.ReturnValuesOnConditionCheckFailureAllOld,
+		}
+		expiry := expiryCondition(expiresAt, values)
+		if hasVenueList {
+			update.UpdateExpression = awsString("SET venues = list_append(venues, :venue), #version = if_not_exists(#version, :zero) + :one")
+			update.ConditionExpression = awsString("attribute_exists(pk) AND attribute_type(venues, :list) AND " + expiry)
+			values[":list"] = &types.AttributeValueMemberS{Value: "L"}
+		} else {
+			update.UpdateExpression = awsString("SET venues = :venue, #version = if_not_exists(#version, :zero) + :one")
+			update.ConditionExpression = awsString("attribute_exists(pk) AND (attribute_not_exists(venues) OR attribute_type(venues, :null)) AND " + expiry)
+			values[":null"] = &types.AttributeValueMemberS{Value: "NULL"}
+		}
+		err = s.putResponse(ctx, pollID, response, expiresAt, types.TransactWriteItem{Update: update}, statsChange{}.with(time.Now(), StatsCounters{WriteIns: 1}))
+		if transactionConditionFailed(err, 1) {
+			return errConflict
+		}
+		if !transactionConditionFailed(err, 0) {
+			return err
+		}
+		reason := cancellationReason(err, 0)
+		if reason == nil || len(reason.Item) == 0 {
+			return errNotFound
+		}
+		_, hasVenueList = reason.Item["venues"].(*types.AttributeValueMemberL)
+		expiresAt = itemExpiresAt(reason.Item)
 	}
-	return Stats{PollCount: pollCount, ResponseCount: responseCount}, nil
+	return errConflict
 }
 
-func (s *DynamoDBStorage) countByType(ctx context.Context, itemType string) (int, error) {
-	total := 0
+// GetStats sums the stats shards, backfilling the totals on first use.
+func (s *DynamoDBStorage) GetStats(ctx context.Context) (Stats, error) {
+	var base StatsItem
+	var total Stats
 	var startKey map[string]types.AttributeValue
 	for {
-		out, err := s.client.Scan(ctx, &dynamodb.ScanInput{
-			TableName:        &s.Table,
-			Select:           types.SelectCount,
-			FilterExpression: awsString("#t = :type"),
-			ExpressionAttributeNames: map[string]string{
-				"#t": "type",
-			},
+		out, err := s.client.Query(ctx, &dynamodb.QueryInput{
+			TableName:              &s.Table,
+			KeyConditionExpression: awsString("pk = :pk AND begins_with(sk, :prefix)"),
 			ExpressionAttributeValues: map[string]types.AttributeValue{
-				":type": &types.AttributeValueMemberS{Value: itemType},
+				":pk":     &types.AttributeValueMemberS{Value: "STATS"},
+				":prefix": &types.AttributeValueMemberS{Value: "STATS"},
 			},
+			ConsistentRead:    awsBool(true),
 			ExclusiveStartKey: startKey,
 		})
 		if err != nil {
-			return 0, err
+			return Stats{}, err
+		}
+		for _, av := range out.Items {
+			var item StatsItem
+			if err := attributevalue.UnmarshalMap(av, &item); err != nil {
+				return Stats{}, err
+			}
+			if item.SK == "STATS" {
+				base = item
+			}
+			shard := item.stats()
+			total.PollCount += shard.PollCount
+			total.ResponseCount += shard.ResponseCount
+			total.WriteInCount += shard.WriteInCount
+			total.DeletionCount += shard.DeletionCount
 		}
-		total += int(out.Count)
 		if len(out.LastEvaluatedKey) == 0 {
 			break
 		}
 		startKey = out.LastEvaluatedKey
 	}
+	if base.BackfilledAt == "" {
+		return s.backfillStats(ctx, base, total)
+	}
 	return total, nil
 }
 
-func (s *DynamoDBStorage) UpdatePollDays(ctx context.Context, pollID string, days []string) error {
-	_, err := s.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
-		TableName: &s.Table,
-		Key: map[string]types.AttributeValue{
-			"pk": &types.AttributeValueMemberS{Value: pollPartitionKey(pollID)},
-			"sk": &types.AttributeValueMemberS{Value: "POLL"},
+// backfillStats stores scanned totals on tables created before the stats item.
+func (s *DynamoDBStorage) backfillStats(ctx context.Context, item StatsItem, total Stats) (Stats, error) {
+	pollCount, err := s.countByType(ctx, "poll")
+	if err != nil {
+		return Stats{}, err
+	}
+	responseCount, err := s.countByType(ctx, "response")
+	if err != nil {
+		return Stats{}, err
+	}
+	item.PollCount = pollCount - (total.PollCount - item.PollCount)
+	item.ResponseCount = responseCount - (total.ResponseCount - item.ResponseCount)
+	_, err = s.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:           &s.Table,
+		Key:                 statsKey(),
+		UpdateExpression:    awsString("SET #t = :type, poll_count = :polls, response_count = :responses, backfilled_at = :now"),
+		ConditionExpression: awsString("attribute_not_exists(backfilled_at)"),
+		ExpressionAttributeNames: map[string]string{
+			"#t": "type",
 		},
-		UpdateExpression: awsString("SET days = :days"),
 		ExpressionAttributeValues: map[string]types.AttributeValue{
-			":days": &types.AttributeValueMemberL{Value: stringSliceAttribute(days)},
+			":type":      &types.AttributeValueMemberS{Value: "stats"},
+			":polls":     &types.AttributeValueMemberN{Value: fmt.Sprint(item.PollCount)},
+			":responses": &types.AttributeValueMemberN{Value: fmt.Sprint(item.ResponseCount)},
+			":now":       &types.AttributeValueMemberS{Value: time.Now().UTC().Format(time.RFC3339)},
 		},
 	})
-	return err
-}
-
-func (s *DynamoDBStorage) UpdatePollVenues(ctx context.Context, pollID string, venues []Venue) error {
+	if isConditionFailed(err) {
+		return s.GetStats(ctx)
+	}
+	if err != nil {
+		return Stats{}, err
+	}
+	total.PollCount = pollCount
+	total.ResponseCount = responseCount
+	return total, nil
+}
+
+// statsUpdates adds change to one randomly picked shard of the totals and of each day.
+func (s *DynamoDBStorage) statsUpdates(change statsChange) []types.TransactWriteItem {
+	shard := mathrand.Intn(statsShards)
+	var items []types.TransactWriteItem
+	if change.total != (StatsCounters{}) {
+		items = append(items, s.statsUpdate(statsShardKey(shard), "stats", nil, change.total))
+	}
+	days := make([]string, 0, len(change.days))
+	for day := range change.days {
+		days = append(days, day)
+	}
+	sort.Strings(days)
+	for _, day := range days {
+		if change.days[day] == (StatsCounters{}) {
+			continue
+		}
+		items = append(items, s.statsUpdate(statsDayKey(day, shard), "stats_day", map[string]types.AttributeValue{
+			"day": &types.AttributeValueMemberS{Value: day},
+		}, change.days[day]))
+	}
+	return items
+}
+
+func (s *DynamoDBStorage) statsUpdate(key map[string]types.AttributeValue, itemType string, attributes map[string]types.AttributeValue, counters StatsCounters) types.TransactWriteItem {
+	values := map[string]types.AttributeValue{
+		":type": &types.AttributeValueMemberS{Value: itemType},
+	}
+	// Attribute names such as day are DynamoDB reserved words, so every SET goes through an alias.
+	names := map[string]string{
+		"#t": "type",
+	}
+	sets := []string{"#t = :type"}
+	for name, value := range attributes {
+		sets = append(sets, "#"+name+" = :"+name)
+		names["#"+name] = name
+		values[":"+name] = value
+	}
+	var adds []string
+	for _, counter := range []struct {
+		attribute string
+		value     int
+	}{
+		{"poll_count", counters.Polls},
+		{"venue_poll_count", counters.VenuePolls},
+		{"response_count", counters.Responses},
+		{"write_in_count", counters.WriteIns},
+		{"deletion_count", counters.Deletions},
+	} {
+		if counter.value == 0 {
+			continue
+		}
+		placeholder := ":" + counter.attribute
+		adds = append(adds, counter.attribute+" "+placeholder)
+		values[placeholder] = &types.AttributeValueMemberN{Value: fmt.Sprint(counter.value)}
+	}
+	return types.TransactWriteItem{Update: &types.Update{
+		TableName:                 &s.Table,
+		Key:                       key,
+		UpdateExpression:          awsString("SET " + strings.Join(sets, ", ") + " ADD " + strings.Join(adds, ", ")),
+		ExpressionAttributeNames:  names,
+		ExpressionAttributeValues: values,
+	}}
+}
+
+// GetStatsSeries sums each day's shards between from and to.
+func (s *DynamoDBStorage) GetStatsSeries(ctx context.Context, from string, to string) ([]StatsPeriod, error) {
+	var periods []StatsPeriod
+	var startKey map[string]types.AttributeValue
+	for {
+		out, err := s.client.Query(ctx, &dynamodb.QueryInput{
+			TableName:              &s.Table,
+			KeyConditionExpression: awsString("pk = :pk AND sk BETWEEN :from AND :to"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":pk":   &types.AttributeValueMemberS{Value: "STATS"},
+				":from": &types.AttributeValueMemberS{Value: "DAY#" + from},
+				":to":   &types.AttributeValueMemberS{Value: "DAY#" + to + "#~"},
+			},
+			ExclusiveStartKey: startKey,
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, av := range out.Items {
+			var item StatsDayItem
+			if err := attributevalue.UnmarshalMap(av, &item); err != nil {
+				return nil, err
+			}
+			counters := StatsCounters{
+				Polls:      item.Polls,
+				VenuePolls: item.VenuePolls,
+				Responses:  item.Responses,
+				WriteIns:   item.WriteIns,
+				Deletions:  item.Deletions,
+			}
+			if last := len(periods) - 1; last >= 0 && periods[last].Start == item.Day {
+				periods[last].add(counters)
+				continue
+			}
+			periods = append(periods, StatsPeriod{Start: item.Day, StatsCounters: counters})
+		}
+		if len(out.LastEvaluatedKey) == 0 {
+			return periods, nil
+		}
+		startKey = out.LastEvaluatedKey
+	}
+}
+
+// transact runs a transaction, retrying cancellations caused only by transaction conflicts.
+func (s *DynamoDBStorage) transact(ctx context.Context, items []types.TransactWriteItem) error {
+	for attempt := 1; ; attempt++ {
+		_, err := s.client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{TransactItems: items})
+		if !isTransactionConflict(err) {
+			return err
+		}
+		if attempt == maxTransactionAttempts {
+			return errConflict
+		}
+		time.Sleep(time.Duration(attempt*10+mathrand.Intn(20)) * time.Millisecond)
+	}
+}
+
+func (s *DynamoDBStorage) countByType(ctx context.Context, itemType string) (int, error) {
+	total := 0
+	var startKey map[string]types.AttributeValue
+	for {
+		out, err := s.client.Scan(ctx, &dynamodb.ScanInput{
+			TableName:        &s.Table,
+			Select:           types.SelectCount,
+			FilterExpression: awsString("#t = :type"),
+			ExpressionAttributeNames: map[string]string{
+				"#t": "type",
+			},
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":type": &types.AttributeValueMemberS{Value: itemType},
+			},
+			ExclusiveStartKey: startKey,
+		})
+		if err != nil {
+			return 0, err
+		}
+		total += int(out.Count)
+		if len(out.LastEvaluatedKey) == 0 {
+			break
+		}
+		startKey = out.LastEvaluatedKey
+	}
+	return total, nil
+}
+
+// UpdatePollDays writes the days with the creator and first responses, then the rest.
+func (s *DynamoDBStorage) UpdatePollDays(ctx context.Context, pollID string, version int, days []string, slots []TimeSlot, expiresAt time.Time, responses []Response) error {
+	slotsAttr, err := attributevalue.Marshal(slots)
+	if err != nil {
+		return err
+	}
+	expires := optionalUnix(expiresAt)
+	puts := make([]types.TransactWriteItem, 0, len(responses))
+	for _, response := range responses {
+		put, err := s.responsePut(pollID, response, false, expires)
+		if err != nil {
+			return err
+		}
+		puts = append(puts, put)
+	}
+
+	first := min(len(puts), maxTransactionItems-1)
+	if err := s.transactPollUpdate(ctx, s.pollDaysUpdate(pollID, version, days, slotsAttr, expires), puts[:first]); err != nil {
+		return err
+	}
+	pruneErr := s.putOverflow(ctx, pollID, puts[first:])
+	if err := s.expireResponses(ctx, pollID, expires); err != nil && pruneErr == nil {
+		pruneErr = err
+	}
+	return pruneErr
+}
+
+// transactPollUpdate writes a versioned poll update and response puts in one transaction.
+func (s *DynamoDBStorage) transactPollUpdate(ctx context.Context, pollUpdate types.TransactWriteItem, puts []types.TransactWriteItem) error {
+	items := append([]types.TransactWriteItem{pollUpdate}, puts...)
+	err := s.transact(ctx, items)
+	if transactionConditionFailed(err, 0) {
+		if reason := cancellationReason(err, 0); reason == nil || len(reason.Item) == 0 {
+			return errNotFound
+		}
+		return errConflict
+	}
+	for i := 1; i < len(items); i++ {
+		if transactionConditionFailed(err, i) {
+			return errConflict
+		}
+	}
+	return err
+}
+
+// putOverflow writes the remaining response puts 100 per transaction and returns the first failure.
+func (s *DynamoDBStorage) putOverflow(ctx context.Context, pollID string, puts []types.TransactWriteItem) error {
+	var pruneErr error
+	for start := 0; start < len(puts); start += maxTransactionItems {
+		end := min(start+maxTransactionItems, len(puts))
+		err := s.transact(ctx, puts[start:end])
+		for i := 0; i < end-start && err != nil; i++ {
+			if transactionConditionFailed(err, i) {
+				err = errConflict
+			}
+		}
+		if err != nil && pruneErr == nil {
+			pruneErr = fmt.Errorf("update overflow responses %d-%d for poll %s: %w", start, end, pollID, err)
+		}
+	}
+	return pruneErr
+}
+
+func (s *DynamoDBStorage) pollDaysUpdate(pollID string, version int, days []string, slots types.AttributeValue, expiresAt int64) types.TransactWriteItem {
+	values := versionAttributeValues(version)
+	values[":days"] = &types.AttributeValueMemberL{Value: stringSliceAttribute(days)}
+	values[":slots"] = slots
+	values[":next"] = &types.AttributeValueMemberN{Value: fmt.Sprint(version + 1)}
+	names := versionAttributeNames()
+	names["#days"] = "days"
+	names["#slots"] = "slots"
+	expression := "SET #days = :days, #slots = :slots, #version = :next"
+	if expiresAt == 0 {
+		expression += " REMOVE expires_at"
+	} else {
+		expression += ", expires_at = :expires"
+		values[":expires"] = &types.AttributeValueMemberN{Value: strconv.FormatInt(expiresAt, 10)}
+	}
+	return types.TransactWriteItem{Update: &types.Update{
+		TableName: &s.Table,
+		Key: map[string]types.AttributeValue{
+			"pk": &types.AttributeValueMemberS{Value: pollPartitionKey(pollID)},
+			"sk": &types.AttributeValueMemberS{Value: "POLL"},
+		},
+		UpdateExpression:                    awsString(expression),
+		ConditionExpression:                 awsString("attribute_exists(pk) AND " + versionCondition(version)),
+		ExpressionAttributeNames:            names,
+		ExpressionAttributeValues:           values,
+		ReturnValuesOnConditionCheckFailure: types.ReturnValuesOnConditionCheckFailureAllOld,
+	}}
+}
+
+// UpdatePollVenues writes the venues with the responses whose votes were dropped.
+func (s *DynamoDBStorage) UpdatePollVenues(ctx context.Context, pollID string, version int, venues []Venue, responses []Response) error {
 	venuesAttr, err := attributevalue.Marshal(venues)
 	if err != nil {
 		return err
 	}
-	_, err = s.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+	if len(responses) == 0 {
+		return s.updatePollAttribute(ctx, pollID, version, "venues", venuesAttr)
+	}
+	// The version condition fails if the expiry changed after this read.
+	expiresAt, err := s.pollExpiresAt(ctx, pollID)
+	if err != nil {
+		return err
+	}
+	puts := make([]types.TransactWriteItem, 0, len(responses))
+	for _, response := range responses {
+		put, err := s.responsePut(pollID, response, false, expiresAt)
+		if err != nil {
+			return err
+		}
+		puts = append(puts, put)
+	}
+	first := min(len(puts), maxTransactionItems-1)
+	pollUpdate := types.TransactWriteItem{Update: s.pollAttributesUpdate(pollID, version, map[string]types.AttributeValue{"venues": venuesAttr})}
+	if err := s.transactPollUpdate(ctx, pollUpdate, puts[:first]); err != nil {
+		return err
+	}
+	return s.putOverflow(ctx, pollID, puts[first:])
+}
+
+func (s *DynamoDBStorage) UpdatePollSettings(ctx context.Context, pollID string, version int, settings PollSettings) error {
+	requiredAttr, err := attributevalue.Marshal(settings.RequiredAttendees)
+	if err != nil {
+		return err
+	}
+	return s.updatePollAttributes(ctx, pollID, version, map[string]types.AttributeValue{
+		"required_attendees": requiredAttr,
+		"quorum":             &types.AttributeValueMemberN{Value: strconv.Itoa(settings.Quorum)},
+		"trip_length":        &types.AttributeValueMemberN{Value: strconv.Itoa(settings.TripLength)},
+		"closed_at":          &types.AttributeValueMemberS{Value: formatOptionalTime(settings.ClosedAt)},
+		"deadline":           &types.AttributeValueMemberS{Value: formatOptionalTime(settings.Deadline)},
+		"final_day":          &types.AttributeValueMemberS{Value: settings.FinalDay},
+		"final_venue_id":     &types.AttributeValueMemberS{Value: settings.FinalVenueID},
+		"time_zone":          &types.AttributeValueMemberS{Value: settings.TimeZone},
+	})
+}
+
+func (s *DynamoDBStorage) UpdatePollCredentials(ctx context.Context, pollID string, version int, credentials PollCredentials, creatorResponse *Response) error {
+	names := versionAttributeNames()
+	values := versionAttributeValues(version)
+	values[":creator"] = &types.AttributeValueMemberS{Value: credentials.CreatorToken}
+	values[":admin"] = &types.AttributeValueMemberS{Value: credentials.AdminToken}
+	values[":recovery"] = &types.AttributeValueMemberS{Value: credentials.RecoveryHash}
+	values[":next"] = &types.AttributeValueMemberN{Value: fmt.Sprint(version + 1)}
+	items := []types.TransactWriteItem{{Update: &types.Update{
 		TableName: &s.Table,
 		Key: map[string]types.AttributeValue{
 			"pk": &types.AttributeValueMemberS{Value: pollPartitionKey(pollID)},
 			"sk": &types.AttributeValueMemberS{Value: "POLL"},
 		},
-		UpdateExpression: awsString("SET venues = :venues"),
-		ExpressionAttributeValues: map[string]types.AttributeValue{
-			":venues": venuesAttr,
+		UpdateExpression:                    awsString("SET creator_token = :creator, admin_token = :admin, recovery_hash = :recovery, #version = :next"),
+		ConditionExpression:                 awsString("attribute_exists(pk) AND " + versionCondition(version)),
+		ExpressionAttributeNames:            names,
+		ExpressionAttributeValues:           values,
+		ReturnValuesOnConditionCheckFailure: types.ReturnValuesOnConditionCheckFailureAllOld,
+	}}}
+	if creatorResponse != nil {
+		expiresAt, err := s.pollExpiresAt(ctx, pollID)
+		if err != nil {
+			return err
+		}
+		put, err := s.responsePut(pollID, *creatorResponse, false, expiresAt)
+		if err != nil {
+			return err
+		}
+		items = append(items, put)
+	}
+	err := s.transact(ctx, items)
+	if transactionConditionFailed(err, 0) {
+		if reason := cancellationReason(err, 0); reason == nil || len(reason.Item) == 0 {
+			return errNotFound
+		}
+		return errConflict
+	}
+	if transactionConditionFailed(err, 1) {
+		return errConflict
+	}
+	return err
+}
+
+func (s *DynamoDBStorage) ArchivePoll(ctx context.Context, pollID string, version int, archivedAt time.Time) error {
+	return s.updatePollAttribute(ctx, pollID, version, "archived_at", &types.AttributeValueMemberS{Value: formatOptionalTime(archivedAt)})
+}
+
+// expireResponses copies the poll's expires_at onto every response.
+func (s *DynamoDBStorage) expireResponses(ctx context.Context, pollID string, expiresAt int64) error {
+	items, err := s.responseExpiries(ctx, pollID)
+	if err != nil {
+		return err
+	}
+	updates := make([]types.TransactWriteItem, 0, len(items))
+	for _, item := range items {
+		if itemExpiresAt(item) == expiresAt {
+			continue
+		}
+		update := &types.Update{
+			TableName:           &s.Table,
+			Key:                 map[string]types.AttributeValue{"pk": item["pk"], "sk": item["sk"]},
+			UpdateExpression:    awsString("REMOVE expires_at"),
+			ConditionExpression: awsString("attribute_exists(pk)"),
+		}
+		if expiresAt != 0 {
+			update.UpdateExpression = awsString("SET expires_at = :expires")
+			update.ExpressionAttributeValues = map[string]types.AttributeValue{
+				":expires": &types.AttributeValueMemberN{Value: strconv.FormatInt(expiresAt, 10)},
+			}
+		}
+		updates = append(updates, types.TransactWriteItem{Update: update})
+	}
+	var expireErr error
+	for start := 0; start < len(updates); start += maxTransactionItems {
+		end := min(start+maxTransactionItems, len(updates))
+		if err := s.transact(ctx, updates[start:end]); err != nil && expireErr == nil {
+			expireErr = fmt.Errorf("expire responses %d-%d for poll %s: %w", start, end, pollID, err)
+		}
+	}
+	return expireErr
+}
+
+func (s *DynamoDBStorage) responseExpiries(ctx context.Context, pollID string) ([]map[string]types.AttributeValue, error) {
+	var items []map[string]types.AttributeValue
+	var startKey map[string]types.AttributeValue
+	for {
+		out, err := s.client.Query(ctx, &dynamodb.QueryInput{
+			TableName:              &s.Table,
+			KeyConditionExpression: awsString("pk = :pk AND begins_with(sk, :prefix)"),
+			ProjectionExpression:   awsString("pk, sk, expires_at"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":pk":     &types.AttributeValueMemberS{Value: pollPartitionKey(pollID)},
+				":prefix": &types.AttributeValueMemberS{Value: "RESP#"},
+			},
+			ExclusiveStartKey: startKey,
+		})
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, out.Items...)
+		if len(out.LastEvaluatedKey) == 0 {
+			return items, nil
+		}
+		startKey = out.LastEvaluatedKey
+	}
+}
+
+// pollExpiresAt reads the poll's expires_at; zero means it never expires.
+func (s *DynamoDBStorage) pollExpiresAt(ctx context.Context, pollID string) (int64, error) {
+	out, err := s.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: &s.Table,
+		Key: map[string]types.AttributeValue{
+			"pk": &types.AttributeValueMemberS{Value: pollPartitionKey(pollID)},
+			"sk": &types.AttributeValueMemberS{Value: "POLL"},
 		},
+		ProjectionExpression: awsString("pk, expires_at"),
+		ConsistentRead:       awsBool(true),
+	})
+	if err != nil {
+		return 0, err
+	}
+	if len(out.Item) == 0 {
+		return 0, errNotFound
+	}
+	return itemExpiresAt(out.Item), nil
+}
+
+func itemExpiresAt(item map[string]types.AttributeValue) int64 {
+	var holder struct {
+		ExpiresAt int64 `dynamodbav:"expires_at"`
+	}
+	if err := attributevalue.UnmarshalMap(item, &holder); err != nil {
+		return 0
+	}
+	return holder.ExpiresAt
+}
+
+// expiryCondition checks that the poll's expires_at is still expiresAt.
+func expiryCondition(expiresAt int64, values map[string]types.AttributeValue) string {
+	if expiresAt == 0 {
+		return "attribute_not_exists(expires_at)"
+	}
+	values[":expires"] = &types.AttributeValueMemberN{Value: strconv.FormatInt(expiresAt, 10)}
+	return "expires_at = :expires"
+}
+
+func (s *DynamoDBStorage) updatePollAttribute(ctx context.Context, pollID string, version int, name string, value types.AttributeValue) error {
+	return s.updatePollAttributes(ctx, pollID, version, map[string]types.AttributeValue{name: value})
+}
+
+func (s *DynamoDBStorage) updatePollAttributes(ctx context.Context, pollID string, version int, attributes map[string]types.AttributeValue) error {
+	update := s.pollAttributesUpdate(pollID, version, attributes)
+	_, err := s.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:                           update.TableName,
+		Key:                                 update.Key,
+		UpdateExpression:                    update.UpdateExpression,
+		ConditionExpression:                 update.ConditionExpression,
+		ExpressionAttributeNames:            update.ExpressionAttributeNames,
+		ExpressionAttributeValues:           update.ExpressionAttributeValues,
+		ReturnValuesOnConditionCheckFailure: update.ReturnValuesOnConditionCheckFailure,
 	})
+	var conditionErr *types.ConditionalCheckFailedException
+	if errors.As(err, &conditionErr) {
+		if len(conditionErr.Item) == 0 {
+			return errNotFound
+		}
+		return errConflict
+	}
 	return err
 }
 
-func (s *DynamoDBStorage) DeleteResponse(ctx context.Context, pollID string, responseID string) error {
-	_, err := s.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+func (s *DynamoDBStorage) pollAttributesUpdate(pollID string, version int, attributes map[string]types.AttributeValue) *types.Update {
+	names := versionAttributeNames()
+	values := versionAttributeValues(version)
+	values[":next"] = &types.AttributeValueMemberN{Value: fmt.Sprint(version + 1)}
+	attrNames := make([]string, 0, len(attributes))
+	for name := range attributes {
+		attrNames = append(attrNames, name)
+	}
+	sort.Strings(attrNames)
+	sets := make([]string, 0, len(attributes)+1)
+	for i, name := range attrNames {
+		names[fmt.Sprintf("#attr%d", i)] = name
+		values[fmt.Sprintf(":value%d", i)] = attributes[name]
+		sets = append(sets, fmt.Sprintf("#attr%d = :value%d", i, i))
+	}
+	sets = append(sets, "#version = :next")
+	return &types.Update{
 		TableName: &s.Table,
 		Key: map[string]types.AttributeValue{
 			"pk": &types.AttributeValueMemberS{Value: pollPartitionKey(pollID)},
-			"sk": &types.AttributeValueMemberS{Value: "RESP#" + responseID},
+			"sk": &types.AttributeValueMemberS{Value: "POLL"},
 		},
-	})
+		UpdateExpression:                    awsString("SET " + strings.Join(sets, ", ")),
+		ConditionExpression:                 awsString("attribute_exists(pk) AND " + versionCondition(version)),
+		ExpressionAttributeNames:            names,
+		ExpressionAttributeValues:           values,
+		ReturnValuesOnConditionCheckFailure: types.ReturnValuesOnConditionCheckFailureAllOld,
+	}
+}
+
+func (s *DynamoDBStorage) DeleteResponse(ctx context.Context, pollID string, responseID string) error {
+	err := s.transact(ctx, append([]types.TransactWriteItem{
+		s.pollExistsCheck(pollID),
+		{Delete: &types.Delete{
+			TableName: &s.Table,
+			Key: map[string]types.AttributeValue{
+				"pk": &types.AttributeValueMemberS{Value: pollPartitionKey(pollID)},
+				"sk": &types.AttributeValueMemberS{Value: "RESP#" + responseID},
+			},
+			ConditionExpression: awsString("attribute_exists(pk)"),
+		}},
+	}, s.statsUpdates(statsChange{total: StatsCounters{Responses: -1}}.with(time.Now(), StatsCounters{Deletions: 1}))...))
+	if transactionConditionFailed(err, 0) {
+		return errNotFound
+	}
+	if transactionConditionFailed(err, 1) {
+		return nil
+	}
 	return err
 }
 
+func (s *DynamoDBStorage) pollExpiryCheck(pollID string, expiresAt int64) types.TransactWriteItem {
+	values := map[string]types.AttributeValue{}
+	condition := "attribute_exists(pk) AND " + expiryCondition(expiresAt, values)
+	if len(values) == 0 {
+		values = nil
+	}
+	return types.TransactWriteItem{
+		ConditionCheck: &types.ConditionCheck{
+			TableName: &s.Table,
+			Key: map[string]types.AttributeValue{
+				"pk": &types.AttributeValueMemberS{Value: pollPartitionKey(pollID)},
+				"sk": &types.AttributeValueMemberS{Value: "POLL"},
+			},
+			ConditionExpression:                 awsString(condition),
+			ExpressionAttributeValues:           values,
+			ReturnValuesOnConditionCheckFailure: types.ReturnValuesOnConditionCheckFailureAllOld,
+		},
+	}
+}
+
+func (s *DynamoDBStorage) pollExistsCheck(pollID string) types.TransactWriteItem {
+	return types.TransactWriteItem{
+		ConditionCheck: &types.ConditionCheck{
+			TableName: &s.Table,
+			Key: map[string]types.AttributeValue{
+				"pk": &types.AttributeValueMemberS{Value: pollPartitionKey(pollID)},
+				"sk": &types.AttributeValueMemberS{Value: "POLL"},
+			},
+			ConditionExpression: awsString("attribute_exists(pk)"),
+		},
+	}
+}
+
+func newMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{
+		polls:     make(map[string]Poll),
+		responses: make(map[string][]Response),
+	}
+}
+
 func (s *MemoryStorage) CreatePoll(ctx context.Context, poll Poll) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	if _, exists := s.polls[poll.ID]; exists {
 		return errConflict
 	}
-	s.polls[poll.ID] = poll
+	s.polls[poll.ID] = clonePoll(poll)
+	s.recordStats(poll.CreatedAt, pollCounters(poll))
 	return nil
 }
 
 func (s *MemoryStorage) GetPoll(ctx context.Context, pollID string) (Poll, []Response, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 	poll, ok := s.polls[pollID]
 	if !ok {
 		return Poll{}, nil, errNotFound
 	}
-	responses := append([]Response(nil), s.responses[pollID]...)
+	responses := cloneResponses(s.responses[pollID])
 	sort.Slice(responses, func(i, j int) bool {
 		return responses[i].CreatedAt.Before(responses[j].CreatedAt)
 	})
-	return poll, responses, nil
+	return clonePoll(poll), responses, nil
 }
 
 func (s *MemoryStorage) AddResponse(ctx context.Context, pollID string, response Response) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	if _, ok := s.polls[pollID]; !ok {
 		return errNotFound
 	}
+	return s.putResponse(pollID, response)
+}
+
+func (s *MemoryStorage) putResponse(pollID string, response Response) error {
+	response = cloneResponse(response)
 	responses := s.responses[pollID]
 	for i := range responses {
 		if responses[i].ID == response.ID {
+			if responses[i].Version != response.Version {
+				return errConflict
+			}
+			response.Version++
 			responses[i] = response
-			s.responses[pollID] = responses
 			return nil
 		}
 	}
+	if response.Version != 0 {
+		return errConflict
+	}
+	response.Version = 1
 	s.responses[pollID] = append(responses, response)
+	s.recordStats(response.CreatedAt, StatsCounters{Responses: 1})
 	return nil
 }
 
-func (s *MemoryStorage) UpdatePollDays(ctx context.Context, pollID string, days []string) error {
-	poll, ok := s.polls[pollID]
-	if !ok {
-		return errNotFound
-	}
-	poll.Days = days
-	s.polls[pollID] = poll
-	return nil
+func (s *MemoryStorage) UpdatePollDays(ctx context.Context, pollID string, version int, days []string, slots []TimeSlot, expiresAt time.Time, responses []Response) error {
+	return s.updatePollWithResponses(pollID, version, responses, func(poll *Poll) {
+		poll.Days = cloneStrings(days)
+		poll.Slots = cloneSlots(slots)
+		poll.ExpiresAt = expiresAt
+	})
 }
 
-func (s *MemoryStorage) UpdatePollVenues(ctx context.Context, pollID string, venues []Venue) error {
+// updatePollWithResponses applies a versioned poll update and rewrites responses, all or nothing.
+func (s *MemoryStorage) updatePollWithResponses(pollID string, version int, responses []Response, apply func(poll *Poll)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	poll, ok := s.polls[pollID]
 	if !ok {
 		return errNotFound
 	}
-	poll.Venues = venues
-	s.polls[pollID] = poll
-	return nil
-}
-
-func (s *MemoryStorage) DeleteResponse(ctx context.Context, pollID string, responseID string) error {
-	if _, ok := s.polls[pollID]; !ok {
-		return errNotFound
+	if poll.Version != version {
+		return errConflict
 	}
-	responses := s.responses[pollID]
-	for i := range responses {
-		if responses[i].ID == responseID {
-			s.responses[pollID] = append(responses[:i], responses[i+1:]...)
-			return nil
+	stored := s.responses[pollID]
+	indexByID := make(map[string]int, len(stored))
+	for i, response := range stored {
+		indexByID[response.ID] = i
+	}
+	for _, response := range responses {
+		i, ok := indexByID[response.ID]
+		if !ok || stored[i].Version != response.Version {
+			return errConflict
+		}
+	}
+
+	updated := cloneResponses(stored)
+	for _, response := range responses {
+		response = cloneResponse(response)
+		response.Version++
+		updated[indexByID[response.ID]] = response
+	}
+	s.responses[pollID] = updated
+	apply(&poll)
+	poll.Version++
+	s.polls[pollID] = poll
+	return nil
+}
+
+func (s *MemoryStorage) UpdatePollVenues(ctx context.Context, pollID string, version int, venues []Venue, responses []Response) error {
+	return s.updatePollWithResponses(pollID, version, responses, func(poll *Poll) {
+		poll.Venues = cloneVenues(venues)
+	})
+}
+
+func (s *MemoryStorage) UpdatePollSettings(ctx context.Context, pollID string, version int, settings PollSettings) error {
+	return s.updatePoll(pollID, version, func(poll *Poll) {
+		poll.RequiredAttendees = cloneStrings(settings.RequiredAttendees)
+		poll.Quorum = settings.Quorum
+		poll.TripLength = settings.TripLength
+		poll.ClosedAt = settings.ClosedAt
+		poll.Deadline = settings.Deadline
+		poll.FinalDay = settings.FinalDay
+		poll.FinalVenueID = settings.FinalVenueID
+		poll.TimeZone = settings.TimeZone
+	})
+}
+
+func (s *MemoryStorage) ArchivePoll(ctx context.Context, pollID string, version int, archivedAt time.Time) error {
+	return s.updatePoll(pollID, version, func(poll *Poll) {
+		poll.ArchivedAt = archivedAt
+	})
+}
+
+// SweepPolls archives ended polls and deletes expired ones with their responses.
+func (s *MemoryStorage) SweepPolls(ctx context.Context, now time.Time) (int, int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	archived, deleted := 0, 0
+	for id, poll := range s.polls {
+		if !poll.ExpiresAt.IsZero() && !now.Before(poll.ExpiresAt) {
+			delete(s.polls, id)
+			delete(s.responses, id)
+			deleted++
+			continue
+		}
+		if poll.ArchivedAt.IsZero() && pollEnded(poll, now) {
+			poll.ArchivedAt = now.UTC()
+			poll.Version++
+			s.polls[id] = poll
+			archived++
+		}
+	}
+	return archived, deleted, nil
+}
+
+func (s *MemoryStorage) UpdatePollCredentials(ctx context.Context, pollID string, version int, credentials PollCredentials, creatorResponse *Response) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	poll, ok := s.polls[pollID]
+	if !ok {
+		return errNotFound
+	}
+	if poll.Version != version {
+		return errConflict
+	}
+	if creatorResponse != nil {
+		found := false
+		for _, response := range s.responses[pollID] {
+			if response.ID == creatorResponse.ID {
+				found = response.Version == creatorResponse.Version
+			}
+		}
+		if !found {
+			return errConflict
+		}
+		if err := s.putResponse(pollID, *creatorResponse); err != nil {
+			return err
+		}
+	}
+	poll.CreatorToken = credentials.CreatorToken
+	poll.AdminToken = credentials.AdminToken
+	poll.RecoveryHash = credentials.RecoveryHash
+	poll.Version++
+	s.polls[pollID] = poll
+	return nil
+}
+
+func (s *MemoryStorage) AddVenueWriteIn(ctx context.Context, pollID string, venue Venue, response Response) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	poll, ok := s.polls[pollID]
+	if !ok {
+		return errNotFound
+	}
+	if err := s.putResponse(pollID, response); err != nil {
+		return err
+	}
+	poll.Venues = append(cloneVenues(poll.Venues), venue)
+	poll.Version++
+	s.polls[pollID] = poll
+	s.writeIns++
+	s.recordStats(time.Now(), StatsCounters{WriteIns: 1})
+	return nil
+}
+
+func (s *MemoryStorage) updatePoll(pollID string, version int, apply func(poll *Poll)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	poll, ok := s.polls[pollID]
+	if !ok {
+		return errNotFound
+	}
+	if poll.Version != version {
+		return errConflict
+	}
+	apply(&poll)
+	poll.Version++
+	s.polls[pollID] = poll
+	return nil
+}
+
+func (s *MemoryStorage) DeleteResponse(ctx context.Context, pollID string, responseID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.polls[pollID]; !ok {
+		return errNotFound
+	}
+	responses := s.responses[pollID]
+	for i := range responses {
+		if responses[i].ID == responseID {
+			remaining := make([]Response, 0, len(responses)-1)
+			remaining = append(remaining, responses[:i]...)
+			s.responses[pollID] = append(remaining, responses[i+1:]...)
+			s.deletions++
+			s.recordStats(time.Now(), StatsCounters{Deletions: 1})
+			return nil
 		}
 	}
 	return nil
 }
 
 func (s *MemoryStorage) GetStats(ctx context.Context) (Stats, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 	responseCount := 0
 	for _, responses := range s.responses {
 		responseCount += len(responses)
@@ -491,362 +1929,1683 @@ func (s *MemoryStorage) GetStats(ctx context.Context) (Stats, error) {
 	return Stats{
 		PollCount:     len(s.polls),
 		ResponseCount: responseCount,
+		WriteInCount:  s.writeIns,
+		DeletionCount: s.deletions,
 	}, nil
 }
 
+func (s *MemoryStorage) GetStatsSeries(ctx context.Context, from string, to string) ([]StatsPeriod, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var periods []StatsPeriod
+	for day, counters := range s.daily {
+		if day >= from && day <= to {
+			periods = append(periods, StatsPeriod{Start: day, StatsCounters: counters})
+		}
+	}
+	sort.Slice(periods, func(i, j int) bool {
+		return periods[i].Start < periods[j].Start
+	})
+	return periods, nil
+}
+
+func (s *MemoryStorage) recordStats(at time.Time, counters StatsCounters) {
+	if s.daily == nil {
+		s.daily = make(map[string]StatsCounters)
+	}
+	day := at.UTC().Format("2006-01-02")
+	dayCounters := s.daily[day]
+	dayCounters.add(counters)
+	s.daily[day] = dayCounters
+}
+
+func (s *MemoryStorage) snapshot() fileSnapshot {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	snapshot := fileSnapshot{
+		Polls:     make([]Poll, 0, len(s.polls)),
+		Responses: make(map[string][]Response, len(s.responses)),
+		WriteIns:  s.writeIns,
+		Deletions: s.deletions,
+	}
+	if len(s.daily) > 0 {
+		snapshot.Daily = make(map[string]StatsCounters, len(s.daily))
+		for day, counters := range s.daily {
+			snapshot.Daily[day] = counters
+		}
+	}
+	for _, poll := range s.polls {
+		snapshot.Polls = append(snapshot.Polls, clonePoll(poll))
+	}
+	sort.Slice(snapshot.Polls, func(i, j int) bool {
+		return snapshot.Polls[i].ID < snapshot.Polls[j].ID
+	})
+	for pollID, responses := range s.responses {
+		if len(responses) == 0 {
+			continue
+		}
+		snapshot.Responses[pollID] = cloneResponses(responses)
+	}
+	return snapshot
+}
+
+func (s *MemoryStorage) restore(snapshot fileSnapshot) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.polls = make(map[string]Poll, len(snapshot.Polls))
+	s.responses = make(map[string][]Response, len(snapshot.Responses))
+	s.writeIns = snapshot.WriteIns
+	s.deletions = snapshot.Deletions
+	s.daily = make(map[string]StatsCounters, len(snapshot.Daily))
+	for day, counters := range snapshot.Daily {
+		s.daily[day] = counters
+	}
+	for _, poll := range snapshot.Polls {
+		s.polls[poll.ID] = clonePoll(poll)
+	}
+	for pollID, responses := range snapshot.Responses {
+		s.responses[pollID] = cloneResponses(responses)
+	}
+}
+
+// newFileStorage loads the data file at path, starting empty if it does not exist.
+func newFileStorage(path string) (*FileStorage, error) {
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, err
+		}
+	}
+	storage := &FileStorage{
+		path:   path,
+		memory: newMemoryStorage(),
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return storage, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var snapshot fileSnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil, fmt.Errorf("read %s: %w", path, err)
+	}
+	storage.memory.restore(snapshot)
+	return storage, nil
+}
+
+func (s *FileStorage) CreatePoll(ctx context.Context, poll Poll) error {
+	return s.update(func(memory *MemoryStorage) error {
+		return memory.CreatePoll(ctx, poll)
+	})
+}
+
+func (s *FileStorage) GetPoll(ctx context.Context, pollID string) (Poll, []Response, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.memory.GetPoll(ctx, pollID)
+}
+
+func (s *FileStorage) AddResponse(ctx context.Context, pollID string, response Response) error {
+	return s.update(func(memory *MemoryStorage) error {
+		return memory.AddResponse(ctx, pollID, response)
+	})
+}
+
+func (s *FileStorage) UpdatePollDays(ctx context.Context, pollID string, version int, days []string, slots []TimeSlot, expiresAt time.Time, responses []Response) error {
+	return s.update(func(memory *MemoryStorage) error {
+		return memory.UpdatePollDays(ctx, pollID, version, days, slots, expiresAt, responses)
+	})
+}
+
+func (s *FileStorage) UpdatePollVenues(ctx context.Context, pollID string, version int, venues []Venue, responses []Response) error {
+	return s.update(func(memory *MemoryStorage) error {
+		return memory.UpdatePollVenues(ctx, pollID, version, venues, responses)
+	})
+}
+
+func (s *FileStorage) UpdatePollSettings(ctx context.Context, pollID string, version int, settings PollSettings) error {
+	return s.update(func(memory *MemoryStorage) error {
+		return memory.UpdatePollSettings(ctx, pollID, version, settings)
+	})
+}
+
+func (s *FileStorage) ArchivePoll(ctx context.Context, pollID string, version int, archivedAt time.Time) error {
+	return s.update(func(memory *MemoryStorage) error {
+		return memory.ArchivePoll(ctx, pollID, version, archivedAt)
+	})
+}
+
+func (s *FileStorage) SweepPolls(ctx context.Context, now time.Time) (int, int, error) {
+	archived, deleted := 0, 0
+	err := s.update(func(memory *MemoryStorage) error {
+		var err error
+		archived, deleted, err = memory.SweepPolls(ctx, now)
+		return err
+	})
+	return archived, deleted, err
+}
+
+func (s *FileStorage) UpdatePollCredentials(ctx context.Context, pollID string, version int, credentials PollCredentials, creatorResponse *Response) error {
+	return s.update(func(memory *MemoryStorage) error {
+		return memory.UpdatePollCredentials(ctx, pollID, version, credentials, creatorResponse)
+	})
+}
+
+func (s *FileStorage) AddVenueWriteIn(ctx context.Context, pollID string, venue Venue, response Response) error {
+	return s.update(func(memory *MemoryStorage) error {
+		return memory.AddVenueWriteIn(ctx, pollID, venue, response)
+	})
+}
+
+func (s *FileStorage) DeleteResponse(ctx context.Context, pollID string, responseID string) error {
+	return s.update(func(memory *MemoryStorage) error {
+		return memory.DeleteResponse(ctx, pollID, responseID)
+	})
+}
+
+func (s *FileStorage) GetStats(ctx context.Context) (Stats, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.memory.GetStats(ctx)
+}
+
+func (s *FileStorage) GetStatsSeries(ctx context.Context, from string, to string) ([]StatsPeriod, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.memory.GetStatsSeries(ctx, from, to)
+}
+
+// update applies fn and writes the file, rolling back if the write fails.
+func (s *FileStorage) update(fn func(memory *MemoryStorage) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	previous := s.memory.snapshot()
+	if err := fn(s.memory); err != nil {
+		return err
+	}
+	if err := writeFileAtomic(s.path, s.memory.snapshot()); err != nil {
+		s.memory.restore(previous)
+		return err
+	}
+	return nil
+}
+
+func writeFileAtomic(path string, snapshot fileSnapshot) error {
+	data, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return err
+	}
+	dir := filepath.Dir(path)
+	tmp, err := os.CreateTemp(dir, filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+	defer os.Remove(tmpName)
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpName, path); err != nil {
+		return err
+	}
+	dirHandle, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer dirHandle.Close()
+	return dirHandle.Sync()
+}
+
 func (a *App) handleHome(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	data := struct {
-		Upcoming        []DayOption
-		Message         string
-		PlaceholderName string
-	}{
-		Upcoming:        upcomingDays(14),
-		Message:         homeMessage(r),
-		PlaceholderName: randomPlaceholderName(),
-	}
+	timeZone := timeZoneCookie(r)
+	location, _ := loadTimeZone(timeZone)
+	data := HomeView{
+		Months:          calendarMonths(time.Now().In(location), nil),
+		TimeZone:        timeZone,
+		Message:         homeMessage(r),
+		PlaceholderName: randomPlaceholderName(),
+		CSRFToken:       csrfToken(r),
+		Venues:          []Venue{{}},
+	}
+
+	a.render(w, "home.html", data)
+}
+
+func (a *App) handleCreatePoll(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid form", http.StatusBadRequest)
+		return
+	}
+
+	title := strings.TrimSpace(r.FormValue("title"))
+	creator := strings.TrimSpace(r.FormValue("creator"))
+	selectedDays := normalizeDays(r.Form["days"])
+	// The time zone comes from the browser; one Go does not know falls back to UTC.
+	timeZone := strings.TrimSpace(r.FormValue("time_zone"))
+	location, err := loadTimeZone(timeZone)
+	if err != nil {
+		timeZone, location = "", time.UTC
+	}
+	now := time.Now().In(location)
+	errs := FieldErrors{}
+	dateRange := dateRangeFromForm(r.Form, "range")
+	selectedDays = normalizeDays(append(selectedDays, expandDateRange(errs, "range", dateRange)...))
+	validateText(errs, "title", "Title", title, maxTitleLength)
+	validateText(errs, "creator", "Your name", creator, maxNameLength)
+	validatePollDays(errs, "days", selectedDays, nil, now)
+	venues, err := parseVenuesFromForm(
+		r.Form["venue_id"],
+		r.Form["venue_title"],
+		r.Form["venue_url"],
+		r.Form["venue_description"],
+		nil,
+	)
+	errs.merge(err)
+	if len(errs) > 0 {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		a.render(w, "home.html", HomeView{
+			Months:          calendarMonths(now, selectedDays),
+			Range:           dateRange,
+			TimeZone:        timeZone,
+			PlaceholderName: randomPlaceholderName(),
+			CSRFToken:       csrfToken(r),
+			Errors:          errs,
+			Title:           title,
+			Creator:         creator,
+			SelectedDays:    makeDaySet(selectedDays),
+			Venues:          pollEditVenues(venues),
+		})
+		return
+	}
+
+	creatorToken := randomID()
+	recoveryCode := newRecoveryCode()
+	poll := Poll{
+		ID:           randomID(),
+		Title:        title,
+		Days:         selectedDays,
+		Venues:       venues,
+		TimeZone:     timeZone,
+		CreatorToken: creatorToken,
+		AdminToken:   randomID(),
+		RecoveryHash: hashRecoveryCode(recoveryCode),
+		ExpiresAt:    pollExpiry(selectedDays, a.retention),
+		CreatedAt:    time.Now().UTC(),
+	}
+
+	if err := a.storage.CreatePoll(r.Context(), poll); err != nil {
+		log.Printf("failed to create poll: %v", err)
+		http.Error(w, "unable to create poll", http.StatusInternalServerError)
+		return
+	}
+
+	creatorResponse := Response{
+		ID:         randomID(),
+		Name:       creator,
+		Days:       selectedDays,
+		VenueVotes: nil,
+		UserToken:  creatorToken,
+		CreatedAt:  time.Now().UTC(),
+	}
+	if err := a.storage.AddResponse(r.Context(), poll.ID, creatorResponse); err != nil {
+		log.Printf("failed to add creator response: %v", err)
+		http.Error(w, "unable to create poll", http.StatusInternalServerError)
+		return
+	}
+
+	setUserTokenCookie(w, r, poll.ID, creatorToken)
+	setRecoveryCodeCookie(w, r, poll.ID, recoveryCode)
+	http.Redirect(w, r, pollManagePath(poll), http.StatusSeeOther)
+}
+
+func duplicatePoll(source Poll) Poll {
+	return Poll{
+		ID:           randomID(),
+		Title:        source.Title,
+		Days:         nil,
+		Slots:        cloneSlots(source.Slots),
+		Venues:       cloneVenues(source.Venues),
+		TimeZone:     source.TimeZone,
+		TripLength:   source.TripLength,
+		CreatorToken: randomID(),
+		AdminToken:   randomID(),
+		CreatedAt:    time.Now().UTC(),
+	}
+}
+
+func (a *App) handlePoll(w http.ResponseWriter, r *http.Request) {
+	pollID, userToken := parsePollPath(r.URL.Path)
+	if pollID == "" {
+		http.NotFound(w, r)
+		return
+	}
+	if adminToken := pollManageToken(r.URL.Path); adminToken != "" {
+		a.handleManagePoll(w, r, pollID, adminToken)
+		return
+	}
+	if r.URL.Path == "/poll/"+pollID+"/recover" {
+		a.handleRecoverPoll(w, r, pollID)
+		return
+	}
+	a.servePoll(w, r, pollID, userToken, "")
+}
+
+// handleManagePoll serves the creator's management URL.
+func (a *App) handleManagePoll(w http.ResponseWriter, r *http.Request, pollID string, adminToken string) {
+	poll, responses, err := a.storage.GetPoll(r.Context(), pollID)
+	if err != nil {
+		if errors.Is(err, errNotFound) {
+			http.Redirect(w, r, "/?invalid=1", http.StatusSeeOther)
+			return
+		}
+		log.Printf("failed to load poll: %v", err)
+		http.Error(w, "unable to load poll", http.StatusInternalServerError)
+		return
+	}
+	if !isPollAdmin(poll, adminToken) {
+		if organizer := findResponseByOrganizerToken(responses, adminToken); organizer != nil {
+			a.serveOrganizer(w, r, poll, responses, *organizer)
+			return
+		}
+		http.Redirect(w, r, "/?invalid=1", http.StatusSeeOther)
+		return
+	}
+	if r.Method != http.MethodGet {
+		a.servePoll(w, r, pollID, poll.CreatorToken, adminToken)
+		return
+	}
+	if poll.AdminToken == "" {
+		a.separateLegacyAdmin(w, r, poll)
+		return
+	}
+	setUserTokenCookie(w, r, pollID, poll.CreatorToken)
+	poll = a.archiveEndedPoll(r.Context(), poll, time.Now())
+	view := a.buildPollView(r, poll, responses, "", poll.CreatorToken, adminToken)
+	view.RecoveryCode = popRecoveryCode(w, r, pollID)
+	a.render(w, "poll.html", view)
+}
+
+// serveOrganizer serves a co-organizer's management URL.
+func (a *App) serveOrganizer(w http.ResponseWriter, r *http.Request, poll Poll, responses []Response, organizer Response) {
+	if r.Method != http.MethodGet {
+		a.servePoll(w, r, poll.ID, organizer.UserToken, organizer.OrganizerToken)
+		return
+	}
+	setUserTokenCookie(w, r, poll.ID, organizer.UserToken)
+	poll = a.archiveEndedPoll(r.Context(), poll, time.Now())
+	view := a.buildPollView(r, poll, responses, "", organizer.UserToken, organizer.OrganizerToken)
+	a.render(w, "poll.html", view)
+}
+
+// separateLegacyAdmin gives a legacy poll its own admin token and recovery code.
+func (a *App) separateLegacyAdmin(w http.ResponseWriter, r *http.Request, poll Poll) {
+	recoveryCode := newRecoveryCode()
+	credentials := PollCredentials{
+		CreatorToken: poll.CreatorToken,
+		AdminToken:   randomID(),
+		RecoveryHash: hashRecoveryCode(recoveryCode),
+	}
+	err := a.storage.UpdatePollCredentials(r.Context(), poll.ID, poll.Version, credentials, nil)
+	if errors.Is(err, errConflict) {
+		// Someone else changed the poll first; reloading shows whatever it is now.
+		http.Redirect(w, r, r.URL.Path, http.StatusSeeOther)
+		return
+	}
+	if err != nil {
+		log.Printf("failed to separate admin token: %v", err)
+		http.Error(w, "unable to load poll", http.StatusInternalServerError)
+		return
+	}
+	poll.AdminToken = credentials.AdminToken
+	setUserTokenCookie(w, r, poll.ID, poll.CreatorToken)
+	setRecoveryCodeCookie(w, r, poll.ID, recoveryCode)
+	http.Redirect(w, r, pollManagePath(poll), http.StatusSeeOther)
+}
+
+// handleRecoverPoll rotates every creator secret when given the recovery code.
+func (a *App) handleRecoverPoll(w http.ResponseWriter, r *http.Request, pollID string) {
+	switch r.Method {
+	case http.MethodGet:
+		a.render(w, "recover.html", RecoverView{PollID: pollID, CSRFToken: csrfToken(r)})
+	case http.MethodPost:
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, "invalid form", http.StatusBadRequest)
+			return
+		}
+		poll, responses, err := a.storage.GetPoll(r.Context(), pollID)
+		if err != nil && !errors.Is(err, errNotFound) {
+			log.Printf("failed to load poll: %v", err)
+			http.Error(w, "unable to load poll", http.StatusInternalServerError)
+			return
+		}
+		if err != nil || !matchesRecoveryCode(poll, r.FormValue("code")) {
+			w.WriteHeader(http.StatusUnauthorized)
+			a.render(w, "recover.html", RecoverView{PollID: pollID, Error: "That recovery code doesn't match this poll.", CSRFToken: csrfToken(r)})
+			return
+		}
+		rotated, code, err := a.rotateCreatorCredentials(r.Context(), poll, responses, true)
+		if err != nil {
+			writeUpdateError(w, err, "failed to recover poll")
+			return
+		}
+		setUserTokenCookie(w, r, pollID, rotated.CreatorToken)
+		setRecoveryCodeCookie(w, r, pollID, code)
+		http.Redirect(w, r, pollManagePath(rotated), http.StatusSeeOther)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// rotateCreatorCredentials issues new creator secrets and moves the creator's response onto them.
+func (a *App) rotateCreatorCredentials(ctx context.Context, poll Poll, responses []Response, newRecovery bool) (Poll, string, error) {
+	credentials := PollCredentials{
+		CreatorToken: randomID(),
+		AdminToken:   randomID(),
+		RecoveryHash: poll.RecoveryHash,
+	}
+	code := ""
+	if newRecovery {
+		code = newRecoveryCode()
+		credentials.RecoveryHash = hashRecoveryCode(code)
+	}
+	var creatorResponse *Response
+	if existing := findResponseByToken(responses, poll.CreatorToken); existing != nil {
+		moved := cloneResponse(*existing)
+		moved.UserToken = credentials.CreatorToken
+		creatorResponse = &moved
+	}
+	if err := a.storage.UpdatePollCredentials(ctx, poll.ID, poll.Version, credentials, creatorResponse); err != nil {
+		return Poll{}, "", err
+	}
+	poll.CreatorToken = credentials.CreatorToken
+	poll.AdminToken = credentials.AdminToken
+	poll.RecoveryHash = credentials.RecoveryHash
+	return poll, code, nil
+}
+
+func (a *App) servePoll(w http.ResponseWriter, r *http.Request, pollID string, userToken string, adminToken string) {
+	switch r.Method {
+	case http.MethodGet:
+		if userToken == "" {
+			token := userTokenFromCookie(r, pollID)
+			if token == "" {
+				token = randomID()
+				setUserTokenCookie(w, r, pollID, token)
+			}
+			http.Redirect(w, r, fmt.Sprintf("/poll/%s/u/%s", pollID, token), http.StatusSeeOther)
+			return
+		}
+
+		if adminToken == "" {
+			setUserTokenCookie(w, r, pollID, userToken)
+		}
+		poll, responses, err := a.storage.GetPoll(r.Context(), pollID)
+		if err != nil {
+			if errors.Is(err, errNotFound) {
+				http.Redirect(w, r, "/?invalid=1", http.StatusSeeOther)
+				return
+			}
+			log.Printf("failed to load poll: %v", err)
+			http.Error(w, "unable to load poll", http.StatusInternalServerError)
+			return
+		}
+
+		if poll.AdminToken == "" && manageToken(poll, userToken, adminToken) != "" {
+			a.separateLegacyAdmin(w, r, poll)
+			return
+		}
+		poll = a.archiveEndedPoll(r.Context(), poll, time.Now())
+		view := a.buildPollView(r, poll, responses, "", userToken, manageToken(poll, userToken, adminToken))
+		a.render(w, "poll.html", view)
+	case http.MethodPost:
+		if userToken == "" {
+			http.Redirect(w, r, "/poll/"+pollID, http.StatusSeeOther)
+			return
+		}
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, "invalid form", http.StatusBadRequest)
+			return
+		}
+		poll, responses, err := a.storage.GetPoll(r.Context(), pollID)
+		if err != nil {
+			if errors.Is(err, errNotFound) {
+				http.Redirect(w, r, "/?invalid=1", http.StatusSeeOther)
+				return
+			}
+			log.Printf("failed to load poll: %v", err)
+			http.Error(w, "unable to load poll", http.StatusInternalServerError)
+			return
+		}
+
+		adminToken = manageToken(poll, userToken, adminToken)
+		pageURL := fmt.Sprintf("/poll/%s/u/%s", pollID, userToken)
+		if adminToken != "" {
+			pageURL = fmt.Sprintf("/poll/%s/manage/%s", pollID, adminToken)
+		}
+		// Duplicating is the one thing left to do with an archived poll.
+		archivedWrite := !poll.ArchivedAt.IsZero() && r.FormValue("action") != "duplicate-poll"
+		role := pollRoleFor(poll, responses, userToken, adminToken)
+		locked := pollClosed(poll, time.Now()) && role < roleCreator
+		if r.FormValue("action") != "" {
+			locked = pollClosedTo(poll, time.Now(), role)
+		}
+		if archivedWrite || locked {
+			view := a.buildPollView(r, poll, responses, "", userToken, adminToken)
+			a.renderPollErrors(w, r, http.StatusForbidden, view)
+			return
+		}
+		if action := r.FormValue("action"); action != "" {
+			required, known := actionRoles[action]
+			if !known {
+				http.Error(w, "unknown action", http.StatusBadRequest)
+				return
+			}
+			if role < required {
+				http.Error(w, "forbidden", http.StatusForbidden)
+				return
+			}
+			switch action {
+			case "delete-response":
+				responseID := strings.TrimSpace(r.FormValue("response_id"))
+				if responseID == "" {
+					http.Error(w, "missing response", http.StatusBadRequest)
+					return
+				}
+				target := findResponseByID(responses, responseID)
+				if target != nil && isCreator(poll, target.UserToken) && role < roleCreator {
+					http.Error(w, "only the creator can delete their own response", http.StatusForbidden)
+					return
+				}
+				if err := a.storage.DeleteResponse(r.Context(), pollID, responseID); err != nil {
+					log.Printf("failed to delete response: %v", err)
+					http.Error(w, "unable to delete response", http.StatusInternalServerError)
+					return
+				}
+				http.Redirect(w, r, pageURL, http.StatusSeeOther)
+				return
+			case "update-dates":
+				errs := FieldErrors{}
+				dateRange := dateRangeFromForm(r.Form, "range")
+				updatedDays := normalizeDays(append(r.Form["days"], expandDateRange(errs, "edit_range", dateRange)...))
+				validatePollDays(errs, "edit_days", updatedDays, poll.Days, pollNow(poll))
+				// Forms without slot rows leave the poll's slots alone.
+				updatedSlots := poll.Slots
+				if _, ok := r.Form["slot_name"]; ok {
+					slots, err := parseSlotsFromForm(r.Form["slot_id"], r.Form["slot_name"], r.Form["slot_start"], r.Form["slot_end"], poll.Slots)
+					errs.merge(err)
+					updatedSlots = slots
+				}
+				if poll.FinalDay != "" && !makeDaySet(pollOptions(updatedDays, updatedSlots))[poll.FinalDay] {
+					errs.add("edit_days", "Un-finalize the poll before removing its chosen day or time slot.")
+				}
+				if len(errs) > 0 {
+					view := a.buildPollView(r, poll, responses, "", userToken, adminToken)
+					view.Errors = errs
+					view.EditMonths = calendarMonths(pollNow(poll), updatedDays)
+					view.EditRange = dateRange
+					view.EditSlots = pollEditSlots(updatedSlots)
+					view.PollDaySet = makeDaySet(updatedDays)
+					a.renderPollErrors(w, r, http.StatusUnprocessableEntity, view)
+					return
+				}
+				changedResponses := responsesForUpdatedDays(poll, responses, updatedDays, updatedSlots)
+				if err := a.storage.UpdatePollDays(r.Context(), pollID, poll.Version, updatedDays, updatedSlots, pollExpiry(updatedDays, a.retention), changedResponses); err != nil {
+					writeUpdateError(w, err, "failed to update poll days")
+					return
+				}
+				http.Redirect(w, r, pageURL, http.StatusSeeOther)
+				return
+			case "update-venues":
+				existingByID := make(map[string]Venue, len(poll.Venues))
+				for _, venue := range poll.Venues {
+					existingByID[venue.ID] = venue
+				}
+				updatedVenues, err := parseVenuesFromForm(
+					r.Form["venue_id"],
+					r.Form["venue_title"],
+					r.Form["venue_url"],
+					r.Form["venue_description"],
+					existingByID,
+				)
+				if err == nil && poll.FinalVenueID != "" && findVenueByID(updatedVenues, poll.FinalVenueID) == nil {
+					err = FieldErrors{"venues": "Un-finalize the poll before removing its chosen venue/activity."}
+				}
+				if err != nil {
+					view := a.buildPollView(r, poll, responses, "", userToken, adminToken)
+					view.Errors = FieldErrors{}
+					view.Errors.merge(err)
+					view.EditVenues = pollEditVenues(updatedVenues)
+					a.renderPollErrors(w, r, http.StatusUnprocessableEntity, view)
+					return
+				}
+				changedResponses := responsesForUpdatedVenues(responses, updatedVenues)
+				if err := a.storage.UpdatePollVenues(r.Context(), pollID, poll.Version, updatedVenues, changedResponses); err != nil {
+					writeUpdateError(w, err, "failed to update poll venues")
+					return
+				}
+				http.Redirect(w, r, pageURL, http.StatusSeeOther)
+				return
+			case "duplicate-poll":
+				duplicated := duplicatePoll(poll)
+				recoveryCode := newRecoveryCode()
+				duplicated.RecoveryHash = hashRecoveryCode(recoveryCode)
+				if err := a.storage.CreatePoll(r.Context(), duplicated); err != nil {
+					log.Printf("failed to duplicate poll: %v", err)
+					http.Error(w, "unable to duplicate poll", http.StatusInternalServerError)
+					return
+				}
+				setUserTokenCookie(w, r, duplicated.ID, duplicated.CreatorToken)
+				setRecoveryCodeCookie(w, r, duplicated.ID, recoveryCode)
+				http.Redirect(w, r, pollManagePath(duplicated), http.StatusSeeOther)
+				return
+			case "rotate-creator-link":
+				rotated, _, err := a.rotateCreatorCredentials(r.Context(), poll, responses, false)
+				if err != nil {
+					writeUpdateError(w, err, "failed to rotate creator link")
+					return
+				}
+				setUserTokenCookie(w, r, pollID, rotated.CreatorToken)
+				http.Redirect(w, r, pollManagePath(rotated), http.StatusSeeOther)
+				return
+			case "reset-recovery-code":
+				code := newRecoveryCode()
+				credentials := PollCredentials{
+					CreatorToken: poll.CreatorToken,
+					AdminToken:   poll.AdminToken,
+					RecoveryHash: hashRecoveryCode(code),
+				}
+				if err := a.storage.UpdatePollCredentials(r.Context(), pollID, poll.Version, credentials, nil); err != nil {
+					writeUpdateError(w, err, "failed to reset recovery code")
+					return
+				}
+				setRecoveryCodeCookie(w, r, pollID, code)
+				http.Redirect(w, r, pageURL, http.StatusSeeOther)
+				return
+			case "close-poll", "reopen-poll", "set-deadline":
+				settings := pollSettings(poll)
+				now := time.Now().UTC()
+				switch action {
+				case "close-poll":
+					settings.ClosedAt = now
+				case "reopen-poll":
+					settings.ClosedAt = time.Time{}
+					if !settings.Deadline.IsZero() && !now.Before(settings.Deadline) {
+						settings.Deadline = time.Time{}
+					}
+				case "set-deadline":
+					errs := FieldErrors{}
+					settings.Deadline = parseDeadline(errs, r.FormValue("deadline"), now, pollLocation(poll))
+					if len(errs) > 0 {
+						view := a.buildPollView(r, poll, responses, "", userToken, adminToken)
+						view.Errors = errs
+						view.DeadlineInput = r.FormValue("deadline")
+						a.renderPollErrors(w, r, http.StatusUnprocessableEntity, view)
+						return
+					}
+				}
+				if err := a.storage.UpdatePollSettings(r.Context(), pollID, poll.Version, settings); err != nil {
+					writeUpdateError(w, err, "failed to update poll closing")
+					return
+				}
+				http.Redirect(w, r, pageURL, http.StatusSeeOther)
+				return
+			case "finalize-poll":
+				day := strings.TrimSpace(r.FormValue("final_day"))
+				venueID := strings.TrimSpace(r.FormValue("final_venue"))
+				errs := FieldErrors{}
+				if !makeDaySet(pollOptions(poll.Days, poll.Slots))[day] {
+					errs.add("final_day", "Pick one of the poll's days.")
+				}
+				if venueID != "" && findVenueByID(poll.Venues, venueID) == nil {
+					errs.add("final_venue", "Pick one of the poll's venue/activity options.")
+				}
+				if len(errs) > 0 {
+					view := a.buildPollView(r, poll, responses, "", userToken, adminToken)
+					view.Errors = errs
+					a.renderPollErrors(w, r, http.StatusUnprocessableEntity, view)
+					return
+				}
+				settings := pollSettings(poll)
+				settings.FinalDay, settings.FinalVenueID = day, venueID
+				if err := a.storage.UpdatePollSettings(r.Context(), pollID, poll.Version, settings); err != nil {
+					writeUpdateError(w, err, "failed to finalize poll")
+					return
+				}
+				http.Redirect(w, r, pageURL, http.StatusSeeOther)
+				return
+			case "unfinalize-poll":
+				settings := pollSettings(poll)
+				settings.FinalDay, settings.FinalVenueID = "", ""
+				if err := a.storage.UpdatePollSettings(r.Context(), pollID, poll.Version, settings); err != nil {
+					writeUpdateError(w, err, "failed to unfinalize poll")
+					return
+				}
+				http.Redirect(w, r, pageURL, http.StatusSeeOther)
+				return
+			case "update-time-zone":
+				timeZone := strings.TrimSpace(r.FormValue("time_zone"))
+				errs := FieldErrors{}
+				validateTimeZone(errs, "time_zone", timeZone)
+				if len(errs) > 0 {
+					view := a.buildPollView(r, poll, responses, "", userToken, adminToken)
+					view.Errors = errs
+					view.TimeZoneInput = timeZone
+					a.renderPollErrors(w, r, http.StatusUnprocessableEntity, view)
+					return
+				}
+				settings := pollSettings(poll)
+				settings.TimeZone = timeZone
+				if err := a.storage.UpdatePollSettings(r.Context(), pollID, poll.Version, settings); err != nil {
+					writeUpdateError(w, err, "failed to update poll time zone")
+					return
+				}
+				http.Redirect(w, r, pageURL, http.StatusSeeOther)
+				return
+			case "set-trip-length":
+				errs := FieldErrors{}
+				tripLength := parseTripLength(errs, r.FormValue("trip_length"))
+				if len(errs) > 0 {
+					view := a.buildPollView(r, poll, responses, "", userToken, adminToken)
+					view.Errors = errs
+					view.TripLengthInput = r.FormValue("trip_length")
+					a.renderPollErrors(w, r, http.StatusUnprocessableEntity, view)
+					return
+				}
+				settings := pollSettings(poll)
+				settings.TripLength = tripLength
+				if err := a.storage.UpdatePollSettings(r.Context(), pollID, poll.Version, settings); err != nil {
+					writeUpdateError(w, err, "failed to update trip length")
+					return
+				}
+				http.Redirect(w, r, pageURL, http.StatusSeeOther)
+				return
+			case "require-attendee", "unrequire-attendee":
+				target := findResponseByID(responses, strings.TrimSpace(r.FormValue("response_id")))
+				if target == nil {
+					http.Error(w, "unknown responder", http.StatusBadRequest)
+					return
+				}
+				settings := pollSettings(poll)
+				settings.RequiredAttendees = removeString(poll.RequiredAttendees, target.ID)
+				if action == "require-attendee" {
+					settings.RequiredAttendees = append(settings.RequiredAttendees, target.ID)
+				}
+				if err := a.storage.UpdatePollSettings(r.Context(), pollID, poll.Version, settings); err != nil {
+					writeUpdateError(w, err, "failed to update required attendees")
+					return
+				}
+				http.Redirect(w, r, pageURL, http.StatusSeeOther)
+				return
+			case "set-quorum":
+				errs := FieldErrors{}
+				quorum := parseQuorum(errs, r.FormValue("quorum"))
+				if len(errs) > 0 {
+					view := a.buildPollView(r, poll, responses, "", userToken, adminToken)
+					view.Errors = errs
+					view.QuorumInput = r.FormValue("quorum")
+					a.renderPollErrors(w, r, http.StatusUnprocessableEntity, view)
+					return
+				}
+				settings := pollSettings(poll)
+				settings.Quorum = quorum
+				if err := a.storage.UpdatePollSettings(r.Context(), pollID, poll.Version, settings); err != nil {
+					writeUpdateError(w, err, "failed to update quorum")
+					return
+				}
+				http.Redirect(w, r, pageURL, http.StatusSeeOther)
+				return
+			case "promote-organizer", "revoke-organizer":
+				target := findResponseByID(responses, strings.TrimSpace(r.FormValue("response_id")))
+				if target == nil || isCreator(poll, target.UserToken) {
+					http.Error(w, "unknown responder", http.StatusBadRequest)
+					return
+				}
+				// Promoting again hands out a fresh secret, which also retires a leaked one.
+				updated := cloneResponse(*target)
+				updated.OrganizerToken = ""
+				if action == "promote-organizer" {
+					updated.OrganizerToken = randomID()
+				}
+				if err := a.storage.AddResponse(r.Context(), pollID, updated); err != nil {
+					writeUpdateError(w, err, "failed to update poll organizers")
+					return
+				}
+				http.Redirect(w, r, pageURL, http.StatusSeeOther)
+				return
+			}
+		}
+
+		name := strings.TrimSpace(r.FormValue("name"))
+		for attempt := 1; ; attempt++ {
+			selectedDays, ifNeedBeDays := parseDayPreferences(r.Form, pollOptions(poll.Days, poll.Slots))
+			selectedVenueVotes := filterVenueVotes(normalizeVenueVotes(r.Form["venues"]), poll.Venues)
+			errs := FieldErrors{}
+			validateText(errs, "name", "Your name", name, maxNameLength)
+			if len(selectedDays) == 0 && len(ifNeedBeDays) == 0 {
+				errs.add("days", "Pick at least one day you can make.")
+			}
+			updatedVenues, writeInVenueID, err := addVenueWriteIn(
+				poll.Venues,
+				r.FormValue("write_in_venue_title"),
+				r.FormValue("write_in_venue_url"),
+				r.FormValue("write_in_venue_description"),
+			)
+			errs.merge(err)
+			errMsg := ""
+			if findResponseByToken(responses, userToken) == nil && len(responses) >= maxPollResponses {
+				errMsg = fmt.Sprintf("This poll already has the maximum of %d responses.", maxPollResponses)
+			}
+			if len(errs) > 0 || errMsg != "" {
+				view := a.buildPollView(r, poll, responses, errMsg, userToken, adminToken)
+				view.Errors = errs
+				view.ViewerName = name
+				view.SelectedDays = makeDaySet(selectedDays)
+				view.SelectedIfNeedBeDays = makeDaySet(ifNeedBeDays)
+				view.SelectedVenueVotes = makeDaySet(selectedVenueVotes)
+				view.WriteIn = Venue{
+					Title:       r.FormValue("write_in_venue_title"),
+					URL:         r.FormValue("write_in_venue_url"),
+					Description: r.FormValue("write_in_venue_description"),
+				}
+				a.renderPollErrors(w, r, http.StatusUnprocessableEntity, view)
+				return
+			}
+
+			err = a.saveResponse(r.Context(), poll, responses, userToken, name, selectedDays, ifNeedBeDays, selectedVenueVotes, updatedVenues, writeInVenueID)
+			if errors.Is(err, errConflict) && attempt < maxWriteAttempts {
+				poll, responses, err = a.storage.GetPoll(r.Context(), pollID)
+				if err != nil {
+					log.Printf("failed to reload poll: %v", err)
+					http.Error(w, "unable to load poll", http.StatusInternalServerError)
+					return
+				}
+				continue
+			}
+			if errors.Is(err, errConflict) {
+				http.Error(w, "this poll is busy right now, please try saving again", http.StatusConflict)
+				return
+			}
+			if err != nil {
+				log.Printf("failed to save response: %v", err)
+				http.Error(w, "unable to save response", http.StatusInternalServerError)
+				return
+			}
+			break
+		}
+
+		poll, responses, err = a.storage.GetPoll(r.Context(), pollID)
+		if err != nil {
+			log.Printf("failed to reload poll: %v", err)
+			http.Error(w, "unable to load poll", http.StatusInternalServerError)
+			return
+		}
+		view := a.buildPollView(r, poll, responses, "", userToken, adminToken)
+		if isHTMX(r) {
+			a.render(w, "results.html", view)
+			return
+		}
+		a.render(w, "poll.html", view)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// renderPollErrors re-renders the whole poll page with status.
+func (a *App) renderPollErrors(w http.ResponseWriter, r *http.Request, status int, view PollView) {
+	if isHTMX(r) {
+		w.Header().Set("HX-Retarget", "#poll-layout")
+		w.Header().Set("HX-Reselect", "#poll-layout")
+		w.Header().Set("HX-Reswap", "outerHTML")
+	}
+	w.WriteHeader(status)
+	a.render(w, "poll.html", view)
+}
+
+func (a *App) saveResponse(ctx context.Context, poll Poll, responses []Response, userToken string, name string, days []string, ifNeedBeDays []string, venueVotes []string, updatedVenues []Venue, writeInVenueID string) error {
+	if writeInVenueID != "" {
+		venueVotes = filterVenueVotes(normalizeVenueVotes(append(venueVotes, writeInVenueID)), updatedVenues)
+	}
+
+	response := Response{
+		ID:           randomID(),
+		Name:         name,
+		Days:         days,
+		IfNeedBeDays: ifNeedBeDays,
+		VenueVotes:   venueVotes,
+		UserToken:    userToken,
+		CreatedAt:    time.Now().UTC(),
+	}
+	if existing := findResponseByToken(responses, userToken); existing != nil {
+		response.ID = existing.ID
+		response.CreatedAt = existing.CreatedAt
+		response.OrganizerToken = existing.OrganizerToken
+		response.Version = existing.Version
+	}
+	if len(updatedVenues) != len(poll.Venues) {
+		return a.storage.AddVenueWriteIn(ctx, poll.ID, updatedVenues[len(updatedVenues)-1], response)
+	}
+	return a.storage.AddResponse(ctx, poll.ID, response)
+}
+
+func (a *App) handleStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	stats, err := a.storage.GetStats(r.Context())
+	if err != nil {
+		log.Printf("failed to load stats: %v", err)
+		http.Error(w, "unable to load stats", http.StatusInternalServerError)
+		return
+	}
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	from := weekStart(today).AddDate(0, 0, -7*(statsWeeks-1))
+	daily, err := a.storage.GetStatsSeries(r.Context(), from.Format("2006-01-02"), today.Format("2006-01-02"))
+	if err != nil {
+		log.Printf("failed to load stats series: %v", err)
+		http.Error(w, "unable to load stats", http.StatusInternalServerError)
+		return
+	}
+	stats.Daily = dailyStatsSeries(daily, today.AddDate(0, 0, 1-statsDays), today)
+	stats.Weekly = weeklyStatsSeries(daily, from, today)
+
+	if strings.HasSuffix(r.URL.Path, ".json") {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(stats); err != nil {
+			log.Printf("failed to write stats: %v", err)
+		}
+		return
+	}
+	a.render(w, "stats.html", StatsView{
+		Stats:        stats,
+		DailyCharts:  statsCharts(stats.Daily),
+		WeeklyCharts: statsCharts(stats.Weekly),
+		CSRFToken:    csrfToken(r),
+	})
+}
+
+// dailyStatsSeries fills in days without activity from start to end.
+func dailyStatsSeries(daily []StatsPeriod, start, end time.Time) []StatsPeriod {
+	byDay := make(map[string]StatsCounters, len(daily))
+	for _, period := range daily {
+		byDay[period.Start] = period.StatsCounters
+	}
+	var series []StatsPeriod
+	for day := start; !day.After(end); day = day.AddDate(0, 0, 1) {
+		key := day.Format("2006-01-02")
+		series = append(series, withRates(StatsPeriod{Start: key, StatsCounters: byDay[key]}))
+	}
+	return series
+}
+
+// weeklyStatsSeries sums days into weeks keyed by their Monday.
+func weeklyStatsSeries(daily []StatsPeriod, start, end time.Time) []StatsPeriod {
+	var series []StatsPeriod
+	index := make(map[string]int)
+	for week := weekStart(start); !week.After(end); week = week.AddDate(0, 0, 7) {
+		key := week.Format("2006-01-02")
+		index[key] = len(series)
+		series = append(series, StatsPeriod{Start: key})
+	}
+	for _, period := range daily {
+		day, err := time.Parse("2006-01-02", period.Start)
+		if err != nil {
+			continue
+		}
+		if i, ok := index[weekStart(day).Format("2006-01-02")]; ok {
+			series[i].add(period.StatsCounters)
+		}
+	}
+	for i := range series {
+		series[i] = withRates(series[i])
+	}
+	return series
+}
+
+func weekStart(day time.Time) time.Time {
+	offset := (int(day.Weekday()) + 6) % 7
+	return day.AddDate(0, 0, -offset)
+}
+
+// withRates fills in the rates derived from the period's counts.
+func withRates(period StatsPeriod) StatsPeriod {
+	if period.Polls > 0 {
+		period.RespondentsPerPoll = float64(period.Responses) / float64(period.Polls)
+		period.VenueShare = float64(period.VenuePolls) / float64(period.Polls)
+	}
+	if period.Responses > 0 {
+		period.WriteInRate = float64(period.WriteIns) / float64(period.Responses)
+	}
+	return period
+}
+
+func statsCharts(series []StatsPeriod) []StatsChart {
+	count := func(value float64) string { return fmt.Sprintf("%.0f", value) }
+	ratio := func(value float64) string { return fmt.Sprintf("%.1f", value) }
+	percent := func(value float64) string { return fmt.Sprintf("%.0f%%", value*100) }
+	metrics := []struct {
+		title  string
+		value  func(StatsPeriod) float64
+		format func(float64) string
+	}{
+		{"Polls created", func(p StatsPeriod) float64 { return float64(p.Polls) }, count},
+		{"Responses submitted", func(p StatsPeriod) float64 { return float64(p.Responses) }, count},
+		{"Respondents per poll", func(p StatsPeriod) float64 { return p.RespondentsPerPoll }, ratio},
+		{"Polls with venues", func(p StatsPeriod) float64 { return p.VenueShare }, percent},
+		{"Write-in rate", func(p StatsPeriod) float64 { return p.WriteInRate }, percent},
+	}
+	charts := make([]StatsChart, 0, len(metrics))
+	for _, metric := range metrics {
+		charts = append(charts, statsChart(series, metric.title, metric.value, metric.format))
+	}
+	return charts
+}
+
+func statsChart(series []StatsPeriod, title string, value func(StatsPeriod) float64, format func(float64) string) StatsChart {
+	const width, height = 300, 100
+	chart := StatsChart{Title: title, Width: width, Height: height}
+	if len(series) == 0 {
+		return chart
+	}
+	maxValue := 0.0
+	for _, period := range series {
+		maxValue = max(maxValue, value(period))
+	}
+	chart.Max = format(maxValue)
+	chart.FirstLabel = formatDate(series[0].Start)
+	chart.LastLabel = formatDate(series[len(series)-1].Start)
+	slot := float64(width) / float64(len(series))
+	for i, period := range series {
+		barHeight := 0.0
+		if maxValue > 0 {
+			barHeight = value(period) / maxValue * height
+		}
+		chart.Bars = append(chart.Bars, StatsBar{
+			X:      roundChart(float64(i)*slot + slot*0.1),
+			Y:      roundChart(height - barHeight),
+			Width:  roundChart(slot * 0.8),
+			Height: roundChart(barHeight),
+			Label:  formatDate(period.Start) + ": " + format(value(period)),
+		})
+	}
+	return chart
+}
+
+func roundChart(value float64) float64 {
+	return math.Round(value*100) / 100
+}
+
+// AdminAuth checks Basic credentials or a signed session cookie for admin routes.
+type AdminAuth struct {
+	passwordHash []byte
+	sessionKey   []byte
+	throttle     *loginThrottle
+}
+
+type loginThrottle struct {
+	mu       sync.Mutex
+	failures map[string]loginFailures
+}
+
+type loginFailures struct {
+	count int
+	first time.Time
+}
+
+type RecoverView struct {
+	PollID    string
+	Error     string
+	CSRFToken string
+}
+
+type AdminLoginView struct {
+	Next      string
+	Error     string
+	CSRFToken string
+}
+
+type ErrorView struct {
+	Title   string
+	Message string
+}
+
+type csrfContextKey struct{}
+
+func newAdminAuth(passwordHash, sessionSecret string) (*AdminAuth, error) {
+	if passwordHash == "" {
+		return nil, nil
+	}
+	if _, err := bcrypt.Cost([]byte(passwordHash)); err != nil {
+		return nil, fmt.Errorf("ADMIN_PASSWORD_HASH must be a bcrypt hash: %w", err)
+	}
+	key := []byte(sessionSecret)
+	if len(key) == 0 {
+		key = make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return nil, err
+		}
+		log.Printf("ADMIN_SESSION_SECRET is not set; admin sessions will not survive a restart")
+	}
+	return &AdminAuth{
+		passwordHash: []byte(passwordHash),
+		sessionKey:   key,
+		throttle:     &loginThrottle{failures: make(map[string]loginFailures)},
+	}, nil
+}
+
+func (a *App) requireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if a.admin == nil {
+			http.Error(w, "admin access is not configured", http.StatusForbidden)
+			return
+		}
+		if a.admin.validSession(r, time.Now()) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if _, password, ok := r.BasicAuth(); ok {
+			ok, wait := a.checkAdminPassword(r, password)
+			if wait > 0 {
+				writeLoginThrottled(w, wait)
+				return
+			}
+			if !ok {
+				w.Header().Set("WWW-Authenticate", `Basic realm="bff-hang admin"`)
+				http.Error(w, "invalid admin credentials", http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+		if r.Method == http.MethodGet && !strings.HasSuffix(r.URL.Path, ".json") {
+			http.Redirect(w, r, "/admin/login?next="+url.QueryEscape(r.URL.RequestURI()), http.StatusSeeOther)
+			return
+		}
+		w.Header().Set("WWW-Authenticate", `Basic realm="bff-hang admin"`)
+		http.Error(w, "admin login required", http.StatusUnauthorized)
+	})
+}
+
+// checkAdminPassword returns a non-zero wait, without checking, while the client is locked out.
+func (a *App) checkAdminPassword(r *http.Request, password string) (bool, time.Duration) {
+	client := a.clientIP(r)
+	now := time.Now()
+	if wait := a.admin.throttle.lockedFor(client, now); wait > 0 {
+		return false, wait
+	}
+	if bcrypt.CompareHashAndPassword(a.admin.passwordHash, []byte(password)) != nil {
+		log.Printf("failed admin login from %s", client)
+		a.admin.throttle.fail(client, now)
+		return false, 0
+	}
+	a.admin.throttle.reset(client)
+	return true, 0
+}
+
+func writeLoginThrottled(w http.ResponseWriter, wait time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+	http.Error(w, "too many failed login attempts, try again later", http.StatusTooManyRequests)
+}
+
+func (a *App) handleAdminLogin(w http.ResponseWriter, r *http.Request) {
+	if a.admin == nil {
+		http.Error(w, "admin access is not configured", http.StatusForbidden)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		a.render(w, "admin_login.html", AdminLoginView{Next: adminRedirectTarget(r.URL.Query().Get("next")), CSRFToken: csrfToken(r)})
+	case http.MethodPost:
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, "invalid form", http.StatusBadRequest)
+			return
+		}
+		next := adminRedirectTarget(r.FormValue("next"))
+		ok, wait := a.checkAdminPassword(r, r.FormValue("password"))
+		if wait > 0 {
+			writeLoginThrottled(w, wait)
+			return
+		}
+		if !ok {
+			w.WriteHeader(http.StatusUnauthorized)
+			a.render(w, "admin_login.html", AdminLoginView{Next: next, Error: "That password didn't work.", CSRFToken: csrfToken(r)})
+			return
+		}
+		expires := time.Now().Add(adminSessionTTL)
+		http.SetCookie(w, &http.Cookie{
+			Name:     adminCookieName,
+			Value:    a.admin.sessionToken(expires),
+			Path:     "/admin",
+			Expires:  expires,
+			HttpOnly: true,
+			SameSite: http.SameSiteStrictMode,
+			Secure:   schemeForRequest(r) == "https",
+		})
+		http.Redirect(w, r, next, http.StatusSeeOther)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (a *App) handleAdminLogout(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     adminCookieName,
+		Value:    "",
+		Path:     "/admin",
+		MaxAge:   -1,
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+		Secure:   schemeForRequest(r) == "https",
+	})
+	http.Redirect(w, r, "/admin/login", http.StatusSeeOther)
+}
+
+// adminRedirectTarget returns next if it is an admin path, so login is not an open redirect.
+func adminRedirectTarget(next string) string {
+	if !strings.HasPrefix(next, "/admin/") || strings.HasPrefix(next, "/admin/login") {
+		return "/admin/stats"
+	}
+	return next
+}
+
+func (auth *AdminAuth) sessionToken(expires time.Time) string {
+	expiry := strconv.FormatInt(expires.Unix(), 10)
+	return expiry + "." + auth.sessionSignature(expiry)
+}
+
+func (auth *AdminAuth) sessionSignature(expiry string) string {
+	mac := hmac.New(sha256.New, auth.sessionKey)
+	mac.Write(auth.passwordHash)
+	mac.Write([]byte("|admin|" + expiry))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func (auth *AdminAuth) validSession(r *http.Request, now time.Time) bool {
+	cookie, err := r.Cookie(adminCookieName)
+	if err != nil {
+		return false
+	}
+	expiry, signature, ok := strings.Cut(cookie.Value, ".")
+	if !ok {
+		return false
+	}
+	unix, err := strconv.ParseInt(expiry, 10, 64)
+	if err != nil || !now.Before(time.Unix(unix, 0)) {
+		return false
+	}
+	return hmac.Equal([]byte(signature), []byte(auth.sessionSignature(expiry)))
+}
+
+func (t *loginThrottle) lockedFor(client string, now time.Time) time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	entry, ok := t.failures[client]
+	if !ok || entry.count < maxAdminLoginFailures {
+		return 0
+	}
+	return max(entry.first.Add(adminLoginWindow).Sub(now), 0)
+}
+
+func (t *loginThrottle) fail(client string, now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for key, entry := range t.failures {
+		if now.Sub(entry.first) >= adminLoginWindow {
+			delete(t.failures, key)
+		}
+	}
+	entry, ok := t.failures[client]
+	if !ok {
+		entry = loginFailures{first: now}
+	}
+	entry.count++
+	t.failures[client] = entry
+}
+
+func (t *loginThrottle) reset(client string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.failures, client)
+}
+
+// clientIP returns the caller's address, from X-Forwarded-For when trusted.
+func (a *App) clientIP(r *http.Request) string {
+	if a.trustForwarded {
+		if forwarded := r.Header.Values("X-Forwarded-For"); len(forwarded) > 0 {
+			hops := strings.Split(forwarded[len(forwarded)-1], ",")
+			if last := strings.TrimSpace(hops[len(hops)-1]); last != "" {
+				return last
+			}
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func (a *App) limitRate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if a.limiter == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		for _, key := range a.rateLimitKeys(r) {
+			limit, ok := a.rateLimits[key.name]
+			if !ok {
+				continue
+			}
+			allowed, wait, err := a.limiter.Allow(r.Context(), key.name+":"+key.value, limit, time.Now())
+			if err != nil {
+				log.Printf("rate limiter failed, allowing request: %v", err)
+				continue
+			}
+			if !allowed {
+				log.Printf("rate limited %s %s (%s %s)", r.Method, r.URL.Path, key.name, key.value)
+				w.Header().Set("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+				http.Error(w, "too many requests, try again later", http.StatusTooManyRequests)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
 
-	a.render(w, "home.html", data)
+type rateLimitKey struct {
+	name  string
+	value string
 }
 
-func (a *App) handleCreatePoll(w http.ResponseWriter, r *http.Request) {
+// rateLimitKeys returns the limits a write request counts against, per-IP first.
+func (a *App) rateLimitKeys(r *http.Request) []rateLimitKey {
 	if r.Method != http.MethodPost {
-		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
-		return
+		return nil
 	}
-	if err := r.ParseForm(); err != nil {
-		http.Error(w, "invalid form", http.StatusBadRequest)
-		return
+	ip := a.clientIP(r)
+	if r.URL.Path == "/polls" {
+		return []rateLimitKey{{"create-poll.ip", ip}}
 	}
-
-	title := strings.TrimSpace(r.FormValue("title"))
-	creator := strings.TrimSpace(r.FormValue("creator"))
-	selectedDays := normalizeDays(r.Form["days"])
-	if title == "" || creator == "" || len(selectedDays) == 0 {
-		http.Error(w, "title, name, and at least one day are required", http.StatusBadRequest)
-		return
+	pollID, _ := parsePollPath(r.URL.Path)
+	if pollID == "" {
+		return nil
 	}
-	venues, err := parseVenuesFromForm(
-		r.Form["venue_id"],
-		r.Form["venue_title"],
-		r.Form["venue_url"],
-		r.Form["venue_description"],
-		nil,
-	)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return
+	if r.URL.Path == "/poll/"+pollID+"/recover" {
+		return []rateLimitKey{{"recover.ip", ip}}
 	}
+	return []rateLimitKey{{"respond.ip", ip}, {"respond.poll", pollID}}
+}
 
-	creatorToken := randomID()
-	poll := Poll{
-		ID:           randomID(),
-		Title:        title,
-		Days:         selectedDays,
-		Venues:       venues,
-		CreatorToken: creatorToken,
-		CreatedAt:    time.Now().UTC(),
+// parseRateLimits applies RATE_LIMITS overrides such as "create-poll.ip=5/1h,respond.poll=off".
+func parseRateLimits(spec string) (map[string]RateLimit, error) {
+	limits := make(map[string]RateLimit, len(defaultRateLimits))
+	for name, limit := range defaultRateLimits {
+		limits[name] = limit
 	}
-
-	if err := a.storage.CreatePoll(r.Context(), poll); err != nil {
-		log.Printf("failed to create poll: %v", err)
-		http.Error(w, "unable to create poll", http.StatusInternalServerError)
-		return
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(entry, "=")
+		name = strings.TrimSpace(name)
+		if _, known := defaultRateLimits[name]; !ok || !known {
+			return nil, fmt.Errorf("unknown rate limit %q", entry)
+		}
+		value = strings.TrimSpace(value)
+		if value == "off" {
+			delete(limits, name)
+			continue
+		}
+		count, period, ok := strings.Cut(value, "/")
+		requests, err := strconv.Atoi(count)
+		if !ok || err != nil || requests <= 0 {
+			return nil, fmt.Errorf("invalid rate limit %q: want {requests}/{duration} or off", entry)
+		}
+		per, err := time.ParseDuration(period)
+		if err != nil || per <= 0 {
+			return nil, fmt.Errorf("invalid rate limit %q: want {requests}/{duration} or off", entry)
+		}
+		limits[name] = RateLimit{Requests: requests, Per: per}
 	}
+	return limits, nil
+}
 
-	creatorResponse := Response{
-		ID:         randomID(),
-		Name:       creator,
-		Days:       selectedDays,
-		VenueVotes: nil,
-		UserToken:  creatorToken,
-		CreatedAt:  time.Now().UTC(),
+func (limit RateLimit) refillRate() float64 {
+	return float64(limit.Requests) / limit.Per.Seconds()
+}
+
+// take refills the bucket for the time since its last update and takes one token if it can.
+func (b rateBucket) take(limit RateLimit, now time.Time) (rateBucket, bool, time.Duration) {
+	tokens := float64(limit.Requests)
+	if !b.updated.IsZero() {
+		tokens = math.Min(tokens, b.tokens+max(now.Sub(b.updated).Seconds(), 0)*limit.refillRate())
 	}
-	if err := a.storage.AddResponse(r.Context(), poll.ID, creatorResponse); err != nil {
-		log.Printf("failed to add creator response: %v", err)
-		http.Error(w, "unable to create poll", http.StatusInternalServerError)
-		return
+	if tokens < 1 {
+		wait := time.Duration((1 - tokens) / limit.refillRate() * float64(time.Second))
+		return rateBucket{tokens: tokens, updated: now, expires: now.Add(limit.Per)}, false, wait
 	}
-
-	setUserTokenCookie(w, r, poll.ID, creatorToken)
-	http.Redirect(w, r, fmt.Sprintf("/poll/%s/u/%s", poll.ID, creatorToken), http.StatusSeeOther)
+	return rateBucket{tokens: tokens - 1, updated: now, expires: now.Add(limit.Per)}, true, 0
 }
 
-func duplicatePoll(source Poll) Poll {
-	return Poll{
-		ID:           randomID(),
-		Title:        source.Title,
-		Days:         nil,
-		Venues:       cloneVenues(source.Venues),
-		CreatorToken: randomID(),
-		CreatedAt:    time.Now().UTC(),
-	}
+func newMemoryRateLimiter() *MemoryRateLimiter {
+	return &MemoryRateLimiter{buckets: make(map[string]rateBucket)}
 }
 
-func (a *App) handlePoll(w http.ResponseWriter, r *http.Request) {
-	pollID, userToken := parsePollPath(r.URL.Path)
-	if pollID == "" {
-		http.NotFound(w, r)
-		return
+func (l *MemoryRateLimiter) Allow(ctx context.Context, key string, limit RateLimit, now time.Time) (bool, time.Duration, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.buckets) >= maxRateLimitBuckets {
+		l.prune(now)
 	}
+	bucket, allowed, wait := l.buckets[key].take(limit, now)
+	l.buckets[key] = bucket
+	return allowed, wait, nil
+}
 
-	switch r.Method {
-	case http.MethodGet:
-		if userToken == "" {
-			token := userTokenFromCookie(r, pollID)
-			if token == "" {
-				token = randomID()
-				setUserTokenCookie(w, r, pollID, token)
-			}
-			http.Redirect(w, r, fmt.Sprintf("/poll/%s/u/%s", pollID, token), http.StatusSeeOther)
-			return
+func (l *MemoryRateLimiter) prune(now time.Time) {
+	for key, bucket := range l.buckets {
+		if !now.Before(bucket.expires) {
+			delete(l.buckets, key)
 		}
+	}
+}
 
-		setUserTokenCookie(w, r, pollID, userToken)
-		poll, responses, err := a.storage.GetPoll(r.Context(), pollID)
+// Allow takes a token from the bucket item, conditioned on the timestamp it was read with.
+func (l *DynamoDBRateLimiter) Allow(ctx context.Context, key string, limit RateLimit, now time.Time) (bool, time.Duration, error) {
+	itemKey := map[string]types.AttributeValue{
+		"pk": &types.AttributeValueMemberS{Value: "RATE#" + key},
+		"sk": &types.AttributeValueMemberS{Value: "RATE"},
+	}
+	for attempt := 0; attempt < maxWriteAttempts; attempt++ {
+		out, err := l.client.GetItem(ctx, &dynamodb.GetItemInput{
+			TableName:      &l.Table,
+			Key:            itemKey,
+			ConsistentRead: awsBool(true),
+		})
 		if err != nil {
-			if errors.Is(err, errNotFound) {
-				http.Redirect(w, r, "/?invalid=1", http.StatusSeeOther)
-				return
-			}
-			log.Printf("failed to load poll: %v", err)
-			http.Error(w, "unable to load poll", http.StatusInternalServerError)
-			return
+			return false, 0, err
 		}
-
-		view := a.buildPollView(r, poll, responses, "", userToken)
-		a.render(w, "poll.html", view)
-	case http.MethodPost:
-		if userToken == "" {
-			http.Redirect(w, r, "/poll/"+pollID, http.StatusSeeOther)
-			return
+		var item RateLimitItem
+		if err := attributevalue.UnmarshalMap(out.Item, &item); err != nil {
+			return false, 0, err
 		}
-		if err := r.ParseForm(); err != nil {
-			http.Error(w, "invalid form", http.StatusBadRequest)
-			return
+		current := rateBucket{tokens: item.Tokens}
+		if len(out.Item) > 0 && now.Unix() < item.ExpiresAt {
+			current.updated = time.Unix(0, item.UpdatedAt)
 		}
-		poll, responses, err := a.storage.GetPoll(r.Context(), pollID)
+		bucket, allowed, wait := current.take(limit, now)
+		if !allowed {
+			return false, wait, nil
+		}
+		av, err := attributevalue.MarshalMap(RateLimitItem{
+			PK:        "RATE#" + key,
+			SK:        "RATE",
+			Type:      "rate_limit",
+			Tokens:    bucket.tokens,
+			UpdatedAt: now.UnixNano(),
+			ExpiresAt: bucket.expires.Unix() + 1,
+		})
 		if err != nil {
-			if errors.Is(err, errNotFound) {
-				http.Redirect(w, r, "/?invalid=1", http.StatusSeeOther)
-				return
-			}
-			log.Printf("failed to load poll: %v", err)
-			http.Error(w, "unable to load poll", http.StatusInternalServerError)
-			return
+			return false, 0, err
 		}
-
-		if action := r.FormValue("action"); action != "" {
-			if !isCreator(poll, userToken) {
-				http.Error(w, "forbidden", http.StatusForbidden)
-				return
-			}
-			switch action {
-			case "delete-response":
-				responseID := strings.TrimSpace(r.FormValue("response_id"))
-				if responseID == "" {
-					http.Error(w, "missing response", http.StatusBadRequest)
-					return
-				}
-				if err := a.storage.DeleteResponse(r.Context(), pollID, responseID); err != nil {
-					log.Printf("failed to delete response: %v", err)
-					http.Error(w, "unable to delete response", http.StatusInternalServerError)
-					return
-				}
-				http.Redirect(w, r, fmt.Sprintf("/poll/%s/u/%s", pollID, userToken), http.StatusSeeOther)
-				return
-			case "update-dates":
-				updatedDays := normalizeDays(r.Form["days"])
-				if len(updatedDays) == 0 {
-					http.Error(w, "at least one day is required", http.StatusBadRequest)
-					return
-				}
-				previousDays := poll.Days
-				if err := a.storage.UpdatePollDays(r.Context(), pollID, updatedDays); err != nil {
-					log.Printf("failed to update poll days: %v", err)
-					http.Error(w, "unable to update poll", http.StatusInternalServerError)
-					return
-				}
-				addedDays := diffDays(previousDays, updatedDays)
-				for _, response := range responses {
-					filtered := filterDays(response.Days, updatedDays)
-					if isCreator(poll, response.UserToken) && len(addedDays) > 0 {
-						filtered = mergeDays(filtered, addedDays)
-					}
-					if !equalDays(response.Days, filtered) {
-						response.Days = filtered
-						if err := a.storage.AddResponse(r.Context(), pollID, response); err != nil {
-							log.Printf("failed to update response days: %v", err)
-							http.Error(w, "unable to update poll", http.StatusInternalServerError)
-							return
-						}
-					}
-				}
-				http.Redirect(w, r, fmt.Sprintf("/poll/%s/u/%s", pollID, userToken), http.StatusSeeOther)
-				return
-			case "update-venues":
-				existingByID := make(map[string]Venue, len(poll.Venues))
-				for _, venue := range poll.Venues {
-					existingByID[venue.ID] = venue
-				}
-				updatedVenues, err := parseVenuesFromForm(
-					r.Form["venue_id"],
-					r.Form["venue_title"],
-					r.Form["venue_url"],
-					r.Form["venue_description"],
-					existingByID,
-				)
-				if err != nil {
-					http.Error(w, err.Error(), http.StatusBadRequest)
-					return
-				}
-				if err := a.storage.UpdatePollVenues(r.Context(), pollID, updatedVenues); err != nil {
-					log.Printf("failed to update poll venues: %v", err)
-					http.Error(w, "unable to update poll", http.StatusInternalServerError)
-					return
-				}
-				for _, response := range responses {
-					filteredVotes := filterVenueVotes(response.VenueVotes, updatedVenues)
-					if !equalDays(response.VenueVotes, filteredVotes) {
-						response.VenueVotes = filteredVotes
-						if err := a.storage.AddResponse(r.Context(), pollID, response); err != nil {
-							log.Printf("failed to update response venues: %v", err)
-							http.Error(w, "unable to update poll", http.StatusInternalServerError)
-							return
-						}
-					}
-				}
-				http.Redirect(w, r, fmt.Sprintf("/poll/%s/u/%s", pollID, userToken), http.StatusSeeOther)
-				return
-			case "duplicate-poll":
-				duplicated := duplicatePoll(poll)
-				if err := a.storage.CreatePoll(r.Context(), duplicated); err != nil {
-					log.Printf("failed to duplicate poll: %v", err)
-					http.Error(w, "unable to duplicate poll", http.StatusInternalServerError)
-					return
-				}
-				setUserTokenCookie(w, r, duplicated.ID, duplicated.CreatorToken)
-				http.Redirect(w, r, fmt.Sprintf("/poll/%s/u/%s", duplicated.ID, duplicated.CreatorToken), http.StatusSeeOther)
-				return
-			default:
-				http.Error(w, "unknown action", http.StatusBadRequest)
-				return
-			}
+		condition := "attribute_not_exists(pk)"
+		values := map[string]types.AttributeValue{}
+		if len(out.Item) > 0 {
+			condition = "updated_at = :updated"
+			values[":updated"] = &types.AttributeValueMemberN{Value: strconv.FormatInt(item.UpdatedAt, 10)}
 		}
-
-		name := strings.TrimSpace(r.FormValue("name"))
-		selectedDays := filterDays(normalizeDays(r.Form["days"]), poll.Days)
-		selectedVenueVotes := filterVenueVotes(normalizeVenueVotes(r.Form["venues"]), poll.Venues)
-		if name == "" || len(selectedDays) == 0 {
-			view := a.buildPollView(r, poll, responses, "Please enter your name and at least one available day.", userToken)
-			if isHTMX(r) {
-				w.WriteHeader(http.StatusBadRequest)
-				a.render(w, "results.html", view)
-				return
-			}
-			a.render(w, "poll.html", view)
-			return
+		input := &dynamodb.PutItemInput{
+			TableName:           &l.Table,
+			Item:                av,
+			ConditionExpression: awsString(condition),
 		}
-		updatedVenues, writeInVenueID, err := addVenueWriteIn(
-			poll.Venues,
-			r.FormValue("write_in_venue_title"),
-			r.FormValue("write_in_venue_url"),
-			r.FormValue("write_in_venue_description"),
-		)
-		if err != nil {
-			view := a.buildPollView(r, poll, responses, err.Error(), userToken)
-			if isHTMX(r) {
-				w.WriteHeader(http.StatusBadRequest)
-				a.render(w, "results.html", view)
-				return
-			}
-			a.render(w, "poll.html", view)
-			return
+		if len(values) > 0 {
+			input.ExpressionAttributeValues = values
 		}
-		if len(updatedVenues) != len(poll.Venues) {
-			if err := a.storage.UpdatePollVenues(r.Context(), pollID, updatedVenues); err != nil {
-				log.Printf("failed to add write-in venue: %v", err)
-				http.Error(w, "unable to save venue suggestion", http.StatusInternalServerError)
-				return
-			}
-			poll.Venues = updatedVenues
+		_, err = l.client.PutItem(ctx, input)
+		if err == nil {
+			return true, 0, nil
 		}
-		if writeInVenueID != "" {
-			selectedVenueVotes = filterVenueVotes(normalizeVenueVotes(append(selectedVenueVotes, writeInVenueID)), poll.Venues)
+		if !isConditionFailed(err) {
+			return false, 0, err
 		}
+	}
+	// Losing every race means the key is being hammered from several instances at once.
+	return false, time.Second, nil
+}
 
-		response := Response{
-			ID:         randomID(),
-			Name:       name,
-			Days:       selectedDays,
-			VenueVotes: selectedVenueVotes,
-			UserToken:  userToken,
-			CreatedAt:  time.Now().UTC(),
-		}
-		if existing := findResponseByToken(responses, userToken); existing != nil {
-			response.ID = existing.ID
-			response.CreatedAt = existing.CreatedAt
+func newCSRFKey(secret string) ([]byte, error) {
+	if secret != "" {
+		return []byte(secret), nil
+	}
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	log.Printf("CSRF_SECRET is not set; form tokens will not survive a restart")
+	return key, nil
+}
+
+// protectCSRF rejects unsafe requests without the token for their session cookie.
+func (a *App) protectCSRF(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		session := ""
+		if cookie, err := r.Cookie(csrfCookieName); err == nil {
+			session = cookie.Value
 		}
-		if err := a.storage.AddResponse(r.Context(), pollID, response); err != nil {
-			log.Printf("failed to add response: %v", err)
-			http.Error(w, "unable to save response", http.StatusInternalServerError)
-			return
+		token := ""
+		if session != "" {
+			token = a.csrfTokenFor(session)
 		}
-
-		poll, responses, err = a.storage.GetPoll(r.Context(), pollID)
-		if err != nil {
-			log.Printf("failed to reload poll: %v", err)
-			http.Error(w, "unable to load poll", http.StatusInternalServerError)
-			return
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+		default:
+			submitted := r.Header.Get(csrfHeader)
+			if submitted == "" {
+				submitted = r.PostFormValue(csrfFormField)
+			}
+			if token == "" || !hmac.Equal([]byte(submitted), []byte(token)) {
+				log.Printf("rejected %s %s from %s: missing or invalid CSRF token", r.Method, r.URL.Path, a.clientIP(r))
+				a.rejectCSRF(w, r, session)
+				return
+			}
 		}
-		view := a.buildPollView(r, poll, responses, "", userToken)
-		if isHTMX(r) {
-			a.render(w, "results.html", view)
-			return
+		if session == "" {
+			session = a.setCSRFSession(w, r)
+			token = a.csrfTokenFor(session)
 		}
-		a.render(w, "poll.html", view)
-	default:
-		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
-	}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), csrfContextKey{}, token)))
+	})
 }
 
-func (a *App) handleStats(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
-		return
+// rejectCSRF answers a failed CSRF check, asking HTMX to reload the page.
+func (a *App) rejectCSRF(w http.ResponseWriter, r *http.Request, session string) {
+	if session == "" {
+		a.setCSRFSession(w, r)
 	}
-
-	stats, err := a.storage.GetStats(r.Context())
-	if err != nil {
-		log.Printf("failed to load stats: %v", err)
-		http.Error(w, "unable to load stats", http.StatusInternalServerError)
-		return
+	if r.Header.Get("HX-Request") == "true" {
+		w.Header().Set("HX-Refresh", "true")
 	}
+	w.WriteHeader(http.StatusForbidden)
+	a.render(w, "error.html", ErrorView{
+		Title:   "This form has expired",
+		Message: "We couldn't verify that this request came from BFF Hang. Go back, reload the page and try again.",
+	})
+}
 
-	a.render(w, "stats.html", stats)
+func (a *App) setCSRFSession(w http.ResponseWriter, r *http.Request) string {
+	session := randomID()
+	http.SetCookie(w, &http.Cookie{
+		Name:     csrfCookieName,
+		Value:    session,
+		Path:     "/",
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+		Secure:   schemeForRequest(r) == "https",
+	})
+	return session
+}
+
+func (a *App) csrfTokenFor(session string) string {
+	mac := hmac.New(sha256.New, a.csrfKey)
+	mac.Write([]byte("csrf|" + session))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func csrfToken(r *http.Request) string {
+	token, _ := r.Context().Value(csrfContextKey{}).(string)
+	return token
 }
 
-func (a *App) buildPollView(r *http.Request, poll Poll, responses []Response, errMsg string, viewerToken string) PollView {
-	summaries := summarizeAvailability(poll.Days, responses)
+// buildPollView builds the poll page; adminToken is the viewer's verified management secret, or "".
+func (a *App) buildPollView(r *http.Request, poll Poll, responses []Response, errMsg string, viewerToken string, adminToken string) PollView {
+	summaries := summarizeAvailability(poll.Days, poll.Slots, responses, pollNow(poll).Format("2006-01-02"))
 	venueSummaries := summarizeVenueVotes(poll.Venues, responses)
 	baseURL := a.baseURL
 	if baseURL == "" {
 		baseURL = fmt.Sprintf("%s://%s", schemeForRequest(r), r.Host)
 	}
 	selectedDays := make(map[string]bool)
+	selectedIfNeedBeDays := make(map[string]bool)
 	selectedVenueVotes := make(map[string]bool)
 	viewerName := ""
 	pollDaySet := makeDaySet(poll.Days)
+	optionSet := makeDaySet(pollOptions(poll.Days, poll.Slots))
 	if viewerToken != "" {
 		if response := findResponseByToken(responses, viewerToken); response != nil {
 			viewerName = response.Name
-			for _, day := range response.Days {
-				if pollDaySet[day] {
-					selectedDays[day] = true
+			for _, key := range response.Days {
+				if optionSet[key] {
+					selectedDays[key] = true
+				}
+			}
+			for _, key := range response.IfNeedBeDays {
+				if optionSet[key] {
+					selectedIfNeedBeDays[key] = true
 				}
 			}
 			for _, venueID := range filterVenueVotes(response.VenueVotes, poll.Venues) {
@@ -855,31 +3614,99 @@ func (a *App) buildPollView(r *http.Request, poll Poll, responses []Response, er
 		}
 	}
 	allAvailableDays := make(map[string]bool)
+	allIfNeedBeDays := make(map[string]bool)
 	for _, summary := range summaries {
 		if summary.AllAvailable {
-			allAvailableDays[summary.Date] = true
+			allAvailableDays[summary.Key] = true
+		}
+		if summary.AllIfNeedBe {
+			allIfNeedBeDays[summary.Key] = true
+		}
+	}
+
+	role := pollRoleFor(poll, responses, viewerToken, adminToken)
+	now := time.Now()
+	closed := pollClosed(poll, now)
+	archived := !poll.ArchivedAt.IsZero()
+	formURL := fmt.Sprintf("/poll/%s/u/%s", poll.ID, viewerToken)
+	manageURL := ""
+	if adminToken != "" {
+		formURL = fmt.Sprintf("/poll/%s/manage/%s", poll.ID, adminToken)
+		manageURL = strings.TrimRight(baseURL, "/") + formURL
+	}
+	recoverURL := fmt.Sprintf("%s/poll/%s/recover", strings.TrimRight(baseURL, "/"), poll.ID)
+	organizerLinks := make(map[string]string)
+	if role == roleCreator {
+		for _, response := range responses {
+			if response.OrganizerToken != "" {
+				organizerLinks[response.ID] = fmt.Sprintf("%s/poll/%s/manage/%s", strings.TrimRight(baseURL, "/"), poll.ID, response.OrganizerToken)
+			}
 		}
 	}
+	final := finalChoice(poll, summaries, responses)
+	recommendations := recommendDays(poll, summaries, responses)
+	windows := summarizeWindows(summaries, responses, poll.TripLength)
+	if len(windows) > maxWindows {
+		windows = windows[:maxWindows]
+	}
+	defaultDay, defaultVenueID := suggestedDay(summaries), suggestedVenueID(venueSummaries)
+	if len(recommendations) > 0 {
+		defaultDay = recommendations[0].Key
+	}
+	if len(recommendations) > maxRecommendations {
+		recommendations = recommendations[:maxRecommendations]
+	}
+	if final != nil {
+		defaultDay, defaultVenueID = poll.FinalDay, poll.FinalVenueID
+	}
 
 	return PollView{
-		Poll:               poll,
-		Responses:          responses,
-		Summaries:          summaries,
-		VenueSummaries:     venueSummaries,
-		TotalResponse:      len(responses),
-		Error:              errMsg,
-		ShareURL:           fmt.Sprintf("%s/poll/%s", strings.TrimRight(baseURL, "/"), poll.ID),
-		ViewerToken:        viewerToken,
-		ViewerName:         viewerName,
-		PlaceholderName:    randomPlaceholderName(),
-		SelectedDays:       selectedDays,
-		SelectedVenueVotes: selectedVenueVotes,
-		AllAvailableDays:   allAvailableDays,
-		IsCreator:          isCreator(poll, viewerToken),
-		EditDays:           pollEditDays(poll.Days),
-		EditVenues:         pollEditVenues(poll.Venues),
-		PollDaySet:         pollDaySet,
-		HasVenueOptions:    len(poll.Venues) > 0,
+		Poll:                 poll,
+		Responses:            responses,
+		Summaries:            summaries,
+		Recommendations:      recommendations,
+		Windows:              windows,
+		VenueSummaries:       venueSummaries,
+		TotalResponse:        len(responses),
+		Error:                errMsg,
+		ShareURL:             fmt.Sprintf("%s/poll/%s", strings.TrimRight(baseURL, "/"), poll.ID),
+		ViewerToken:          viewerToken,
+		ViewerName:           viewerName,
+		PlaceholderName:      randomPlaceholderName(),
+		SelectedDays:         selectedDays,
+		SelectedIfNeedBeDays: selectedIfNeedBeDays,
+		SelectedVenueVotes:   selectedVenueVotes,
+		AllAvailableDays:     allAvailableDays,
+		AllIfNeedBeDays:      allIfNeedBeDays,
+		IsCreator:            role == roleCreator,
+		Closed:               closed,
+		Archived:             archived,
+		ReadOnly:             archived || (closed && role < roleCreator),
+		ClosedMessage:        pollClosedMessage(poll, now),
+		DeadlineLabel:        formatDeadline(poll.Deadline, pollLocation(poll)),
+		DeadlineInput:        deadlineInput(poll.Deadline, pollLocation(poll)),
+		TimeZone:             timeZoneLabel(poll.TimeZone),
+		TimeZoneInput:        poll.TimeZone,
+		Final:                final,
+		SuggestedDay:         defaultDay,
+		SuggestedVenueID:     defaultVenueID,
+		CSRFToken:            csrfToken(r),
+		RecoverURL:           recoverURL,
+		CanManage:            !archived && role >= roleOrganizer && !pollClosedTo(poll, now, role),
+		Organizers:           organizerResponses(responses),
+		OrganizerLinks:       organizerLinks,
+		RequiredAttendees:    requiredResponses(poll, responses),
+		Quorum:               poll.Quorum,
+		QuorumInput:          countInput(poll.Quorum),
+		TripLength:           poll.TripLength,
+		TripLengthInput:      countInput(poll.TripLength),
+		FormURL:              formURL,
+		ManageURL:            manageURL,
+		EditMonths:           calendarMonths(pollNow(poll), poll.Days),
+		EditSlots:            pollEditSlots(poll.Slots),
+		EditVenues:           pollEditVenues(poll.Venues),
+		PollDaySet:           pollDaySet,
+		HasVenueOptions:      len(poll.Venues) > 0,
 	}
 }
 
@@ -927,47 +3754,618 @@ func (a *App) render(w http.ResponseWriter, name string, data any) {
 	}
 }
 
-func summarizeAvailability(days []string, responses []Response) []DaySummary {
+// summarizeAvailability scores each option; days before today are marked elapsed.
+func summarizeAvailability(days []string, slots []TimeSlot, responses []Response, today string) []DaySummary {
 	nameByDay := make(map[string][]string)
+	ifNeedBeByDay := make(map[string][]string)
 	for _, response := range responses {
-		for _, day := range response.Days {
+		available := makeDaySet(response.Days)
+		for day := range available {
 			nameByDay[day] = append(nameByDay[day], response.Name)
 		}
+		for _, day := range normalizeDays(response.IfNeedBeDays) {
+			if !available[day] {
+				ifNeedBeByDay[day] = append(ifNeedBeByDay[day], response.Name)
+			}
+		}
 	}
 
 	var summaries []DaySummary
-	for _, day := range days {
-		names := append([]string(nil), nameByDay[day]...)
+	for _, key := range pollOptions(days, slots) {
+		day, slotID := splitOptionKey(key)
+		names := append([]string(nil), nameByDay[key]...)
 		sort.Strings(names)
+		ifNeedBe := append([]string(nil), ifNeedBeByDay[key]...)
+		sort.Strings(ifNeedBe)
+		elapsed := today != "" && day < today
+		everyone := !elapsed && len(responses) > 0 && len(names)+len(ifNeedBe) == len(responses)
 		summaries = append(summaries, DaySummary{
-			Date:         day,
-			Label:        formatDate(day),
-			Names:        names,
-			AllAvailable: len(responses) > 0 && len(names) == len(responses),
+			Date:          day,
+			Key:           key,
+			Label:         formatDate(day),
+			SlotLabel:     slotLabel(slots, slotID),
+			Names:         names,
+			IfNeedBeNames: ifNeedBe,
+			Score:         availableWeight*len(names) + ifNeedBeWeight*len(ifNeedBe),
+			Elapsed:       elapsed,
+			AllAvailable:  everyone && len(ifNeedBe) == 0,
+			AllIfNeedBe:   everyone && len(ifNeedBe) > 0,
 		})
 	}
 
 	return summaries
 }
 
-func upcomingDays(count int) []DayOption {
-	start := time.Now().UTC()
-	return upcomingDaysFrom(start, count)
+// parseDayPreferences reads the day_<key> radio groups, or a plain list of available days.
+func parseDayPreferences(form url.Values, options []string) ([]string, []string) {
+	legacy := makeDaySet(normalizeDays(form["days"]))
+	var available, ifNeedBe []string
+	for _, key := range options {
+		preference := strings.TrimSpace(form.Get("day_" + key))
+		if preference == "" && legacy[key] {
+			preference = preferenceAvailable
+		}
+		switch preference {
+		case preferenceAvailable:
+			available = append(available, key)
+		case preferenceIfNeedBe:
+			ifNeedBe = append(ifNeedBe, key)
+		}
+	}
+	return available, ifNeedBe
 }
 
-func upcomingDaysFrom(start time.Time, count int) []DayOption {
-	start = startOfDayUTC(start)
-	options := make([]DayOption, 0, count)
-	for i := 0; i < count; i++ {
-		day := start.AddDate(0, 0, i)
-		options = append(options, DayOption{
-			Date:  day.Format("2006-01-02"),
-			Label: day.Format("Mon, Jan 2"),
-		})
+// optionKey returns the key responses store for a day and slot, or the day alone.
+func optionKey(day string, slotID string) string {
+	if slotID == "" {
+		return day
+	}
+	return day + "@" + slotID
+}
+
+func splitOptionKey(key string) (string, string) {
+	day, slotID, _ := strings.Cut(key, "@")
+	return day, slotID
+}
+
+func pollOptions(days []string, slots []TimeSlot) []string {
+	if len(slots) == 0 {
+		return days
+	}
+	options := make([]string, 0, len(days)*len(slots))
+	for _, day := range days {
+		for _, slot := range slots {
+			options = append(options, optionKey(day, slot.ID))
+		}
 	}
 	return options
 }
 
+// remapOptions moves saved selections onto new days and slots.
+func remapOptions(keys []string, days []string, slots []TimeSlot) []string {
+	selected := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		day, slotID := splitOptionKey(key)
+		switch {
+		case len(slots) == 0:
+			selected[day] = true
+		case slotID == "":
+			for _, slot := range slots {
+				selected[optionKey(day, slot.ID)] = true
+			}
+		default:
+			selected[key] = true
+		}
+	}
+	var remapped []string
+	for _, key := range pollOptions(days, slots) {
+		if selected[key] {
+			remapped = append(remapped, key)
+		}
+	}
+	return remapped
+}
+
+func (slot TimeSlot) Label() string {
+	times := ""
+	if slot.Start != "" && slot.End != "" {
+		times = slot.Start + "–" + slot.End
+	}
+	switch {
+	case slot.Name == "":
+		return times
+	case times == "":
+		return slot.Name
+	}
+	return slot.Name + " (" + times + ")"
+}
+
+func slotLabel(slots []TimeSlot, slotID string) string {
+	for _, slot := range slots {
+		if slot.ID == slotID {
+			return slot.Label()
+		}
+	}
+	return ""
+}
+
+func finalChoice(poll Poll, summaries []DaySummary, responses []Response) *FinalChoice {
+	if poll.FinalDay == "" {
+		return nil
+	}
+	day, slotID := splitOptionKey(poll.FinalDay)
+	final := &FinalChoice{Date: poll.FinalDay, Label: formatDate(day)}
+	if label := slotLabel(poll.Slots, slotID); label != "" {
+		final.Label += " · " + label
+	}
+	if venue := findVenueByID(poll.Venues, poll.FinalVenueID); venue != nil {
+		chosen := *venue
+		final.Venue = &chosen
+	}
+	for _, summary := range summaries {
+		if summary.Key == poll.FinalDay {
+			final.Names = summary.Names
+			final.IfNeedBeNames = summary.IfNeedBeNames
+		}
+	}
+	available := make(map[string]int, len(final.Names)+len(final.IfNeedBeNames))
+	for _, name := range append(cloneStrings(final.Names), final.IfNeedBeNames...) {
+		available[name]++
+	}
+	for _, response := range responses {
+		if available[response.Name] > 0 {
+			available[response.Name]--
+			continue
+		}
+		final.Missing = append(final.Missing, response.Name)
+	}
+	sort.Strings(final.Missing)
+	return final
+}
+
+// suggestedDay picks the default day to finalize once every option has elapsed.
+func suggestedDay(summaries []DaySummary) string {
+	best := -1
+	for i, summary := range summaries {
+		if best < 0 || betterDay(summary, summaries[best]) {
+			best = i
+		}
+	}
+	if best < 0 {
+		return ""
+	}
+	return summaries[best].Key
+}
+
+func betterDay(a DaySummary, b DaySummary) bool {
+	if a.Elapsed != b.Elapsed {
+		return !a.Elapsed
+	}
+	if dayTier(a) != dayTier(b) {
+		return dayTier(a) > dayTier(b)
+	}
+	return a.Score > b.Score
+}
+
+func dayTier(summary DaySummary) int {
+	switch {
+	case summary.AllAvailable:
+		return 2
+	case summary.AllIfNeedBe:
+		return 1
+	}
+	return 0
+}
+
+// recommendDays ranks the options that have not elapsed.
+func recommendDays(poll Poll, summaries []DaySummary, responses []Response) []DayRecommendation {
+	if len(responses) == 0 {
+		return nil
+	}
+	required := makeDaySet(poll.RequiredAttendees)
+	canMake := make([]map[string]bool, len(responses))
+	for i, response := range responses {
+		canMake[i] = makeDaySet(append(cloneStrings(response.Days), response.IfNeedBeDays...))
+	}
+	var recommendations []DayRecommendation
+	for _, summary := range summaries {
+		if summary.Elapsed {
+			continue
+		}
+		recommendation := DayRecommendation{Key: summary.Key, Label: summary.Label, Total: len(responses), IfNeedBe: len(summary.IfNeedBeNames)}
+		if summary.SlotLabel != "" {
+			recommendation.Label += " · " + summary.SlotLabel
+		}
+		for i, response := range responses {
+			if canMake[i][summary.Key] {
+				recommendation.Attending++
+				continue
+			}
+			recommendation.Missing = append(recommendation.Missing, response.Name)
+			if required[response.ID] {
+				recommendation.MissingRequired = append(recommendation.MissingRequired, response.Name)
+			}
+		}
+		sort.Strings(recommendation.Missing)
+		sort.Strings(recommendation.MissingRequired)
+		recommendation.MeetsQuorum = recommendation.Attending >= poll.Quorum
+		recommendation.Explanation = recommendationExplanation(recommendation, poll.Quorum)
+		recommendations = append(recommendations, recommendation)
+	}
+	sort.SliceStable(recommendations, func(i, j int) bool {
+		a, b := recommendations[i], recommendations[j]
+		if len(a.MissingRequired) != len(b.MissingRequired) {
+			return len(a.MissingRequired) < len(b.MissingRequired)
+		}
+		if a.MeetsQuorum != b.MeetsQuorum {
+			return a.MeetsQuorum
+		}
+		if a.Attending != b.Attending {
+			return a.Attending > b.Attending
+		}
+		return a.Attending-a.IfNeedBe > b.Attending-b.IfNeedBe
+	})
+	return recommendations
+}
+
+// summarizeWindows ranks the runs of tripLength consecutive days.
+func summarizeWindows(summaries []DaySummary, responses []Response, tripLength int) []WindowSummary {
+	if tripLength < 2 || len(responses) == 0 {
+		return nil
+	}
+	var days []string
+	keysByDay := make(map[string][]string)
+	elapsed := make(map[string]bool)
+	for _, summary := range summaries {
+		if _, ok := keysByDay[summary.Date]; !ok {
+			days = append(days, summary.Date)
+		}
+		keysByDay[summary.Date] = append(keysByDay[summary.Date], summary.Key)
+		elapsed[summary.Date] = elapsed[summary.Date] || summary.Elapsed
+	}
+	available := make([]map[string]bool, len(responses))
+	ifNeedBe := make([]map[string]bool, len(responses))
+	for i, response := range responses {
+		available[i] = makeDaySet(response.Days)
+		ifNeedBe[i] = makeDaySet(response.IfNeedBeDays)
+	}
+
+	var windows []WindowSummary
+	for start := 0; start+tripLength <= len(days); start++ {
+		window := days[start : start+tripLength]
+		if !consecutiveDays(window) || elapsed[window[0]] {
+			continue
+		}
+		summary := WindowSummary{
+			Start: window[0],
+			End:   window[len(window)-1],
+			Label: formatDate(window[0]) + " – " + formatDate(window[len(window)-1]),
+			Total: len(responses),
+		}
+		for i, response := range responses {
+			free, stretching := true, false
+			for _, day := range window {
+				for _, key := range keysByDay[day] {
+					switch {
+					case available[i][key]:
+					case ifNeedBe[i][key]:
+						stretching = true
+					default:
+						free = false
+					}
+				}
+			}
+			switch {
+			case !free:
+				summary.Missing = append(summary.Missing, response.Name)
+			case stretching:
+				summary.IfNeedBeNames = append(summary.IfNeedBeNames, response.Name)
+			default:
+				summary.Names = append(summary.Names, response.Name)
+			}
+		}
+		sort.Strings(summary.Names)
+		sort.Strings(summary.IfNeedBeNames)
+		sort.Strings(summary.Missing)
+		summary.Free = len(summary.Names) + len(summary.IfNeedBeNames)
+		summary.AllFree = summary.Free == summary.Total
+		windows = append(windows, summary)
+	}
+	sort.SliceStable(windows, func(i, j int) bool {
+		if windows[i].Free != windows[j].Free {
+			return windows[i].Free > windows[j].Free
+		}
+		return len(windows[i].Names) > len(windows[j].Names)
+	})
+	return windows
+}
+
+// consecutiveDays reports whether the sorted days follow each other on the calendar.
+func consecutiveDays(days []string) bool {
+	for i := 1; i < len(days); i++ {
+		previous, err := time.Parse("2006-01-02", days[i-1])
+		if err != nil || previous.AddDate(0, 0, 1).Format("2006-01-02") != days[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// recommendationExplanation returns text such as "6 of 8 (1 if need be); missing Jim, Judy".
+func recommendationExplanation(recommendation DayRecommendation, quorum int) string {
+	explanation := fmt.Sprintf("%d of %d", recommendation.Attending, recommendation.Total)
+	if recommendation.IfNeedBe > 0 {
+		explanation += fmt.Sprintf(" (%d if need be)", recommendation.IfNeedBe)
+	}
+	if len(recommendation.Missing) == 0 {
+		explanation += "; everyone can make it"
+	} else {
+		required := make(map[string]int, len(recommendation.MissingRequired))
+		for _, name := range recommendation.MissingRequired {
+			required[name]++
+		}
+		missing := make([]string, 0, len(recommendation.Missing))
+		for _, name := range recommendation.Missing {
+			if required[name] > 0 {
+				required[name]--
+				name += " (must attend)"
+			}
+			missing = append(missing, name)
+		}
+		explanation += "; missing " + strings.Join(missing, ", ")
+	}
+	if !recommendation.MeetsQuorum {
+		explanation += fmt.Sprintf("; short of the minimum of %d", quorum)
+	}
+	return explanation
+}
+
+func suggestedVenueID(summaries []VenueSummary) string {
+	if len(summaries) == 0 || summaries[0].VoteCount == 0 {
+		return ""
+	}
+	return summaries[0].Venue.ID
+}
+
+// calendarMonths returns the picker months, keeping chosen days selectable.
+func calendarMonths(now time.Time, chosen []string) []CalendarMonth {
+	today := dateOf(now)
+	last := today.AddDate(0, 0, maxDaysAhead)
+	current := time.Date(today.Year(), today.Month(), 1, 0, 0, 0, 0, time.UTC)
+	first := current
+	end := current.AddDate(0, calendarMonthsShown, 0)
+	for _, day := range chosen {
+		parsed, err := time.Parse("2006-01-02", day)
+		if err != nil {
+			continue
+		}
+		if parsed.Before(first) {
+			first = time.Date(parsed.Year(), parsed.Month(), 1, 0, 0, 0, 0, time.UTC)
+		}
+		if !parsed.Before(end) && !parsed.After(last) {
+			end = time.Date(parsed.Year(), parsed.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+		}
+	}
+	chosenSet := makeDaySet(chosen)
+	var months []CalendarMonth
+	for month := first; month.Before(end); month = month.AddDate(0, 1, 0) {
+		calendar := CalendarMonth{Key: month.Format("2006-01"), Label: month.Format("January 2006")}
+		week := make([]CalendarDay, int(month.Sub(weekStart(month)).Hours()/24))
+		for day := month; day.Month() == month.Month(); day = day.AddDate(0, 0, 1) {
+			date := day.Format("2006-01-02")
+			week = append(week, CalendarDay{
+				Date:     date,
+				Day:      day.Day(),
+				Disabled: (day.Before(today) || day.After(last)) && !chosenSet[date],
+			})
+			if len(week) == 7 {
+				calendar.Weeks = append(calendar.Weeks, week)
+				week = nil
+			}
+		}
+		if len(week) > 0 {
+			calendar.Weeks = append(calendar.Weeks, append(week, make([]CalendarDay, 7-len(week))...))
+		}
+		months = append(months, calendar)
+	}
+	return months
+}
+
+var weekdayValues = map[string]time.Weekday{
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+	"sun": time.Sunday,
+}
+
+func dateRangeFromForm(form url.Values, prefix string) DateRange {
+	return DateRange{
+		Start:    strings.TrimSpace(form.Get(prefix + "_start")),
+		End:      strings.TrimSpace(form.Get(prefix + "_end")),
+		Weekdays: makeDaySet(form[prefix+"_weekday"]),
+	}
+}
+
+// expandDateRange returns the range's days that fall on its weekdays.
+func expandDateRange(errs FieldErrors, field string, dateRange DateRange) []string {
+	if dateRange.Start == "" && dateRange.End == "" {
+		return nil
+	}
+	start, startErr := time.Parse("2006-01-02", dateRange.Start)
+	end, endErr := time.Parse("2006-01-02", dateRange.End)
+	switch {
+	case startErr != nil || endErr != nil:
+		errs.add(field, "Give the range both a start and an end date.")
+		return nil
+	case end.Before(start):
+		errs.add(field, "The range must end on or after its start.")
+		return nil
+	case end.After(start.AddDate(0, 0, maxDaysAhead)):
+		errs.add(field, fmt.Sprintf("Ranges can cover at most %d days.", maxDaysAhead))
+		return nil
+	}
+	weekdays := make(map[time.Weekday]bool, len(dateRange.Weekdays))
+	for name := range dateRange.Weekdays {
+		weekday, ok := weekdayValues[name]
+		if !ok {
+			errs.add(field, fmt.Sprintf("%q is not a weekday.", name))
+			return nil
+		}
+		weekdays[weekday] = true
+	}
+	var days []string
+	for day := start; !day.After(end); day = day.AddDate(0, 0, 1) {
+		if len(weekdays) == 0 || weekdays[day.Weekday()] {
+			days = append(days, day.Format("2006-01-02"))
+		}
+	}
+	if len(days) == 0 {
+		errs.add(field, "None of the days in that range fall on the chosen weekdays.")
+	}
+	return days
+}
+
+func (e FieldErrors) Error() string {
+	fields := make([]string, 0, len(e))
+	for field := range e {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	messages := make([]string, 0, len(fields))
+	for _, field := range fields {
+		messages = append(messages, e[field])
+	}
+	return strings.Join(messages, " ")
+}
+
+// add records message unless field already has one.
+func (e FieldErrors) add(field string, message string) {
+	if _, ok := e[field]; !ok {
+		e[field] = message
+	}
+}
+
+func (e FieldErrors) merge(err error) {
+	var fieldErrs FieldErrors
+	if errors.As(err, &fieldErrs) {
+		for field, message := range fieldErrs {
+			e.add(field, message)
+		}
+	}
+}
+
+func (e FieldErrors) err() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
+
+func validateText(errs FieldErrors, field string, label string, value string, limit int) {
+	if value == "" {
+		errs.add(field, label+" is required.")
+		return
+	}
+	if utf8.RuneCountInString(value) > limit {
+		errs.add(field, fmt.Sprintf("%s must be at most %d characters.", label, limit))
+	}
+}
+
+// validatePollDays validates new days; kept days are accepted as they are.
+func validatePollDays(errs FieldErrors, field string, days []string, kept []string, now time.Time) {
+	if len(days) == 0 {
+		errs.add(field, "Pick at least one day.")
+		return
+	}
+	if len(days) > maxPollDays {
+		errs.add(field, fmt.Sprintf("Pick at most %d days.", maxPollDays))
+		return
+	}
+	keptSet := makeDaySet(kept)
+	today := dateOf(now)
+	// now is already in the poll's time zone.
+	first := today
+	last := today.AddDate(0, 0, maxDaysAhead)
+	for _, day := range days {
+		if keptSet[day] {
+			continue
+		}
+		parsed, err := time.Parse("2006-01-02", day)
+		if err != nil {
+			errs.add(field, fmt.Sprintf("%q is not a valid date.", day))
+			return
+		}
+		if parsed.Before(first) || parsed.After(last) {
+			errs.add(field, fmt.Sprintf("Days must be between %s and %s.", formatDate(first.Format("2006-01-02")), formatDate(last.Format("2006-01-02"))))
+			return
+		}
+	}
+}
+
+func validateVenues(errs FieldErrors, field string, venues []Venue) {
+	if len(venues) > maxPollVenues {
+		errs.add(field, fmt.Sprintf("Add at most %d venues or activities.", maxPollVenues))
+		return
+	}
+	for _, venue := range venues {
+		validateVenue(errs, field, venue)
+	}
+}
+
+func validateVenue(errs FieldErrors, field string, venue Venue) {
+	switch {
+	case venue.Title == "":
+		errs.add(field, "Each venue or activity needs a title.")
+	case utf8.RuneCountInString(venue.Title) > maxVenueTitleLength:
+		errs.add(field, fmt.Sprintf("Venue titles must be at most %d characters.", maxVenueTitleLength))
+	case len(venue.URL) > maxVenueURLLength:
+		errs.add(field, fmt.Sprintf("Venue links must be at most %d characters.", maxVenueURLLength))
+	case venue.URL != "" && !isWebURL(venue.URL):
+		errs.add(field, "Venue links must start with http:// or https://.")
+	case utf8.RuneCountInString(venue.Description) > maxVenueDescriptionLength:
+		errs.add(field, fmt.Sprintf("Venue descriptions must be at most %d characters.", maxVenueDescriptionLength))
+	}
+}
+
+func validateSlots(errs FieldErrors, field string, slots []TimeSlot) {
+	if len(slots) > maxPollSlots {
+		errs.add(field, fmt.Sprintf("Add at most %d time slots.", maxPollSlots))
+		return
+	}
+	for _, slot := range slots {
+		validateSlot(errs, field, slot)
+	}
+}
+
+// validateSlot requires a name, a start and end time, or both.
+func validateSlot(errs FieldErrors, field string, slot TimeSlot) {
+	start, startErr := time.Parse("15:04", slot.Start)
+	end, endErr := time.Parse("15:04", slot.End)
+	switch {
+	case utf8.RuneCountInString(slot.Name) > maxSlotNameLength:
+		errs.add(field, fmt.Sprintf("Time slot names must be at most %d characters.", maxSlotNameLength))
+	case slot.Start == "" && slot.End == "" && slot.Name == "":
+		errs.add(field, "Each time slot needs a name or start and end times.")
+	case slot.Start == "" && slot.End == "":
+	case startErr != nil || endErr != nil:
+		errs.add(field, "Give time slots both a start and an end time, like 18:00.")
+	case !end.After(start):
+		errs.add(field, "Time slots must end after they start.")
+	}
+}
+
+func isWebURL(raw string) bool {
+	parsed, err := url.Parse(raw)
+	if err != nil || parsed.Host == "" {
+		return false
+	}
+	scheme := strings.ToLower(parsed.Scheme)
+	return scheme == "http" || scheme == "https"
+}
+
 func normalizeDays(input []string) []string {
 	seen := make(map[string]struct{})
 	var days []string
@@ -1016,9 +4414,6 @@ func parseVenuesFromForm(ids []string, titles []string, urls []string, descripti
 		if title == "" && url == "" && description == "" {
 			continue
 		}
-		if title == "" {
-			return nil, errors.New("each venue or activity needs a title")
-		}
 		if id != "" {
 			if _, ok := seenIDs[id]; ok {
 				id = ""
@@ -1040,7 +4435,38 @@ func parseVenuesFromForm(ids []string, titles []string, urls []string, descripti
 			Description: description,
 		})
 	}
-	return venues, nil
+	errs := FieldErrors{}
+	validateVenues(errs, "venues", venues)
+	return venues, errs.err()
+}
+
+func parseSlotsFromForm(ids []string, names []string, starts []string, ends []string, existing []TimeSlot) ([]TimeSlot, error) {
+	existingIDs := make(map[string]bool, len(existing))
+	for _, slot := range existing {
+		existingIDs[slot.ID] = true
+	}
+	maxLen := maxInt(len(ids), len(names), len(starts), len(ends))
+	slots := make([]TimeSlot, 0, maxLen)
+	seenIDs := make(map[string]bool)
+	for i := 0; i < maxLen; i++ {
+		slot := TimeSlot{
+			ID:    strings.TrimSpace(valueAt(ids, i)),
+			Name:  strings.TrimSpace(valueAt(names, i)),
+			Start: strings.TrimSpace(valueAt(starts, i)),
+			End:   strings.TrimSpace(valueAt(ends, i)),
+		}
+		if slot.Name == "" && slot.Start == "" && slot.End == "" {
+			continue
+		}
+		if !existingIDs[slot.ID] || seenIDs[slot.ID] {
+			slot.ID = randomID()
+		}
+		seenIDs[slot.ID] = true
+		slots = append(slots, slot)
+	}
+	errs := FieldErrors{}
+	validateSlots(errs, "edit_slots", slots)
+	return slots, errs.err()
 }
 
 func addVenueWriteIn(existing []Venue, title string, url string, description string) ([]Venue, string, error) {
@@ -1050,11 +4476,8 @@ func addVenueWriteIn(existing []Venue, title string, url string, description str
 	if title == "" && url == "" && description == "" {
 		return existing, "", nil
 	}
-	if title == "" {
-		return existing, "", errors.New("venue suggestions need a title")
-	}
 	for _, venue := range existing {
-		if strings.EqualFold(strings.TrimSpace(venue.Title), title) {
+		if title != "" && strings.EqualFold(strings.TrimSpace(venue.Title), title) {
 			return existing, venue.ID, nil
 		}
 	}
@@ -1064,6 +4487,14 @@ func addVenueWriteIn(existing []Venue, title string, url string, description str
 		URL:         url,
 		Description: description,
 	}
+	errs := FieldErrors{}
+	validateVenue(errs, "write_in_venue", venue)
+	if len(existing) >= maxPollVenues {
+		errs.add("write_in_venue", fmt.Sprintf("This poll already has the maximum of %d venues or activities.", maxPollVenues))
+	}
+	if err := errs.err(); err != nil {
+		return existing, "", err
+	}
 	updated := append(cloneVenues(existing), venue)
 	return updated, venue.ID, nil
 }
@@ -1104,20 +4535,6 @@ func makeVenueSet(venues []Venue) map[string]Venue {
 	return set
 }
 
-func filterDays(selected []string, allowed []string) []string {
-	if len(selected) == 0 {
-		return selected
-	}
-	allowedSet := makeDaySet(allowed)
-	filtered := make([]string, 0, len(selected))
-	for _, day := range selected {
-		if allowedSet[day] {
-			filtered = append(filtered, day)
-		}
-	}
-	return filtered
-}
-
 func filterVenueVotes(selected []string, venues []Venue) []string {
 	if len(selected) == 0 {
 		return selected
@@ -1153,23 +4570,69 @@ func diffDays(oldDays []string, newDays []string) []string {
 			added = append(added, day)
 		}
 	}
-	return added
+	return added
+}
+
+// responsesForUpdatedVenues returns the responses with votes for removed venues dropped.
+func responsesForUpdatedVenues(responses []Response, venues []Venue) []Response {
+	var changed []Response
+	for _, response := range responses {
+		filtered := filterVenueVotes(response.VenueVotes, venues)
+		if !equalDays(response.VenueVotes, filtered) {
+			response.VenueVotes = filtered
+			changed = append(changed, response)
+		}
+	}
+	return changed
+}
+
+// responsesForUpdatedDays returns the responses whose answers change with the new days and slots.
+func responsesForUpdatedDays(poll Poll, responses []Response, updatedDays []string, updatedSlots []TimeSlot) []Response {
+	addedOptions := pollOptions(diffDays(poll.Days, updatedDays), updatedSlots)
+	var changed []Response
+	for _, response := range responses {
+		filtered := remapOptions(onPollDays(response.Days, poll.Days), updatedDays, updatedSlots)
+		creator := isCreator(poll, response.UserToken)
+		if creator && len(addedOptions) > 0 {
+			filtered = remapOptions(append(filtered, addedOptions...), updatedDays, updatedSlots)
+		}
+		available := makeDaySet(filtered)
+		var filteredIfNeedBe []string
+		for _, key := range remapOptions(onPollDays(response.IfNeedBeDays, poll.Days), updatedDays, updatedSlots) {
+			if !available[key] {
+				filteredIfNeedBe = append(filteredIfNeedBe, key)
+			}
+		}
+		if equalDays(response.Days, filtered) && equalDays(response.IfNeedBeDays, filteredIfNeedBe) {
+			continue
+		}
+		response.Days = filtered
+		response.IfNeedBeDays = filteredIfNeedBe
+		if creator {
+			changed = append([]Response{response}, changed...)
+			continue
+		}
+		changed = append(changed, response)
+	}
+	return changed
+}
+
+func onPollDays(keys []string, days []string) []string {
+	pollDays := makeDaySet(days)
+	var kept []string
+	for _, key := range keys {
+		if day, _ := splitOptionKey(key); pollDays[day] {
+			kept = append(kept, key)
+		}
+	}
+	return kept
 }
 
-func mergeDays(days []string, added []string) []string {
-	if len(added) == 0 {
-		return days
-	}
-	seen := makeDaySet(days)
-	for _, day := range added {
-		seen[day] = true
-	}
-	merged := make([]string, 0, len(seen))
-	for day := range seen {
-		merged = append(merged, day)
+func pollEditSlots(slots []TimeSlot) []TimeSlot {
+	if len(slots) == 0 {
+		return []TimeSlot{{}}
 	}
-	sort.Strings(merged)
-	return merged
+	return cloneSlots(slots)
 }
 
 func pollEditVenues(venues []Venue) []Venue {
@@ -1185,6 +4648,48 @@ func cloneVenues(venues []Venue) []Venue {
 	return cloned
 }
 
+func cloneSlots(slots []TimeSlot) []TimeSlot {
+	if slots == nil {
+		return nil
+	}
+	return append([]TimeSlot(nil), slots...)
+}
+
+func cloneStrings(values []string) []string {
+	if values == nil {
+		return nil
+	}
+	return append([]string(nil), values...)
+}
+
+func clonePoll(poll Poll) Poll {
+	poll.Days = cloneStrings(poll.Days)
+	poll.RequiredAttendees = cloneStrings(poll.RequiredAttendees)
+	poll.Slots = cloneSlots(poll.Slots)
+	if poll.Venues != nil {
+		poll.Venues = cloneVenues(poll.Venues)
+	}
+	return poll
+}
+
+func cloneResponse(response Response) Response {
+	response.Days = cloneStrings(response.Days)
+	response.IfNeedBeDays = cloneStrings(response.IfNeedBeDays)
+	response.VenueVotes = cloneStrings(response.VenueVotes)
+	return response
+}
+
+func cloneResponses(responses []Response) []Response {
+	if responses == nil {
+		return nil
+	}
+	cloned := make([]Response, len(responses))
+	for i, response := range responses {
+		cloned[i] = cloneResponse(response)
+	}
+	return cloned
+}
+
 func summarizeVenueVotes(venues []Venue, responses []Response) []VenueSummary {
 	if len(venues) == 0 {
 		return nil
@@ -1236,31 +4741,45 @@ func maxInt(values ...int) int {
 	return maxValue
 }
 
-func pollEditDays(days []string) []DayOption {
-	start := startOfDayUTC(time.Now().UTC())
-	maxDay := start
-	for _, day := range days {
-		parsed, err := time.Parse("2006-01-02", day)
-		if err != nil {
-			continue
-		}
-		parsed = startOfDayUTC(parsed)
-		if parsed.After(maxDay) {
-			maxDay = parsed
-		}
+// dateOf returns value's calendar date as midnight UTC.
+func dateOf(value time.Time) time.Time {
+	return time.Date(value.Year(), value.Month(), value.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// pollLocation returns the poll's time zone, UTC for polls created without one.
+func pollLocation(poll Poll) *time.Location {
+	location, err := loadTimeZone(poll.TimeZone)
+	if err != nil {
+		return time.UTC
 	}
-	count := 14
-	if maxDay.After(start.AddDate(0, 0, count-1)) {
-		diff := int(maxDay.Sub(start).Hours()/24) + 1
-		if diff > count {
-			count = diff
-		}
+	return location
+}
+
+func pollNow(poll Poll) time.Time {
+	return time.Now().In(pollLocation(poll))
+}
+
+func loadTimeZone(name string) (*time.Location, error) {
+	if name == "" {
+		return time.UTC, nil
 	}
-	return upcomingDaysFrom(start, count)
+	if name == "Local" || len(name) > maxTimeZoneLength {
+		return nil, fmt.Errorf("unknown time zone %q", name)
+	}
+	return time.LoadLocation(name)
 }
 
-func startOfDayUTC(value time.Time) time.Time {
-	return time.Date(value.Year(), value.Month(), value.Day(), 0, 0, 0, 0, time.UTC)
+func validateTimeZone(errs FieldErrors, field string, name string) {
+	if _, err := loadTimeZone(name); err != nil {
+		errs.add(field, "Pick a time zone such as America/Los_Angeles or Europe/Berlin.")
+	}
+}
+
+func timeZoneLabel(name string) string {
+	if name == "" {
+		return "UTC"
+	}
+	return name
 }
 
 func randomID() string {
@@ -1291,6 +4810,37 @@ func parseTime(value string) time.Time {
 	return parsed
 }
 
+// parseOptionalTime returns the zero time for an empty string.
+func parseOptionalTime(value string) time.Time {
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}
+	}
+	return parsed
+}
+
+func formatOptionalTime(value time.Time) string {
+	if value.IsZero() {
+		return ""
+	}
+	return value.UTC().Format(time.RFC3339)
+}
+
+// optionalUnix returns value in Unix seconds, or 0 when unset.
+func optionalUnix(value time.Time) int64 {
+	if value.IsZero() {
+		return 0
+	}
+	return value.Unix()
+}
+
+func parseOptionalUnix(value int64) time.Time {
+	if value == 0 {
+		return time.Time{}
+	}
+	return time.Unix(value, 0).UTC()
+}
+
 func homeMessage(r *http.Request) string {
 	if r.URL.Query().Get("invalid") == "1" {
 		return "That link was invalid. Start a new poll below."
@@ -1313,18 +4863,339 @@ func parsePollPath(path string) (string, string) {
 	return parts[0], ""
 }
 
+func pollManageToken(path string) string {
+	parts := strings.Split(strings.TrimPrefix(path, "/poll/"), "/")
+	if len(parts) == 3 && parts[1] == "manage" {
+		return parts[2]
+	}
+	return ""
+}
+
 func pollPartitionKey(id string) string {
 	return "POLL#" + id
 }
 
+func statsKey() map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"pk": &types.AttributeValueMemberS{Value: "STATS"},
+		"sk": &types.AttributeValueMemberS{Value: "STATS"},
+	}
+}
+
+// statsShardKey returns the totals item key for shard; shard 0 is the unsharded item.
+func statsShardKey(shard int) map[string]types.AttributeValue {
+	if shard == 0 {
+		return statsKey()
+	}
+	return map[string]types.AttributeValue{
+		"pk": &types.AttributeValueMemberS{Value: "STATS"},
+		"sk": &types.AttributeValueMemberS{Value: fmt.Sprintf("STATS#%d", shard)},
+	}
+}
+
+func statsDayKey(day string, shard int) map[string]types.AttributeValue {
+	sk := "DAY#" + day
+	if shard > 0 {
+		sk += fmt.Sprintf("#%d", shard)
+	}
+	return map[string]types.AttributeValue{
+		"pk": &types.AttributeValueMemberS{Value: "STATS"},
+		"sk": &types.AttributeValueMemberS{Value: sk},
+	}
+}
+
+// pollClosed reports whether the poll is closed, past its deadline, finalized or archived.
+func pollClosed(poll Poll, now time.Time) bool {
+	return poll.FinalDay != "" || !poll.ClosedAt.IsZero() || !poll.ArchivedAt.IsZero() || (!poll.Deadline.IsZero() && !now.Before(poll.Deadline))
+}
+
+// pollClosedTo reports whether a closed poll rejects actions from the given role.
+func pollClosedTo(poll Poll, now time.Time, role pollRole) bool {
+	switch role {
+	case roleCreator:
+		return false
+	case roleOrganizer:
+		poll.FinalDay = ""
+	}
+	return pollClosed(poll, now)
+}
+
+func pollClosedMessage(poll Poll, now time.Time) string {
+	switch {
+	case !poll.ArchivedAt.IsZero():
+		return "This poll ended on " + formatDate(lastPollDay(poll)) + " and has been archived. Responses can no longer be changed."
+	case poll.FinalDay != "":
+		return "The organizer picked a date for this poll. Responses can no longer be changed."
+	case !poll.ClosedAt.IsZero():
+		return "The organizer closed this poll on " + formatDeadline(poll.ClosedAt, pollLocation(poll)) + ". Responses can no longer be changed."
+	case pollClosed(poll, now):
+		return "Responses closed on " + formatDeadline(poll.Deadline, pollLocation(poll)) + ". Responses can no longer be changed."
+	}
+	return ""
+}
+
+// pollEnded reports whether the poll's last day is over in its time zone.
+func pollEnded(poll Poll, now time.Time) bool {
+	last := lastPollDay(poll)
+	return last != "" && last < now.In(pollLocation(poll)).Format("2006-01-02")
+}
+
+func lastPollDay(poll Poll) string {
+	last := ""
+	for _, day := range poll.Days {
+		last = max(last, day)
+	}
+	return last
+}
+
+// pollExpiry returns when a poll with days is deleted, or zero when it never is.
+func pollExpiry(days []string, retention time.Duration) time.Time {
+	last := lastPollDay(Poll{Days: days})
+	day, err := time.Parse("2006-01-02", last)
+	if retention <= 0 || err != nil {
+		return time.Time{}
+	}
+	return day.AddDate(0, 0, 2).Add(retention).Truncate(time.Second)
+}
+
+// archiveEndedPoll archives the poll if it has ended, returning the poll to show.
+func (a *App) archiveEndedPoll(ctx context.Context, poll Poll, now time.Time) Poll {
+	if !poll.ArchivedAt.IsZero() || !pollEnded(poll, now) {
+		return poll
+	}
+	archivedAt := now.UTC().Truncate(time.Second)
+	if err := a.storage.ArchivePoll(ctx, poll.ID, poll.Version, archivedAt); err != nil {
+		log.Printf("failed to archive poll %s: %v", poll.ID, err)
+		return poll
+	}
+	poll.ArchivedAt = archivedAt
+	poll.Version++
+	return poll
+}
+
+// parseQuorum returns 0 for an empty quorum.
+func parseQuorum(errs FieldErrors, value string) int {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+	quorum, err := strconv.Atoi(value)
+	if err != nil || quorum < 0 || quorum > maxPollResponses {
+		errs.add("quorum", fmt.Sprintf("Enter a whole number of people from 0 to %d.", maxPollResponses))
+		return 0
+	}
+	return quorum
+}
+
+// parseTripLength returns 0 for an empty trip length.
+func parseTripLength(errs FieldErrors, value string) int {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+	tripLength, err := strconv.Atoi(value)
+	if err != nil || (tripLength != 0 && (tripLength < 2 || tripLength > maxTripLength)) {
+		errs.add("trip_length", fmt.Sprintf("Enter a number of days from 2 to %d, or leave it empty for single days.", maxTripLength))
+		return 0
+	}
+	return tripLength
+}
+
+// countInput shows an unset count as an empty input.
+func countInput(count int) string {
+	if count == 0 {
+		return ""
+	}
+	return strconv.Itoa(count)
+}
+
+// parseDeadline reads a datetime-local value in the poll's time zone.
+func parseDeadline(errs FieldErrors, value string, now time.Time, location *time.Location) time.Time {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}
+	}
+	deadline, err := time.ParseInLocation("2006-01-02T15:04", value, location)
+	if err != nil {
+		errs.add("deadline", "Enter the deadline as a date and time.")
+		return time.Time{}
+	}
+	if deadline.After(now.AddDate(0, 0, maxDaysAhead)) {
+		errs.add("deadline", fmt.Sprintf("The deadline must be within %d days.", maxDaysAhead))
+		return time.Time{}
+	}
+	return deadline.UTC()
+}
+
+func formatDeadline(value time.Time, location *time.Location) string {
+	if value.IsZero() {
+		return ""
+	}
+	return value.In(location).Format("Mon, Jan 2 at 15:04 MST")
+}
+
+func deadlineInput(value time.Time, location *time.Location) string {
+	if value.IsZero() {
+		return ""
+	}
+	return value.In(location).Format("2006-01-02T15:04")
+}
+
 func isHTMX(r *http.Request) bool {
 	return r.Header.Get("HX-Request") == "true"
 }
 
+type pollRole int
+
+const (
+	roleResponder pollRole = iota
+	roleOrganizer
+	roleCreator
+)
+
+// actionRoles maps each poll action to the minimum role that can run it.
+var actionRoles = map[string]pollRole{
+	"delete-response":     roleOrganizer,
+	"update-dates":        roleOrganizer,
+	"update-venues":       roleOrganizer,
+	"duplicate-poll":      roleCreator,
+	"rotate-creator-link": roleCreator,
+	"reset-recovery-code": roleCreator,
+	"promote-organizer":   roleCreator,
+	"revoke-organizer":    roleCreator,
+	"require-attendee":    roleCreator,
+	"unrequire-attendee":  roleCreator,
+	"set-quorum":          roleCreator,
+	"set-trip-length":     roleCreator,
+	"close-poll":          roleCreator,
+	"reopen-poll":         roleCreator,
+	"set-deadline":        roleCreator,
+	"finalize-poll":       roleOrganizer,
+	"unfinalize-poll":     roleOrganizer,
+	"update-time-zone":    roleCreator,
+}
+
+// pollRoleFor returns the viewer's role, decided by the management secret.
+func pollRoleFor(poll Poll, responses []Response, userToken string, manageSecret string) pollRole {
+	if isPollAdmin(poll, manageSecret) || (poll.AdminToken == "" && isPollAdmin(poll, userToken)) {
+		return roleCreator
+	}
+	if organizer := findResponseByOrganizerToken(responses, manageSecret); organizer != nil && organizer.UserToken == userToken {
+		return roleOrganizer
+	}
+	return roleResponder
+}
+
+// findResponseByOrganizerToken returns the response holding the co-organizer secret.
+func findResponseByOrganizerToken(responses []Response, token string) *Response {
+	if token == "" {
+		return nil
+	}
+	for i := range responses {
+		if responses[i].OrganizerToken != "" && subtle.ConstantTimeCompare([]byte(responses[i].OrganizerToken), []byte(token)) == 1 {
+			return &responses[i]
+		}
+	}
+	return nil
+}
+
+// requiredResponses returns the must-attend responses that still exist.
+func requiredResponses(poll Poll, responses []Response) map[string]bool {
+	listed := makeDaySet(poll.RequiredAttendees)
+	required := make(map[string]bool)
+	for _, response := range responses {
+		if listed[response.ID] {
+			required[response.ID] = true
+		}
+	}
+	return required
+}
+
+func organizerResponses(responses []Response) map[string]bool {
+	organizers := make(map[string]bool)
+	for _, response := range responses {
+		if response.OrganizerToken != "" {
+			organizers[response.ID] = true
+		}
+	}
+	return organizers
+}
+
+func findResponseByID(responses []Response, id string) *Response {
+	if id == "" {
+		return nil
+	}
+	for i := range responses {
+		if responses[i].ID == id {
+			return &responses[i]
+		}
+	}
+	return nil
+}
+
+func findVenueByID(venues []Venue, id string) *Venue {
+	if id == "" {
+		return nil
+	}
+	for i := range venues {
+		if venues[i].ID == id {
+			return &venues[i]
+		}
+	}
+	return nil
+}
+
+func removeString(values []string, target string) []string {
+	var kept []string
+	for _, value := range values {
+		if value != target {
+			kept = append(kept, value)
+		}
+	}
+	return kept
+}
+
+// pollAdminToken returns the admin secret, the creator token on legacy polls.
+func pollAdminToken(poll Poll) string {
+	if poll.AdminToken != "" {
+		return poll.AdminToken
+	}
+	return poll.CreatorToken
+}
+
+func isPollAdmin(poll Poll, token string) bool {
+	adminToken := pollAdminToken(poll)
+	return adminToken != "" && subtle.ConstantTimeCompare([]byte(adminToken), []byte(token)) == 1
+}
+
+// manageToken returns the verified management secret for the viewer, or "".
+func manageToken(poll Poll, userToken string, manageSecret string) string {
+	if manageSecret != "" {
+		return manageSecret
+	}
+	if poll.AdminToken == "" && isPollAdmin(poll, userToken) {
+		return userToken
+	}
+	return ""
+}
+
+func pollManagePath(poll Poll) string {
+	return fmt.Sprintf("/poll/%s/manage/%s", poll.ID, pollAdminToken(poll))
+}
+
 func isCreator(poll Poll, token string) bool {
 	return poll.CreatorToken != "" && poll.CreatorToken == token
 }
 
+func writeUpdateError(w http.ResponseWriter, err error, logMessage string) {
+	if errors.Is(err, errConflict) {
+		http.Error(w, "this poll was just changed by someone else, reload the page and try again", http.StatusConflict)
+		return
+	}
+	log.Printf("%s: %v", logMessage, err)
+	http.Error(w, "unable to update poll", http.StatusInternalServerError)
+}
+
 func schemeForRequest(r *http.Request) string {
 	if r.TLS != nil {
 		return "https"
@@ -1339,6 +5210,82 @@ func pollCookieName(pollID string) string {
 	return "bffhang_" + pollID
 }
 
+// newRecoveryCode returns a random 128-bit recovery code.
+func newRecoveryCode() string {
+	code := randomID()
+	var groups []string
+	for len(code) > 4 {
+		groups = append(groups, code[:4])
+		code = code[4:]
+	}
+	return strings.Join(append(groups, code), "-")
+}
+
+func hashRecoveryCode(code string) string {
+	normalized := strings.Map(func(r rune) rune {
+		if r == '-' || unicode.IsSpace(r) {
+			return -1
+		}
+		return unicode.ToUpper(r)
+	}, code)
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
+
+func matchesRecoveryCode(poll Poll, code string) bool {
+	if poll.RecoveryHash == "" || strings.TrimSpace(code) == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(poll.RecoveryHash), []byte(hashRecoveryCode(code))) == 1
+}
+
+func recoveryCookieName(pollID string) string {
+	return "bffhang_recovery_" + pollID
+}
+
+// setRecoveryCodeCookie passes a new recovery code to the management page to show once.
+func setRecoveryCodeCookie(w http.ResponseWriter, r *http.Request, pollID string, code string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     recoveryCookieName(pollID),
+		Value:    code,
+		Path:     "/poll/" + pollID,
+		MaxAge:   300,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+		Secure:   schemeForRequest(r) == "https",
+	})
+}
+
+func popRecoveryCode(w http.ResponseWriter, r *http.Request, pollID string) string {
+	cookie, err := r.Cookie(recoveryCookieName(pollID))
+	if err != nil {
+		return ""
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     recoveryCookieName(pollID),
+		Value:    "",
+		Path:     "/poll/" + pollID,
+		MaxAge:   -1,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+		Secure:   schemeForRequest(r) == "https",
+	})
+	return cookie.Value
+}
+
+// timeZoneCookie returns the browser time zone stored by the home page script.
+func timeZoneCookie(r *http.Request) string {
+	cookie, err := r.Cookie(timeZoneCookieName)
+	if err != nil {
+		return ""
+	}
+	name := strings.TrimSpace(cookie.Value)
+	if _, err := loadTimeZone(name); err != nil {
+		return ""
+	}
+	return name
+}
+
 func userTokenFromCookie(r *http.Request, pollID string) string {
 	cookie, err := r.Cookie(pollCookieName(pollID))
 	if err != nil {
@@ -1366,6 +5313,66 @@ func awsString(value string) *string {
 	return &value
 }
 
+func awsBool(value bool) *bool {
+	return &value
+}
+
+// versionCondition matches version, with a missing version counting as 0.
+func versionCondition(version int) string {
+	if version == 0 {
+		return "(attribute_not_exists(#version) OR #version = :version)"
+	}
+	return "#version = :version"
+}
+
+func versionAttributeNames() map[string]string {
+	return map[string]string{"#version": "version"}
+}
+
+func versionAttributeValues(version int) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		":version": &types.AttributeValueMemberN{Value: fmt.Sprint(version)},
+	}
+}
+
+func isConditionFailed(err error) bool {
+	var conditionErr *types.ConditionalCheckFailedException
+	return errors.As(err, &conditionErr)
+}
+
+func transactionConditionFailed(err error, index int) bool {
+	reason := cancellationReason(err, index)
+	return reason != nil && reason.Code != nil && *reason.Code == "ConditionalCheckFailed"
+}
+
+func isTransactionConflict(err error) bool {
+	var canceledErr *types.TransactionCanceledException
+	if !errors.As(err, &canceledErr) {
+		return false
+	}
+	conflict := false
+	for _, reason := range canceledErr.CancellationReasons {
+		if reason.Code == nil {
+			continue
+		}
+		switch *reason.Code {
+		case "ConditionalCheckFailed":
+			return false
+		case "TransactionConflict":
+			conflict = true
+		}
+	}
+	return conflict
+}
+
+func cancellationReason(err error, index int) *types.CancellationReason {
+	var canceledErr *types.TransactionCanceledException
+	if !errors.As(err, &canceledErr) || index >= len(canceledErr.CancellationReasons) {
+		return nil
+	}
+	return &canceledErr.CancellationReasons[index]
+}
+
 func stringSliceAttribute(values []string) []types.AttributeValue {
 	attrs := make([]types.AttributeValue, 0, len(values))
 	for _, value := range values {
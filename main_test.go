@@ -2,24 +2,39 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"html/template"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"testing"
 	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"golang.org/x/crypto/bcrypt"
 )
 
 func testTemplates(t *testing.T) *template.Template {
 	t.Helper()
 	const templates = `
-{{define "home.html"}}home {{.Message}}{{end}}
-{{define "poll.html"}}poll {{.Poll.Title}} {{.Error}}{{end}}
+{{define "home.html"}}home {{.Message}}{{range $field, $message := .Errors}} {{$field}}={{$message}}{{end}}{{end}}
+{{define "poll.html"}}poll {{.Poll.Title}} {{.Error}}{{if .RecoveryCode}} recovery={{.RecoveryCode}}{{end}}{{range $field, $message := .Errors}} {{$field}}={{$message}}{{end}}{{end}}
 {{define "results.html"}}results {{.Poll.Title}} {{.Error}}{{end}}
 {{define "stats.html"}}stats {{.PollCount}} {{.ResponseCount}}{{end}}
+{{define "recover.html"}}recover {{.PollID}} error={{.Error}}{{end}}
+{{define "error.html"}}error {{.Title}}{{end}}
+{{define "admin_login.html"}}login next={{.Next}} error={{.Error}}{{end}}
 `
 	tmpl, err := template.New("").Funcs(templateFuncs).Parse(templates)
 	if err != nil {
@@ -38,6 +53,7 @@ func newTestApp(t *testing.T) (*App, *MemoryStorage) {
 		storage:   storage,
 		templates: testTemplates(t),
 		baseURL:   "",
+		csrfKey:   []byte("test-csrf-key"),
 	}
 	return app, storage
 }
@@ -48,6 +64,11 @@ func newFormRequest(method, target string, form url.Values) *http.Request {
 	return req
 }
 
+// Creating polls only accepts days near today, so handler tests build them relative to now.
+func daysFromToday(offset int) string {
+	return time.Now().UTC().AddDate(0, 0, offset).Format("2006-01-02")
+}
+
 func TestNormalizeDays(t *testing.T) {
 	input := []string{"2024-01-02", "", "2024-01-01", "2024-01-02", " 2024-01-03 "}
 	got := normalizeDays(input)
@@ -57,23 +78,45 @@ func TestNormalizeDays(t *testing.T) {
 	}
 }
 
-func TestFilterDiffMergeDays(t *testing.T) {
-	selected := []string{"2024-01-01", "2024-01-02", "2024-01-03"}
-	allowed := []string{"2024-01-01", "2024-01-03"}
-	filtered := filterDays(selected, allowed)
-	wantFiltered := []string{"2024-01-01", "2024-01-03"}
-	if !equalDays(filtered, wantFiltered) {
-		t.Fatalf("expected %v, got %v", wantFiltered, filtered)
-	}
-
+func TestDiffDays(t *testing.T) {
 	added := diffDays([]string{"2024-01-01"}, []string{"2024-01-01", "2024-01-02"})
 	if !equalDays(added, []string{"2024-01-02"}) {
 		t.Fatalf("expected added day, got %v", added)
 	}
+}
+
+func TestResponsesForUpdatedDays(t *testing.T) {
+	poll := Poll{Days: []string{"2024-01-01", "2024-01-02"}, CreatorToken: "creator"}
+	responses := []Response{
+		{ID: "sam", Days: []string{"2024-01-01", "2024-01-02"}, UserToken: "sam"},
+		{ID: "kim", Days: []string{"2024-01-02"}, UserToken: "kim"},
+		{ID: "creator", Days: []string{"2024-01-02"}, UserToken: "creator"},
+		{ID: "lee", Days: []string{"2024-01-02"}, IfNeedBeDays: []string{"2024-01-01"}, UserToken: "lee"},
+	}
+	changed := responsesForUpdatedDays(poll, responses, []string{"2024-01-02", "2024-01-03"}, nil)
+	if len(changed) != 3 {
+		t.Fatalf("expected 3 changed responses, got %+v", changed)
+	}
+	if changed[0].ID != "creator" || !equalDays(changed[0].Days, []string{"2024-01-02", "2024-01-03"}) {
+		t.Fatalf("expected creator first with new day added, got %+v", changed[0])
+	}
+	if changed[1].ID != "sam" || !equalDays(changed[1].Days, []string{"2024-01-02"}) {
+		t.Fatalf("expected removed day pruned, got %+v", changed[1])
+	}
+	if changed[2].ID != "lee" || len(changed[2].IfNeedBeDays) != 0 || !equalDays(changed[2].Days, []string{"2024-01-02"}) {
+		t.Fatalf("expected removed if-need-be day pruned, got %+v", changed[2])
+	}
+}
 
-	merged := mergeDays([]string{"2024-01-02"}, []string{"2024-01-01", "2024-01-02"})
-	if !equalDays(merged, []string{"2024-01-01", "2024-01-02"}) {
-		t.Fatalf("expected merged days, got %v", merged)
+func TestResponsesForUpdatedDaysDropsStaleAnswers(t *testing.T) {
+	poll := Poll{Days: []string{"2024-01-02"}, CreatorToken: "creator"}
+	responses := []Response{
+		{ID: "sam", Days: []string{"2024-01-01", "2024-01-02"}, IfNeedBeDays: []string{"2024-01-03"}, UserToken: "sam"},
+		{ID: "kim", Days: []string{"2024-01-02"}, UserToken: "kim"},
+	}
+	changed := responsesForUpdatedDays(poll, responses, []string{"2024-01-01", "2024-01-02", "2024-01-03"}, nil)
+	if len(changed) != 1 || changed[0].ID != "sam" || !equalDays(changed[0].Days, []string{"2024-01-02"}) || len(changed[0].IfNeedBeDays) != 0 {
+		t.Fatalf("expected answers for days no longer on the poll dropped, got %+v", changed)
 	}
 }
 
@@ -151,6 +194,81 @@ func TestAddVenueWriteInRequiresTitle(t *testing.T) {
 	}
 }
 
+func TestValidatePollDays(t *testing.T) {
+	now := time.Date(2024, 3, 10, 15, 0, 0, 0, time.UTC)
+	manyDays := make([]string, maxPollDays+1)
+	for i := range manyDays {
+		manyDays[i] = now.AddDate(0, 0, i).Format("2006-01-02")
+	}
+	cases := []struct {
+		name  string
+		days  []string
+		kept  []string
+		valid bool
+	}{
+		{"today", []string{"2024-03-10"}, nil, true},
+		{"a year ahead", []string{"2025-03-10"}, nil, true},
+		{"none", nil, nil, false},
+		{"garbage", []string{"garbage"}, nil, false},
+		{"impossible date", []string{"2024-02-30"}, nil, false},
+		{"unpadded", []string{"2024-3-11"}, nil, false},
+		{"yesterday", []string{"2024-03-09"}, nil, false},
+		{"too far ahead", []string{"2025-03-12"}, nil, false},
+		{"past day already on the poll", []string{"2024-01-01", "2024-03-11"}, []string{"2024-01-01"}, true},
+		{"too many", manyDays, nil, false},
+	}
+	for _, tc := range cases {
+		errs := FieldErrors{}
+		validatePollDays(errs, "days", tc.days, tc.kept, now)
+		if valid := len(errs) == 0; valid != tc.valid {
+			t.Fatalf("%s: expected valid=%v, got errors %v", tc.name, tc.valid, errs)
+		}
+	}
+}
+
+func TestParseVenuesFromFormValidatesFields(t *testing.T) {
+	cases := map[string][]string{
+		"javascript link":  {"Arcade", "javascript:alert(1)", ""},
+		"relative link":    {"Arcade", "/arcade", ""},
+		"ftp link":         {"Arcade", "ftp://example.com", ""},
+		"long title":       {strings.Repeat("a", maxVenueTitleLength+1), "", ""},
+		"long description": {"Arcade", "", strings.Repeat("é", maxVenueDescriptionLength+1)},
+	}
+	for name, fields := range cases {
+		_, err := parseVenuesFromForm(nil, fields[:1], fields[1:2], fields[2:], nil)
+		var errs FieldErrors
+		if !errors.As(err, &errs) || errs["venues"] == "" {
+			t.Fatalf("%s: expected a venues field error, got %v", name, err)
+		}
+	}
+	if _, err := parseVenuesFromForm(nil, []string{"Arcade"}, []string{"HTTPS://example.com/arcade"}, []string{strings.Repeat("é", maxVenueDescriptionLength)}, nil); err != nil {
+		t.Fatalf("expected valid venue accepted, got %v", err)
+	}
+	titles := make([]string, maxPollVenues+1)
+	for i := range titles {
+		titles[i] = fmt.Sprintf("Venue %d", i)
+	}
+	if _, err := parseVenuesFromForm(nil, titles, nil, nil, nil); err == nil {
+		t.Fatalf("expected too many venues rejected")
+	}
+}
+
+func TestAddVenueWriteInValidates(t *testing.T) {
+	if _, _, err := addVenueWriteIn(nil, "Arcade", "javascript:alert(1)", ""); err == nil {
+		t.Fatalf("expected unsafe link rejected")
+	}
+	full := make([]Venue, maxPollVenues)
+	for i := range full {
+		full[i] = Venue{ID: fmt.Sprint(i), Title: fmt.Sprintf("Venue %d", i)}
+	}
+	if _, _, err := addVenueWriteIn(full, "Arcade", "", ""); err == nil {
+		t.Fatalf("expected write-in rejected on a full poll")
+	}
+	if _, venueID, err := addVenueWriteIn(full, "venue 3", "", ""); err != nil || venueID != "3" {
+		t.Fatalf("expected existing venue still matched on a full poll, got %q %v", venueID, err)
+	}
+}
+
 func TestParsePollPath(t *testing.T) {
 	cases := []struct {
 		path      string
@@ -177,7 +295,7 @@ func TestSummarizeAvailability(t *testing.T) {
 		{Name: "B", Days: []string{"2024-01-01", "2024-01-02"}},
 		{Name: "A", Days: []string{"2024-01-01"}},
 	}
-	summaries := summarizeAvailability(days, responses)
+	summaries := summarizeAvailability(days, nil, responses, "")
 	if len(summaries) != 2 {
 		t.Fatalf("expected 2 summaries, got %d", len(summaries))
 	}
@@ -192,6 +310,145 @@ func TestSummarizeAvailability(t *testing.T) {
 	}
 }
 
+func TestSummarizeAvailabilitySlots(t *testing.T) {
+	days := []string{"2024-01-01"}
+	slots := []TimeSlot{{ID: "lunch", Name: "Lunch"}, {ID: "eve", Start: "18:00", End: "22:00"}}
+	responses := []Response{
+		{Name: "A", Days: []string{"2024-01-01@eve"}},
+		{Name: "B", Days: []string{"2024-01-01@eve"}, IfNeedBeDays: []string{"2024-01-01@lunch"}},
+	}
+	summaries := summarizeAvailability(days, slots, responses, "")
+	if len(summaries) != 2 {
+		t.Fatalf("expected a summary per slot, got %d", len(summaries))
+	}
+	if summaries[0].Key != "2024-01-01@lunch" || summaries[0].SlotLabel != "Lunch" {
+		t.Fatalf("unexpected first slot %+v", summaries[0])
+	}
+	if summaries[1].Key != "2024-01-01@eve" || summaries[1].SlotLabel != "18:00–22:00" || !summaries[1].AllAvailable {
+		t.Fatalf("unexpected second slot %+v", summaries[1])
+	}
+	if strings.Join(summaries[0].IfNeedBeNames, ",") != "B" {
+		t.Fatalf("expected if-need-be names per slot, got %v", summaries[0].IfNeedBeNames)
+	}
+}
+
+func TestRemapOptions(t *testing.T) {
+	days := []string{"2024-01-01", "2024-01-02"}
+	slots := []TimeSlot{{ID: "a"}, {ID: "b"}}
+	got := remapOptions([]string{"2024-01-02", "2024-01-01@a", "2024-01-09"}, days, slots)
+	if !equalDays(got, []string{"2024-01-01@a", "2024-01-02@a", "2024-01-02@b"}) {
+		t.Fatalf("expected whole days expanded to slots, got %v", got)
+	}
+	got = remapOptions([]string{"2024-01-01@a", "2024-01-01@b", "2024-01-02@gone"}, days, nil)
+	if !equalDays(got, []string{"2024-01-01", "2024-01-02"}) {
+		t.Fatalf("expected slots collapsed to days, got %v", got)
+	}
+	got = remapOptions([]string{"2024-01-01@a", "2024-01-01@gone"}, days, slots[:1])
+	if !equalDays(got, []string{"2024-01-01@a"}) {
+		t.Fatalf("expected removed slots dropped, got %v", got)
+	}
+}
+
+func TestParseSlotsFromForm(t *testing.T) {
+	existing := []TimeSlot{{ID: "keep", Name: "Brunch"}}
+	slots, err := parseSlotsFromForm(
+		[]string{"keep", "keep", "", ""},
+		[]string{"Brunch", "Late brunch", "", " Evening "},
+		[]string{"", "", "", "18:00"},
+		[]string{"", "", "", "22:00"},
+		existing,
+	)
+	if err != nil {
+		t.Fatalf("parse slots: %v", err)
+	}
+	if len(slots) != 3 || slots[0].ID != "keep" || slots[1].ID == "keep" || slots[1].ID == "" {
+		t.Fatalf("expected existing id kept once, got %+v", slots)
+	}
+	if slots[2].Name != "Evening" || slots[2].Label() != "Evening (18:00–22:00)" {
+		t.Fatalf("unexpected trimmed slot %+v", slots[2])
+	}
+
+	cases := []struct {
+		name   string
+		starts []string
+		ends   []string
+		want   string
+	}{
+		{name: "Late", starts: []string{"22:00"}, ends: []string{"18:00"}, want: "Time slots must end after they start."},
+		{name: "Half", starts: []string{"18:00"}, ends: []string{""}, want: "Give time slots both a start and an end time, like 18:00."},
+		{name: strings.Repeat("x", maxSlotNameLength+1), want: fmt.Sprintf("Time slot names must be at most %d characters.", maxSlotNameLength)},
+	}
+	for _, tc := range cases {
+		_, err := parseSlotsFromForm(nil, []string{tc.name}, tc.starts, tc.ends, nil)
+		var fieldErrs FieldErrors
+		if !errors.As(err, &fieldErrs) || fieldErrs["edit_slots"] != tc.want {
+			t.Fatalf("slot %q: expected %q, got %v", tc.name, tc.want, err)
+		}
+	}
+	names := make([]string, maxPollSlots+1)
+	for i := range names {
+		names[i] = fmt.Sprintf("Slot %d", i)
+	}
+	if _, err := parseSlotsFromForm(nil, names, nil, nil, nil); err == nil {
+		t.Fatalf("expected too many slots rejected")
+	}
+}
+
+func TestSummarizeAvailabilityIfNeedBe(t *testing.T) {
+	days := []string{"2024-01-01", "2024-01-02", "2024-01-03"}
+	responses := []Response{
+		{Name: "Ana", Days: []string{"2024-01-01", "2024-01-02"}},
+		{Name: "Ben", Days: []string{"2024-01-01"}, IfNeedBeDays: []string{"2024-01-01", "2024-01-02", "2024-01-03"}},
+		{Name: "Cy", IfNeedBeDays: []string{"2024-01-01", "2024-01-02"}},
+	}
+	summaries := summarizeAvailability(days, nil, responses, "")
+	first, second, third := summaries[0], summaries[1], summaries[2]
+	if !equalDays(first.Names, []string{"Ana", "Ben"}) || !equalDays(first.IfNeedBeNames, []string{"Cy"}) || first.Score != 5 {
+		t.Fatalf("expected available day to win over if-need-be, got %+v", first)
+	}
+	if first.AllAvailable || !first.AllIfNeedBe {
+		t.Fatalf("expected everyone to make day 1 only if some stretch, got %+v", first)
+	}
+	if !equalDays(second.Names, []string{"Ana"}) || !equalDays(second.IfNeedBeNames, []string{"Ben", "Cy"}) || second.Score != 4 || !second.AllIfNeedBe {
+		t.Fatalf("unexpected day 2 summary %+v", second)
+	}
+	if third.Score != 1 || third.AllAvailable || third.AllIfNeedBe {
+		t.Fatalf("unexpected day 3 summary %+v", third)
+	}
+
+	legacy := summarizeAvailability(days[:1], nil, []Response{{Name: "Ana", Days: days[:1]}, {Name: "Ben", Days: days[:1]}}, "")
+	if !legacy[0].AllAvailable || legacy[0].AllIfNeedBe || legacy[0].Score != 4 {
+		t.Fatalf("expected responses without preferences to count as available, got %+v", legacy[0])
+	}
+}
+
+func TestSummarizeAvailabilityElapsed(t *testing.T) {
+	days := []string{"2024-01-01", "2024-01-02"}
+	responses := []Response{{Name: "Ana", Days: days}, {Name: "Ben", Days: days}}
+	summaries := summarizeAvailability(days, nil, responses, "2024-01-02")
+	if !summaries[0].Elapsed || summaries[0].AllAvailable || summaries[0].Score != 4 {
+		t.Fatalf("expected yesterday elapsed and not highlighted, got %+v", summaries[0])
+	}
+	if summaries[1].Elapsed || !summaries[1].AllAvailable {
+		t.Fatalf("expected today to stay open, got %+v", summaries[1])
+	}
+}
+
+func TestParseDayPreferences(t *testing.T) {
+	pollDays := []string{"2024-01-01", "2024-01-02", "2024-01-03", "2024-01-04"}
+	form := url.Values{
+		"day_2024-01-01": {"available"},
+		"day_2024-01-02": {"if-need-be"},
+		"day_2024-01-03": {"unavailable"},
+		"day_2024-01-09": {"available"},
+		"days":           {"2024-01-03", "2024-01-04"},
+	}
+	available, ifNeedBe := parseDayPreferences(form, pollDays)
+	if !equalDays(available, []string{"2024-01-01", "2024-01-04"}) || !equalDays(ifNeedBe, []string{"2024-01-02"}) {
+		t.Fatalf("unexpected preferences available=%v if-need-be=%v", available, ifNeedBe)
+	}
+}
+
 func TestSummarizeVenueVotes(t *testing.T) {
 	venues := []Venue{
 		{ID: "park", Title: "Park"},
@@ -213,14 +470,179 @@ func TestSummarizeVenueVotes(t *testing.T) {
 	}
 }
 
-func TestUpcomingDaysFrom(t *testing.T) {
-	start := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
-	options := upcomingDaysFrom(start, 3)
-	if len(options) != 3 {
-		t.Fatalf("expected 3 options, got %d", len(options))
+// The pickable days of a calendar, in order.
+func calendarEnabledDays(months []CalendarMonth) []string {
+	var days []string
+	for _, month := range months {
+		for _, week := range month.Weeks {
+			for _, day := range week {
+				if day.Date != "" && !day.Disabled {
+					days = append(days, day.Date)
+				}
+			}
+		}
+	}
+	return days
+}
+
+func TestCalendarMonths(t *testing.T) {
+	now := time.Date(2024, 2, 14, 12, 0, 0, 0, time.UTC)
+	months := calendarMonths(now, []string{"2024-02-01", "2024-06-03"})
+	if len(months) != 5 || months[0].Key != "2024-02" || months[0].Label != "February 2024" || months[4].Key != "2024-06" {
+		t.Fatalf("expected February through June, got %+v", months)
+	}
+	// February 2024 starts on a Thursday and has 29 days.
+	first := months[0].Weeks[0]
+	if len(first) != 7 || first[2].Date != "" || first[3].Date != "2024-02-01" || first[3].Day != 1 {
+		t.Fatalf("expected Monday-first weeks, got %+v", first)
+	}
+	last := months[0].Weeks[len(months[0].Weeks)-1]
+	if len(last) != 7 || last[3].Date != "2024-02-29" || last[4].Date != "" {
+		t.Fatalf("expected the last week padded, got %+v", last)
+	}
+	enabled := calendarEnabledDays(months)
+	if enabled[0] != "2024-02-01" || enabled[1] != "2024-02-14" {
+		t.Fatalf("expected past days disabled unless chosen, got %v", enabled[:2])
+	}
+
+	// A poll that already has days in earlier months keeps them on the edit calendar.
+	months = calendarMonths(now, []string{"2023-12-30", "2024-02-20"})
+	if len(months) != calendarMonthsShown+2 || months[0].Key != "2023-12" || months[2].Key != "2024-02" {
+		t.Fatalf("expected December 2023 onwards, got %d months starting %s", len(months), months[0].Key)
+	}
+	if enabled := calendarEnabledDays(months); enabled[0] != "2023-12-30" || enabled[1] != "2024-02-14" {
+		t.Fatalf("expected only the chosen past day enabled, got %v", enabled[:2])
+	}
+
+	months = calendarMonths(now, []string{"9999-12-31"})
+	if len(months) != calendarMonthsShown {
+		t.Fatalf("expected days beyond the creation window ignored, got %d months", len(months))
+	}
+	enabled = calendarEnabledDays(calendarMonths(time.Date(2024, 12, 20, 0, 0, 0, 0, time.UTC), []string{"2025-12-21"}))
+	if enabled[len(enabled)-1] != "2025-12-21" {
+		t.Fatalf("expected the creation window to end 366 days ahead, got %s", enabled[len(enabled)-1])
+	}
+}
+
+func mustLoadLocation(t *testing.T, name string) *time.Location {
+	t.Helper()
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		t.Fatalf("load %s: %v", name, err)
+	}
+	return location
+}
+
+func TestCalendarMonthsTimeZones(t *testing.T) {
+	losAngeles := mustLoadLocation(t, "America/Los_Angeles")
+	cases := []struct {
+		name  string
+		now   time.Time
+		dates []string
+	}{
+		{"evening in Pacific time is still today", time.Date(2024, 6, 1, 1, 30, 0, 0, time.UTC).In(losAngeles), []string{"2024-05-31", "2024-06-01", "2024-06-02"}},
+		{"spring forward", time.Date(2024, 3, 9, 23, 30, 0, 0, losAngeles), []string{"2024-03-09", "2024-03-10", "2024-03-11"}},
+		{"fall back", time.Date(2024, 11, 3, 1, 30, 0, 0, losAngeles), []string{"2024-11-03", "2024-11-04", "2024-11-05"}},
+		{"ahead of the date line", time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC).In(mustLoadLocation(t, "Pacific/Kiritimati")), []string{"2024-01-02", "2024-01-03", "2024-01-04"}},
+		{"behind the date line", time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC).In(mustLoadLocation(t, "Pacific/Pago_Pago")), []string{"2023-12-31", "2024-01-01", "2024-01-02"}},
+		{"Samoa skipping a day", time.Date(2011, 12, 29, 12, 0, 0, 0, mustLoadLocation(t, "Pacific/Apia")), []string{"2011-12-29", "2011-12-30", "2011-12-31"}},
+	}
+	for _, tc := range cases {
+		enabled := calendarEnabledDays(calendarMonths(tc.now, nil))
+		if !equalDays(enabled[:len(tc.dates)], tc.dates) {
+			t.Fatalf("%s: expected %v, got %v", tc.name, tc.dates, enabled[:len(tc.dates)])
+		}
+	}
+}
+
+func TestValidatePollDaysTimeZone(t *testing.T) {
+	// 06:00 UTC on March 10 is still March 9 in Los Angeles.
+	now := time.Date(2024, 3, 10, 6, 0, 0, 0, time.UTC).In(mustLoadLocation(t, "America/Los_Angeles"))
+	errs := FieldErrors{}
+	validatePollDays(errs, "days", []string{"2024-03-09"}, nil, now)
+	if len(errs) != 0 {
+		t.Fatalf("expected local today accepted, got %v", errs)
+	}
+	validatePollDays(errs, "days", []string{"2024-03-08"}, nil, now)
+	if len(errs) == 0 {
+		t.Fatalf("expected local yesterday rejected")
+	}
+
+	// The same instant is already the evening of March 10 in Kiritimati.
+	now = now.In(mustLoadLocation(t, "Pacific/Kiritimati"))
+	errs = FieldErrors{}
+	validatePollDays(errs, "days", []string{"2024-03-09"}, nil, now)
+	if len(errs) == 0 {
+		t.Fatalf("expected March 9 rejected in Kiritimati")
+	}
+}
+
+func TestExpandDateRange(t *testing.T) {
+	cases := []struct {
+		name      string
+		dateRange DateRange
+		days      []string
+		err       string
+	}{
+		{"no range", DateRange{Weekdays: map[string]bool{"fri": true}}, nil, ""},
+		{"every day", DateRange{Start: "2024-02-27", End: "2024-03-01"}, []string{"2024-02-27", "2024-02-28", "2024-02-29", "2024-03-01"}, ""},
+		{"fridays and saturdays in March", DateRange{Start: "2024-03-01", End: "2024-03-31", Weekdays: map[string]bool{"fri": true, "sat": true}}, []string{"2024-03-01", "2024-03-02", "2024-03-08", "2024-03-09", "2024-03-15", "2024-03-16", "2024-03-22", "2024-03-23", "2024-03-29", "2024-03-30"}, ""},
+		{"single day", DateRange{Start: "2024-03-10", End: "2024-03-10"}, []string{"2024-03-10"}, ""},
+		{"missing end", DateRange{Start: "2024-03-01"}, nil, "Give the range both a start and an end date."},
+		{"backwards", DateRange{Start: "2024-03-02", End: "2024-03-01"}, nil, "The range must end on or after its start."},
+		{"too long", DateRange{Start: "2024-01-01", End: "2025-01-02"}, nil, "Ranges can cover at most 366 days."},
+		{"unknown weekday", DateRange{Start: "2024-03-01", End: "2024-03-02", Weekdays: map[string]bool{"funday": true}}, nil, `"funday" is not a weekday.`},
+		{"no matching weekday", DateRange{Start: "2024-03-04", End: "2024-03-05", Weekdays: map[string]bool{"sun": true}}, nil, "None of the days in that range fall on the chosen weekdays."},
+	}
+	for _, tc := range cases {
+		errs := FieldErrors{}
+		days := expandDateRange(errs, "range", tc.dateRange)
+		if !equalDays(days, tc.days) || errs["range"] != tc.err {
+			t.Fatalf("%s: expected %v %q, got %v %q", tc.name, tc.days, tc.err, days, errs["range"])
+		}
+	}
+}
+
+func TestLoadTimeZone(t *testing.T) {
+	for _, name := range []string{"", "UTC", "America/Los_Angeles", "Pacific/Kiritimati"} {
+		if _, err := loadTimeZone(name); err != nil {
+			t.Fatalf("expected %q to load, got %v", name, err)
+		}
+	}
+	for _, name := range []string{"Local", "Mars/Olympus_Mons", "../etc/passwd", strings.Repeat("A", maxTimeZoneLength+1)} {
+		if _, err := loadTimeZone(name); err == nil {
+			t.Fatalf("expected %q rejected", name)
+		}
+	}
+	if pollLocation(Poll{TimeZone: "Mars/Olympus_Mons"}) != time.UTC || pollLocation(Poll{}) != time.UTC {
+		t.Fatalf("expected polls without a usable time zone to use UTC")
+	}
+}
+
+func TestDeadlineTimeZone(t *testing.T) {
+	losAngeles := mustLoadLocation(t, "America/Los_Angeles")
+	now := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	cases := []struct {
+		input string
+		utc   time.Time
+		label string
+	}{
+		{"2024-03-09T18:00", time.Date(2024, 3, 10, 2, 0, 0, 0, time.UTC), "Sat, Mar 9 at 18:00 PST"},
+		{"2024-03-10T18:00", time.Date(2024, 3, 11, 1, 0, 0, 0, time.UTC), "Sun, Mar 10 at 18:00 PDT"},
+		{"2024-11-03T18:00", time.Date(2024, 11, 4, 2, 0, 0, 0, time.UTC), "Sun, Nov 3 at 18:00 PST"},
 	}
-	if options[0].Date != "2024-02-01" || options[2].Date != "2024-02-03" {
-		t.Fatalf("unexpected dates: %+v", options)
+	for _, tc := range cases {
+		errs := FieldErrors{}
+		deadline := parseDeadline(errs, tc.input, now, losAngeles)
+		if len(errs) != 0 || !deadline.Equal(tc.utc) {
+			t.Fatalf("%s: expected %v, got %v %v", tc.input, tc.utc, deadline, errs)
+		}
+		if label := formatDeadline(deadline, losAngeles); label != tc.label {
+			t.Fatalf("%s: expected label %q, got %q", tc.input, tc.label, label)
+		}
+		if input := deadlineInput(deadline, losAngeles); input != tc.input {
+			t.Fatalf("%s: expected input %q, got %q", tc.input, tc.input, input)
+		}
 	}
 }
 
@@ -247,7 +669,7 @@ func TestMemoryStorageCRUD(t *testing.T) {
 		t.Fatalf("expected poll and response")
 	}
 
-	if err := storage.UpdatePollDays(context.Background(), poll.ID, []string{"2024-01-01", "2024-01-02"}); err != nil {
+	if err := storage.UpdatePollDays(context.Background(), poll.ID, 0, []string{"2024-01-01", "2024-01-02"}, nil, time.Time{}, nil); err != nil {
 		t.Fatalf("update days: %v", err)
 	}
 
@@ -268,429 +690,3608 @@ func TestMemoryStorageCRUD(t *testing.T) {
 	}
 }
 
-func TestHandleHome(t *testing.T) {
-	app, _ := newTestApp(t)
-	req := httptest.NewRequest(http.MethodGet, "/?invalid=1", nil)
-	w := httptest.NewRecorder()
-	app.handleHome(w, req)
-	res := w.Result()
-	if res.StatusCode != http.StatusOK {
-		t.Fatalf("expected 200, got %d", res.StatusCode)
+func TestMemoryStorageReturnsCopies(t *testing.T) {
+	storage := newMemoryStorage()
+	days := []string{"2024-01-01"}
+	venues := []Venue{{ID: "park", Title: "Park"}}
+	poll := Poll{ID: "poll-1", Title: "Title", Days: days, Venues: venues, CreatorToken: "creator"}
+	if err := storage.CreatePoll(context.Background(), poll); err != nil {
+		t.Fatalf("create poll: %v", err)
 	}
-	body, _ := io.ReadAll(res.Body)
-	if !strings.Contains(string(body), "That link was invalid") {
-		t.Fatalf("expected invalid message")
+	votes := []string{"park"}
+	response := Response{ID: "resp-1", Name: "Alex", Days: []string{"2024-01-01"}, VenueVotes: votes, UserToken: "token"}
+	if err := storage.AddResponse(context.Background(), poll.ID, response); err != nil {
+		t.Fatalf("add response: %v", err)
+	}
+
+	days[0] = "mutated"
+	venues[0].Title = "mutated"
+	votes[0] = "mutated"
+
+	loadedPoll, responses, err := storage.GetPoll(context.Background(), poll.ID)
+	if err != nil {
+		t.Fatalf("get poll: %v", err)
+	}
+	if loadedPoll.Days[0] != "2024-01-01" || loadedPoll.Venues[0].Title != "Park" {
+		t.Fatalf("expected stored poll unaffected by caller changes, got %+v", loadedPoll)
+	}
+	if responses[0].VenueVotes[0] != "park" {
+		t.Fatalf("expected stored response unaffected by caller changes, got %+v", responses[0])
+	}
+
+	loadedPoll.Days[0] = "mutated"
+	loadedPoll.Venues[0].Title = "mutated"
+	responses[0].Days[0] = "mutated"
+	reloadedPoll, reloadedResponses, _ := storage.GetPoll(context.Background(), poll.ID)
+	if reloadedPoll.Days[0] != "2024-01-01" || reloadedPoll.Venues[0].Title != "Park" {
+		t.Fatalf("expected returned poll to be a copy, got %+v", reloadedPoll)
+	}
+	if reloadedResponses[0].Days[0] != "2024-01-01" {
+		t.Fatalf("expected returned responses to be copies, got %+v", reloadedResponses[0])
 	}
 }
 
-func TestHandleCreatePollValidation(t *testing.T) {
-	app, _ := newTestApp(t)
-	req := newFormRequest(http.MethodPost, "/polls", url.Values{})
-	w := httptest.NewRecorder()
-	app.handleCreatePoll(w, req)
-	if w.Result().StatusCode != http.StatusBadRequest {
-		t.Fatalf("expected 400 for invalid form")
+func TestMemoryStorageConcurrentAccess(t *testing.T) {
+	storage := newMemoryStorage()
+	ctx := context.Background()
+	poll := Poll{ID: "poll-1", Title: "Title", Days: []string{"2024-01-01"}, CreatorToken: "creator", CreatedAt: time.Now()}
+	if err := storage.CreatePoll(ctx, poll); err != nil {
+		t.Fatalf("create poll: %v", err)
+	}
+
+	const workers = 16
+	const iterations = 50
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			for i := 0; i < iterations; i++ {
+				responseID := fmt.Sprintf("resp-%d-%d", worker, i)
+				response := Response{ID: responseID, Name: "Alex", Days: []string{"2024-01-01"}, UserToken: responseID, CreatedAt: time.Now()}
+				if err := storage.AddResponse(ctx, poll.ID, response); err != nil {
+					t.Errorf("add response: %v", err)
+					return
+				}
+				if _, responses, err := storage.GetPoll(ctx, poll.ID); err != nil {
+					t.Errorf("get poll: %v", err)
+					return
+				} else if len(responses) > 0 {
+					responses[0].Days = append(responses[0].Days, "2024-01-02")
+				}
+				current, _, err := storage.GetPoll(ctx, poll.ID)
+				if err != nil {
+					t.Errorf("get poll: %v", err)
+					return
+				}
+				if err := storage.UpdatePollDays(ctx, poll.ID, current.Version, []string{"2024-01-01", "2024-01-02"}, nil, time.Time{}, nil); err != nil && !errors.Is(err, errConflict) {
+					t.Errorf("update days: %v", err)
+					return
+				}
+				if err := storage.UpdatePollVenues(ctx, poll.ID, current.Version, []Venue{{ID: "park", Title: "Park"}}, nil); err != nil && !errors.Is(err, errConflict) {
+					t.Errorf("update venues: %v", err)
+					return
+				}
+				if err := storage.CreatePoll(ctx, Poll{ID: fmt.Sprintf("poll-%d-%d", worker, i)}); err != nil {
+					t.Errorf("create poll: %v", err)
+					return
+				}
+				if _, err := storage.GetStats(ctx); err != nil {
+					t.Errorf("stats: %v", err)
+					return
+				}
+				if i%2 == 0 {
+					if err := storage.DeleteResponse(ctx, poll.ID, responseID); err != nil {
+						t.Errorf("delete response: %v", err)
+						return
+					}
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	stats, err := storage.GetStats(ctx)
+	if err != nil {
+		t.Fatalf("stats: %v", err)
+	}
+	if stats.PollCount != 1+workers*iterations {
+		t.Fatalf("expected %d polls, got %d", 1+workers*iterations, stats.PollCount)
+	}
+	if stats.ResponseCount != workers*iterations/2 {
+		t.Fatalf("expected %d responses, got %d", workers*iterations/2, stats.ResponseCount)
 	}
 }
 
-func TestHandleCreatePollSuccess(t *testing.T) {
-	app, storage := newTestApp(t)
-	form := url.Values{}
-	form.Set("title", "Dinner")
-	form.Set("creator", "Sam")
-	form.Add("days", "2024-01-02")
-	form.Add("days", "2024-01-01")
-	req := newFormRequest(http.MethodPost, "/polls", form)
-	w := httptest.NewRecorder()
-	app.handleCreatePoll(w, req)
-	res := w.Result()
-	if res.StatusCode != http.StatusSeeOther {
-		t.Fatalf("expected redirect, got %d", res.StatusCode)
+func TestFileStoragePersistsAcrossReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data", "bff-hang.json")
+	storage, err := newFileStorage(path)
+	if err != nil {
+		t.Fatalf("open file storage: %v", err)
 	}
-	location := res.Header.Get("Location")
-	if !strings.Contains(location, "/poll/") || !strings.Contains(location, "/u/") {
-		t.Fatalf("unexpected redirect location: %s", location)
+	ctx := context.Background()
+	poll := Poll{
+		ID:           "poll-1",
+		Title:        "Title",
+		Days:         []string{"2024-01-01", "2024-01-02"},
+		Venues:       []Venue{{ID: "park", Title: "Park", URL: "https://example.com/park"}},
+		CreatorToken: "creator",
+		CreatedAt:    time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC),
 	}
-	if len(storage.polls) != 1 {
-		t.Fatalf("expected 1 poll stored")
+	if err := storage.CreatePoll(ctx, poll); err != nil {
+		t.Fatalf("create poll: %v", err)
 	}
-	var poll Poll
-	for _, stored := range storage.polls {
-		poll = stored
+	if err := storage.CreatePoll(ctx, poll); err != errConflict {
+		t.Fatalf("expected conflict, got %v", err)
 	}
-	if poll.Title != "Dinner" {
-		t.Fatalf("expected poll title stored")
+	response := Response{ID: "resp-1", Name: "Alex", Days: []string{"2024-01-02"}, VenueVotes: []string{"park"}, UserToken: "token", CreatedAt: poll.CreatedAt}
+	if err := storage.AddResponse(ctx, poll.ID, response); err != nil {
+		t.Fatalf("add response: %v", err)
 	}
-	responses := storage.responses[poll.ID]
-	if len(responses) != 1 {
-		t.Fatalf("expected creator response stored")
+	if err := storage.AddResponse(ctx, poll.ID, Response{ID: "resp-2", Name: "Sam", UserToken: "other", CreatedAt: poll.CreatedAt.Add(time.Minute)}); err != nil {
+		t.Fatalf("add response: %v", err)
 	}
-	if responses[0].UserToken != poll.CreatorToken {
-		t.Fatalf("expected creator token to match")
+	if err := storage.DeleteResponse(ctx, poll.ID, "resp-2"); err != nil {
+		t.Fatalf("delete response: %v", err)
 	}
-	if !equalDays(responses[0].Days, []string{"2024-01-01", "2024-01-02"}) {
-		t.Fatalf("unexpected days: %v", responses[0].Days)
+	if err := storage.UpdatePollDays(ctx, poll.ID, poll.Version, []string{"2024-01-02"}, nil, time.Time{}, nil); err != nil {
+		t.Fatalf("update days: %v", err)
 	}
-}
 
-func TestHandleCreatePollWithVenues(t *testing.T) {
-	app, storage := newTestApp(t)
-	form := url.Values{}
-	form.Set("title", "Dinner")
-	form.Set("creator", "Sam")
-	form.Add("days", "2024-01-01")
-	form.Add("venue_title", "Sushi place")
-	form.Add("venue_url", "https://example.com/sushi")
-	form.Add("venue_description", "Close to downtown")
-	req := newFormRequest(http.MethodPost, "/polls", form)
-	w := httptest.NewRecorder()
-	app.handleCreatePoll(w, req)
-	if w.Result().StatusCode != http.StatusSeeOther {
-		t.Fatalf("expected redirect, got %d", w.Result().StatusCode)
+	reopened, err := newFileStorage(path)
+	if err != nil {
+		t.Fatalf("reopen file storage: %v", err)
 	}
-	var poll Poll
-	for _, stored := range storage.polls {
-		poll = stored
+	loadedPoll, responses, err := reopened.GetPoll(ctx, poll.ID)
+	if err != nil {
+		t.Fatalf("get poll: %v", err)
 	}
-	if len(poll.Venues) != 1 {
-		t.Fatalf("expected one venue option, got %d", len(poll.Venues))
+	if loadedPoll.Title != "Title" || !equalDays(loadedPoll.Days, []string{"2024-01-02"}) || len(loadedPoll.Venues) != 1 {
+		t.Fatalf("unexpected poll after reopen: %+v", loadedPoll)
 	}
-	if poll.Venues[0].Title != "Sushi place" {
-		t.Fatalf("unexpected venue title: %s", poll.Venues[0].Title)
+	if !loadedPoll.CreatedAt.Equal(poll.CreatedAt) {
+		t.Fatalf("expected created at preserved, got %v", loadedPoll.CreatedAt)
+	}
+	if len(responses) != 1 || responses[0].Name != "Alex" || !equalDays(responses[0].VenueVotes, []string{"park"}) {
+		t.Fatalf("unexpected responses after reopen: %+v", responses)
+	}
+	stats, err := reopened.GetStats(ctx)
+	if err != nil {
+		t.Fatalf("stats: %v", err)
+	}
+	if stats.PollCount != 1 || stats.ResponseCount != 1 {
+		t.Fatalf("unexpected stats: %+v", stats)
 	}
 }
 
-func TestHandlePollGetRedirect(t *testing.T) {
+func TestFileStorageRollsBackFailedWrite(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "data")
+	storage, err := newFileStorage(filepath.Join(dir, "bff-hang.json"))
+	if err != nil {
+		t.Fatalf("open file storage: %v", err)
+	}
+	ctx := context.Background()
+	if err := storage.CreatePoll(ctx, Poll{ID: "poll-1", Title: "Title"}); err != nil {
+		t.Fatalf("create poll: %v", err)
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		t.Fatalf("remove data dir: %v", err)
+	}
+	if err := storage.CreatePoll(ctx, Poll{ID: "poll-2", Title: "Other"}); err == nil {
+		t.Fatalf("expected write error")
+	}
+	if _, _, err := storage.GetPoll(ctx, "poll-2"); err != errNotFound {
+		t.Fatalf("expected failed write rolled back, got %v", err)
+	}
+	if _, _, err := storage.GetPoll(ctx, "poll-1"); err != nil {
+		t.Fatalf("expected earlier poll kept, got %v", err)
+	}
+}
+
+func TestNewFileStorageRejectsCorruptFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bff-hang.json")
+	if err := os.WriteFile(path, []byte("{not json"), 0o644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	if _, err := newFileStorage(path); err == nil {
+		t.Fatalf("expected corrupt data file to be rejected")
+	}
+}
+
+func TestSweepPolls(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	file, err := newFileStorage(filepath.Join(t.TempDir(), "bff-hang.json"))
+	if err != nil {
+		t.Fatalf("open file storage: %v", err)
+	}
+	for name, storage := range map[string]interface {
+		Storage
+		Sweeper
+	}{"memory": newMemoryStorage(), "file": file} {
+		t.Run(name, func(t *testing.T) {
+			polls := []Poll{
+				{ID: "ended", Days: []string{daysFromToday(-3), daysFromToday(-2)}, ExpiresAt: now.Add(time.Hour)},
+				{ID: "upcoming", Days: []string{daysFromToday(-2), daysFromToday(2)}},
+				{ID: "undated"},
+				{ID: "expired", Days: []string{daysFromToday(-60)}, ExpiresAt: now.Add(-time.Hour)},
+			}
+			for _, poll := range polls {
+				if err := storage.CreatePoll(ctx, poll); err != nil {
+					t.Fatalf("create poll: %v", err)
+				}
+			}
+			if err := storage.AddResponse(ctx, "expired", Response{ID: "resp-1", Name: "Sam", UserToken: "sam"}); err != nil {
+				t.Fatalf("add response: %v", err)
+			}
+
+			archived, deleted, err := storage.SweepPolls(ctx, now)
+			if err != nil || archived != 1 || deleted != 1 {
+				t.Fatalf("expected one poll archived and one deleted, got %d %d %v", archived, deleted, err)
+			}
+			ended, _, err := storage.GetPoll(ctx, "ended")
+			if err != nil {
+				t.Fatalf("get poll: %v", err)
+			}
+			if ended.ArchivedAt.IsZero() || ended.Version != 1 {
+				t.Fatalf("expected ended poll archived, got %+v", ended)
+			}
+			for _, id := range []string{"upcoming", "undated"} {
+				if poll, _, err := storage.GetPoll(ctx, id); err != nil || !poll.ArchivedAt.IsZero() {
+					t.Fatalf("expected %s left alone, got %+v %v", id, poll, err)
+				}
+			}
+			if _, _, err := storage.GetPoll(ctx, "expired"); !errors.Is(err, errNotFound) {
+				t.Fatalf("expected expired poll deleted, got %v", err)
+			}
+
+			if archived, deleted, err := storage.SweepPolls(ctx, now); err != nil || archived != 0 || deleted != 0 {
+				t.Fatalf("expected nothing left to sweep, got %d %d %v", archived, deleted, err)
+			}
+		})
+	}
+
+	reopened, err := newFileStorage(file.path)
+	if err != nil {
+		t.Fatalf("reopen file storage: %v", err)
+	}
+	if poll, _, err := reopened.GetPoll(ctx, "ended"); err != nil || poll.ArchivedAt.IsZero() {
+		t.Fatalf("expected archive persisted, got %+v %v", poll, err)
+	}
+}
+
+func runStorageConformance(t *testing.T, newStorage func(t *testing.T) Storage) {
+	ctx := context.Background()
+	base := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	seedPoll := func(t *testing.T, storage Storage, id string) Poll {
+		t.Helper()
+		poll := Poll{
+			ID:           id,
+			Title:        "Title " + id,
+			Days:         []string{"2024-01-01", "2024-01-02"},
+			Venues:       []Venue{{ID: "park", Title: "Park", URL: "https://example.com/park", Description: "Picnic"}},
+			CreatorToken: "creator-" + id,
+			CreatedAt:    base,
+		}
+		if err := storage.CreatePoll(ctx, poll); err != nil {
+			t.Fatalf("create poll: %v", err)
+		}
+		return poll
+	}
+
+	t.Run("CreatePollConflict", func(t *testing.T) {
+		storage := newStorage(t)
+		poll := seedPoll(t, storage, "poll-1")
+		duplicate := poll
+		duplicate.Title = "Replacement"
+		if err := storage.CreatePoll(ctx, duplicate); !errors.Is(err, errConflict) {
+			t.Fatalf("expected errConflict, got %v", err)
+		}
+		loaded, _, err := storage.GetPoll(ctx, poll.ID)
+		if err != nil {
+			t.Fatalf("get poll: %v", err)
+		}
+		if loaded.Title != poll.Title {
+			t.Fatalf("expected original poll kept, got %q", loaded.Title)
+		}
+	})
+
+	t.Run("GetPollMissing", func(t *testing.T) {
+		storage := newStorage(t)
+		if _, _, err := storage.GetPoll(ctx, "missing"); !errors.Is(err, errNotFound) {
+			t.Fatalf("expected errNotFound, got %v", err)
+		}
+	})
+
+	t.Run("GetPollRoundTrip", func(t *testing.T) {
+		storage := newStorage(t)
+		poll := seedPoll(t, storage, "poll-1")
+		response := Response{ID: "resp-1", Name: "Alex", Days: []string{"2024-01-02"}, IfNeedBeDays: []string{"2024-01-01"}, VenueVotes: []string{"park"}, UserToken: "token", CreatedAt: base}
+		if err := storage.AddResponse(ctx, poll.ID, response); err != nil {
+			t.Fatalf("add response: %v", err)
+		}
+		loaded, responses, err := storage.GetPoll(ctx, poll.ID)
+		if err != nil {
+			t.Fatalf("get poll: %v", err)
+		}
+		if loaded.ID != poll.ID || loaded.Title != poll.Title || loaded.CreatorToken != poll.CreatorToken {
+			t.Fatalf("unexpected poll: %+v", loaded)
+		}
+		if !equalDays(loaded.Days, poll.Days) || len(loaded.Venues) != 1 || loaded.Venues[0] != poll.Venues[0] {
+			t.Fatalf("unexpected poll options: %+v", loaded)
+		}
+		if !loaded.CreatedAt.Equal(base) {
+			t.Fatalf("unexpected created at: %v", loaded.CreatedAt)
+		}
+		if len(responses) != 1 {
+			t.Fatalf("expected 1 response, got %d", len(responses))
+		}
+		got := responses[0]
+		if got.ID != response.ID || got.Name != response.Name || got.UserToken != response.UserToken {
+			t.Fatalf("unexpected response: %+v", got)
+		}
+		if !equalDays(got.Days, response.Days) || !equalDays(got.IfNeedBeDays, response.IfNeedBeDays) || !equalDays(got.VenueVotes, response.VenueVotes) || !got.CreatedAt.Equal(base) {
+			t.Fatalf("unexpected response selections: %+v", got)
+		}
+	})
+
+	t.Run("GetPollOrdersResponsesByCreatedAt", func(t *testing.T) {
+		storage := newStorage(t)
+		poll := seedPoll(t, storage, "poll-1")
+		for _, response := range []Response{
+			{ID: "b", Name: "Second", UserToken: "b", CreatedAt: base.Add(2 * time.Minute)},
+			{ID: "c", Name: "Third", UserToken: "c", CreatedAt: base.Add(3 * time.Minute)},
+			{ID: "a", Name: "First", UserToken: "a", CreatedAt: base.Add(time.Minute)},
+		} {
+			if err := storage.AddResponse(ctx, poll.ID, response); err != nil {
+				t.Fatalf("add response: %v", err)
+			}
+		}
+		_, responses, err := storage.GetPoll(ctx, poll.ID)
+		if err != nil {
+			t.Fatalf("get poll: %v", err)
+		}
+		var names []string
+		for _, response := range responses {
+			names = append(names, response.Name)
+		}
+		if strings.Join(names, ",") != "First,Second,Third" {
+			t.Fatalf("expected responses ordered by creation, got %v", names)
+		}
+	})
+
+	t.Run("AddResponseUpserts", func(t *testing.T) {
+		storage := newStorage(t)
+		poll := seedPoll(t, storage, "poll-1")
+		response := Response{ID: "resp-1", Name: "Alex", Days: []string{"2024-01-01"}, UserToken: "token", CreatedAt: base}
+		if err := storage.AddResponse(ctx, poll.ID, response); err != nil {
+			t.Fatalf("add response: %v", err)
+		}
+		_, responses, err := storage.GetPoll(ctx, poll.ID)
+		if err != nil {
+			t.Fatalf("get poll: %v", err)
+		}
+		response = responses[0]
+		response.Name = "Alexandra"
+		response.Days = []string{"2024-01-02"}
+		response.VenueVotes = []string{"park"}
+		if err := storage.AddResponse(ctx, poll.ID, response); err != nil {
+			t.Fatalf("update response: %v", err)
+		}
+		_, responses, err = storage.GetPoll(ctx, poll.ID)
+		if err != nil {
+			t.Fatalf("get poll: %v", err)
+		}
+		if len(responses) != 1 {
+			t.Fatalf("expected upsert to keep 1 response, got %d", len(responses))
+		}
+		if responses[0].Name != "Alexandra" || !equalDays(responses[0].Days, []string{"2024-01-02"}) || !equalDays(responses[0].VenueVotes, []string{"park"}) {
+			t.Fatalf("expected response replaced, got %+v", responses[0])
+		}
+	})
+
+	t.Run("AddResponseMissingPoll", func(t *testing.T) {
+		storage := newStorage(t)
+		response := Response{ID: "resp-1", Name: "Alex", UserToken: "token", CreatedAt: base}
+		if err := storage.AddResponse(ctx, "missing", response); !errors.Is(err, errNotFound) {
+			t.Fatalf("expected errNotFound, got %v", err)
+		}
+	})
+
+	t.Run("DeleteResponse", func(t *testing.T) {
+		storage := newStorage(t)
+		poll := seedPoll(t, storage, "poll-1")
+		for _, response := range []Response{
+			{ID: "resp-1", Name: "Alex", UserToken: "a", CreatedAt: base},
+			{ID: "resp-2", Name: "Sam", UserToken: "b", CreatedAt: base.Add(time.Minute)},
+		} {
+			if err := storage.AddResponse(ctx, poll.ID, response); err != nil {
+				t.Fatalf("add response: %v", err)
+			}
+		}
+		if err := storage.DeleteResponse(ctx, poll.ID, "resp-1"); err != nil {
+			t.Fatalf("delete response: %v", err)
+		}
+		if err := storage.DeleteResponse(ctx, poll.ID, "resp-missing"); err != nil {
+			t.Fatalf("expected deleting a missing response to be a no-op, got %v", err)
+		}
+		if err := storage.DeleteResponse(ctx, "missing", "resp-2"); !errors.Is(err, errNotFound) {
+			t.Fatalf("expected errNotFound for missing poll, got %v", err)
+		}
+		_, responses, err := storage.GetPoll(ctx, poll.ID)
+		if err != nil {
+			t.Fatalf("get poll: %v", err)
+		}
+		if len(responses) != 1 || responses[0].ID != "resp-2" {
+			t.Fatalf("expected only resp-2 left, got %+v", responses)
+		}
+	})
+
+	t.Run("UpdatePollDays", func(t *testing.T) {
+		storage := newStorage(t)
+		poll := seedPoll(t, storage, "poll-1")
+		if err := storage.UpdatePollDays(ctx, poll.ID, poll.Version, []string{"2024-01-03"}, nil, time.Time{}, nil); err != nil {
+			t.Fatalf("update days: %v", err)
+		}
+		loaded, _, err := storage.GetPoll(ctx, poll.ID)
+		if err != nil {
+			t.Fatalf("get poll: %v", err)
+		}
+		if !equalDays(loaded.Days, []string{"2024-01-03"}) || loaded.Title != poll.Title {
+			t.Fatalf("unexpected poll after update: %+v", loaded)
+		}
+		if err := storage.UpdatePollDays(ctx, "missing", 0, []string{"2024-01-03"}, nil, time.Time{}, nil); !errors.Is(err, errNotFound) {
+			t.Fatalf("expected errNotFound, got %v", err)
+		}
+		if _, _, err := storage.GetPoll(ctx, "missing"); !errors.Is(err, errNotFound) {
+			t.Fatalf("expected update not to create a poll, got %v", err)
+		}
+	})
+
+	t.Run("UpdatePollDaysWithSlots", func(t *testing.T) {
+		storage := newStorage(t)
+		poll := seedPoll(t, storage, "poll-1")
+		slots := []TimeSlot{{ID: "eve", Name: "Evening", Start: "18:00", End: "22:00"}}
+		if err := storage.AddResponse(ctx, poll.ID, Response{ID: "resp-1", Name: "Alex", Days: []string{"2024-01-01"}, UserToken: "a", CreatedAt: base}); err != nil {
+			t.Fatalf("create response: %v", err)
+		}
+		_, responses, err := storage.GetPoll(ctx, poll.ID)
+		if err != nil {
+			t.Fatalf("get poll: %v", err)
+		}
+		remapped := responses[0]
+		remapped.Days = []string{"2024-01-01@eve"}
+		if err := storage.UpdatePollDays(ctx, poll.ID, poll.Version, []string{"2024-01-01"}, slots, time.Time{}, []Response{remapped}); err != nil {
+			t.Fatalf("update days: %v", err)
+		}
+		loaded, responses, err := storage.GetPoll(ctx, poll.ID)
+		if err != nil {
+			t.Fatalf("get poll: %v", err)
+		}
+		if len(loaded.Slots) != 1 || loaded.Slots[0] != slots[0] {
+			t.Fatalf("expected slots to round-trip, got %+v", loaded.Slots)
+		}
+		if len(responses) != 1 || !equalDays(responses[0].Days, []string{"2024-01-01@eve"}) {
+			t.Fatalf("expected remapped response, got %+v", responses)
+		}
+		if err := storage.UpdatePollDays(ctx, poll.ID, loaded.Version, []string{"2024-01-01"}, nil, time.Time{}, nil); err != nil {
+			t.Fatalf("clear slots: %v", err)
+		}
+		loaded, _, err = storage.GetPoll(ctx, poll.ID)
+		if err != nil {
+			t.Fatalf("get poll: %v", err)
+		}
+		if len(loaded.Slots) != 0 {
+			t.Fatalf("expected slots cleared, got %+v", loaded.Slots)
+		}
+	})
+
+	t.Run("UpdatePollDaysWithResponses", func(t *testing.T) {
+		storage := newStorage(t)
+		poll := seedPoll(t, storage, "poll-1")
+		for _, response := range []Response{
+			{ID: "resp-1", Name: "Alex", Days: []string{"2024-01-01", "2024-01-02"}, UserToken: "a", CreatedAt: base},
+			{ID: "resp-2", Name: "Sam", Days: []string{"2024-01-02"}, UserToken: "b", CreatedAt: base.Add(time.Minute)},
+		} {
+			if err := storage.AddResponse(ctx, poll.ID, response); err != nil {
+				t.Fatalf("add response: %v", err)
+			}
+		}
+		_, responses, err := storage.GetPoll(ctx, poll.ID)
+		if err != nil {
+			t.Fatalf("get poll: %v", err)
+		}
+		pruned := responses[0]
+		pruned.Days = []string{"2024-01-02"}
+		if err := storage.UpdatePollDays(ctx, poll.ID, poll.Version, []string{"2024-01-02"}, nil, time.Time{}, []Response{pruned}); err != nil {
+			t.Fatalf("update days: %v", err)
+		}
+		loaded, responses, err := storage.GetPoll(ctx, poll.ID)
+		if err != nil {
+			t.Fatalf("get poll: %v", err)
+		}
+		if !equalDays(loaded.Days, []string{"2024-01-02"}) || !equalDays(responses[0].Days, []string{"2024-01-02"}) {
+			t.Fatalf("expected days and response updated together, got %v / %v", loaded.Days, responses[0].Days)
+		}
+		if responses[0].Version != pruned.Version+1 || responses[1].Version != 1 {
+			t.Fatalf("expected only the rewritten response bumped, got %d and %d", responses[0].Version, responses[1].Version)
+		}
+	})
+
+	t.Run("UpdatePollDaysIsAllOrNothing", func(t *testing.T) {
+		storage := newStorage(t)
+		poll := seedPoll(t, storage, "poll-1")
+		response := Response{ID: "resp-1", Name: "Alex", Days: []string{"2024-01-01", "2024-01-02"}, UserToken: "a", CreatedAt: base}
+		if err := storage.AddResponse(ctx, poll.ID, response); err != nil {
+			t.Fatalf("add response: %v", err)
+		}
+		stale := response
+		stale.Days = []string{"2024-01-02"}
+		if err := storage.UpdatePollDays(ctx, poll.ID, poll.Version, []string{"2024-01-02"}, nil, time.Time{}, []Response{stale}); !errors.Is(err, errConflict) {
+			t.Fatalf("expected errConflict for stale response, got %v", err)
+		}
+		deleted := Response{ID: "resp-gone", Name: "Gone", UserToken: "gone", CreatedAt: base, Version: 1}
+		if err := storage.UpdatePollDays(ctx, poll.ID, poll.Version, []string{"2024-01-02"}, nil, time.Time{}, []Response{deleted}); !errors.Is(err, errConflict) {
+			t.Fatalf("expected errConflict for deleted response, got %v", err)
+		}
+		loaded, responses, err := storage.GetPoll(ctx, poll.ID)
+		if err != nil {
+			t.Fatalf("get poll: %v", err)
+		}
+		if !equalDays(loaded.Days, poll.Days) || loaded.Version != poll.Version {
+			t.Fatalf("expected poll unchanged, got %+v", loaded)
+		}
+		if len(responses) != 1 || !equalDays(responses[0].Days, response.Days) {
+			t.Fatalf("expected responses unchanged, got %+v", responses)
+		}
+	})
+
+	t.Run("UpdatePollDaysManyResponses", func(t *testing.T) {
+		storage := newStorage(t)
+		poll := seedPoll(t, storage, "poll-1")
+		const count = maxTransactionItems + 20
+		for i := 0; i < count; i++ {
+			response := Response{ID: fmt.Sprintf("resp-%03d", i), Name: "Alex", Days: poll.Days, UserToken: fmt.Sprintf("token-%d", i), CreatedAt: base.Add(time.Duration(i) * time.Second)}
+			if err := storage.AddResponse(ctx, poll.ID, response); err != nil {
+				t.Fatalf("add response: %v", err)
+			}
+		}
+		_, responses, err := storage.GetPoll(ctx, poll.ID)
+		if err != nil {
+			t.Fatalf("get poll: %v", err)
+		}
+		for i := range responses {
+			responses[i].Days = []string{"2024-01-02"}
+		}
+		if err := storage.UpdatePollDays(ctx, poll.ID, poll.Version, []string{"2024-01-02"}, nil, time.Time{}, responses); err != nil {
+			t.Fatalf("update days: %v", err)
+		}
+		_, responses, err = storage.GetPoll(ctx, poll.ID)
+		if err != nil {
+			t.Fatalf("get poll: %v", err)
+		}
+		for _, response := range responses {
+			if !equalDays(response.Days, []string{"2024-01-02"}) {
+				t.Fatalf("expected every response pruned, got %+v", response)
+			}
+		}
+	})
+
+	t.Run("UpdatePollDaysOverflowConflict", func(t *testing.T) {
+		storage := newStorage(t)
+		poll := seedPoll(t, storage, "poll-1")
+		const count = maxTransactionItems + 20
+		for i := 0; i < count; i++ {
+			response := Response{ID: fmt.Sprintf("resp-%03d", i), Name: "Alex", Days: poll.Days, UserToken: fmt.Sprintf("token-%d", i), CreatedAt: base.Add(time.Duration(i) * time.Second)}
+			if err := storage.AddResponse(ctx, poll.ID, response); err != nil {
+				t.Fatalf("add response: %v", err)
+			}
+		}
+		_, responses, err := storage.GetPoll(ctx, poll.ID)
+		if err != nil {
+			t.Fatalf("get poll: %v", err)
+		}
+		days := []string{"2024-01-02"}
+		changed := responsesForUpdatedDays(poll, responses, days, nil)
+		resaved := responses[count-1]
+		if err := storage.AddResponse(ctx, poll.ID, resaved); err != nil {
+			t.Fatalf("re-save response: %v", err)
+		}
+		if err := storage.UpdatePollDays(ctx, poll.ID, poll.Version, days, nil, time.Time{}, changed); !errors.Is(err, errConflict) {
+			t.Fatalf("expected errConflict when a response changed, got %v", err)
+		}
+
+		// Saving the dates again from a fresh read finishes whatever the failed edit left behind.
+		loaded, responses, err := storage.GetPoll(ctx, poll.ID)
+		if err != nil {
+			t.Fatalf("get poll: %v", err)
+		}
+		if err := storage.UpdatePollDays(ctx, poll.ID, loaded.Version, days, nil, time.Time{}, responsesForUpdatedDays(loaded, responses, days, nil)); err != nil {
+			t.Fatalf("retry update days: %v", err)
+		}
+		loaded, responses, err = storage.GetPoll(ctx, poll.ID)
+		if err != nil {
+			t.Fatalf("get poll: %v", err)
+		}
+		if !equalDays(loaded.Days, days) {
+			t.Fatalf("expected new days, got %v", loaded.Days)
+		}
+		for _, response := range responses {
+			if !equalDays(response.Days, days) {
+				t.Fatalf("expected every response pruned, got %+v", response)
+			}
+		}
+	})
+
+	t.Run("UpdatePollVenues", func(t *testing.T) {
+		storage := newStorage(t)
+		poll := seedPoll(t, storage, "poll-1")
+		venues := []Venue{{ID: "movie", Title: "Movie"}, {ID: "park", Title: "Park"}}
+		if err := storage.UpdatePollVenues(ctx, poll.ID, poll.Version, venues, nil); err != nil {
+			t.Fatalf("update venues: %v", err)
+		}
+		loaded, _, err := storage.GetPoll(ctx, poll.ID)
+		if err != nil {
+			t.Fatalf("get poll: %v", err)
+		}
+		if len(loaded.Venues) != 2 || loaded.Venues[0] != venues[0] || loaded.Venues[1] != venues[1] {
+			t.Fatalf("unexpected venues after update: %+v", loaded.Venues)
+		}
+		if err := storage.UpdatePollVenues(ctx, "missing", 0, venues, nil); !errors.Is(err, errNotFound) {
+			t.Fatalf("expected errNotFound, got %v", err)
+		}
+		if _, _, err := storage.GetPoll(ctx, "missing"); !errors.Is(err, errNotFound) {
+			t.Fatalf("expected update not to create a poll, got %v", err)
+		}
+	})
+
+	t.Run("UpdatePollVenuesPrunesVotes", func(t *testing.T) {
+		storage := newStorage(t)
+		poll := seedPoll(t, storage, "poll-1")
+		response := Response{ID: "resp-1", Name: "Sam", Days: poll.Days, VenueVotes: []string{"park"}, UserToken: "sam", CreatedAt: base}
+		if err := storage.AddResponse(ctx, poll.ID, response); err != nil {
+			t.Fatalf("add response: %v", err)
+		}
+		_, responses, err := storage.GetPoll(ctx, poll.ID)
+		if err != nil {
+			t.Fatalf("get poll: %v", err)
+		}
+		pruned := responses[0]
+		pruned.VenueVotes = nil
+		stale := pruned
+		stale.Version = 0
+		venues := []Venue{{ID: "movie", Title: "Movie"}}
+		if err := storage.UpdatePollVenues(ctx, poll.ID, poll.Version, venues, []Response{stale}); !errors.Is(err, errConflict) {
+			t.Fatalf("expected errConflict for a stale response, got %v", err)
+		}
+		loaded, responses, err := storage.GetPoll(ctx, poll.ID)
+		if err != nil || loaded.Venues[0].ID != "park" || !equalDays(responses[0].VenueVotes, []string{"park"}) {
+			t.Fatalf("expected nothing written after the conflict, got %+v %+v %v", loaded.Venues, responses, err)
+		}
+		if err := storage.UpdatePollVenues(ctx, poll.ID, poll.Version, venues, []Response{pruned}); err != nil {
+			t.Fatalf("update venues: %v", err)
+		}
+		loaded, responses, err = storage.GetPoll(ctx, poll.ID)
+		if err != nil || len(loaded.Venues) != 1 || loaded.Venues[0].ID != "movie" {
+			t.Fatalf("expected the new venues, got %+v %v", loaded.Venues, err)
+		}
+		if len(responses[0].VenueVotes) != 0 || responses[0].Version != pruned.Version+1 {
+			t.Fatalf("expected the removed venue's vote pruned, got %+v", responses[0])
+		}
+	})
+
+	t.Run("ResponseOrganizerToken", func(t *testing.T) {
+		storage := newStorage(t)
+		poll := seedPoll(t, storage, "poll-1")
+		response := Response{ID: "resp-jo", Name: "Jo", Days: poll.Days, UserToken: "jo", CreatedAt: time.Now().UTC()}
+		if err := storage.AddResponse(ctx, poll.ID, response); err != nil {
+			t.Fatalf("add response: %v", err)
+		}
+		response.Version = 1
+		response.OrganizerToken = "jo-manage"
+		if err := storage.AddResponse(ctx, poll.ID, response); err != nil {
+			t.Fatalf("promote: %v", err)
+		}
+		_, responses, err := storage.GetPoll(ctx, poll.ID)
+		if err != nil {
+			t.Fatalf("get poll: %v", err)
+		}
+		if len(responses) != 1 || responses[0].OrganizerToken != "jo-manage" || responses[0].Version != 2 {
+			t.Fatalf("expected organizer token stored, got %+v", responses)
+		}
+		if err := storage.AddResponse(ctx, poll.ID, response); !errors.Is(err, errConflict) {
+			t.Fatalf("expected errConflict for stale version, got %v", err)
+		}
+		if err := storage.DeleteResponse(ctx, poll.ID, "resp-jo"); err != nil {
+			t.Fatalf("delete response: %v", err)
+		}
+		if _, responses, err = storage.GetPoll(ctx, poll.ID); err != nil || findResponseByOrganizerToken(responses, "jo-manage") != nil {
+			t.Fatalf("expected organizer token gone with the response, got %+v, %v", responses, err)
+		}
+	})
+
+	t.Run("UpdatePollSettings", func(t *testing.T) {
+		storage := newStorage(t)
+		poll := seedPoll(t, storage, "poll-1")
+		settings := PollSettings{
+			RequiredAttendees: []string{"resp-jo"},
+			Quorum:            3,
+			TripLength:        2,
+			ClosedAt:          base.Add(time.Hour),
+			Deadline:          base.Add(48 * time.Hour),
+			FinalDay:          poll.Days[0],
+			FinalVenueID:      "movie",
+			TimeZone:          "America/Los_Angeles",
+		}
+		if err := storage.UpdatePollSettings(ctx, poll.ID, poll.Version, settings); err != nil {
+			t.Fatalf("update settings: %v", err)
+		}
+		loaded, _, err := storage.GetPoll(ctx, poll.ID)
+		if err != nil {
+			t.Fatalf("get poll: %v", err)
+		}
+		if !reflect.DeepEqual(pollSettings(loaded), settings) || loaded.Version != poll.Version+1 {
+			t.Fatalf("unexpected poll after update: %+v", loaded)
+		}
+		if !equalDays(loaded.Days, poll.Days) || len(loaded.Venues) != len(poll.Venues) {
+			t.Fatalf("expected days and venues untouched, got %+v", loaded)
+		}
+		if err := storage.UpdatePollSettings(ctx, poll.ID, poll.Version, PollSettings{}); !errors.Is(err, errConflict) {
+			t.Fatalf("expected errConflict for stale version, got %v", err)
+		}
+		if err := storage.UpdatePollSettings(ctx, poll.ID, loaded.Version, PollSettings{}); err != nil {
+			t.Fatalf("clear settings: %v", err)
+		}
+		loaded, _, err = storage.GetPoll(ctx, poll.ID)
+		if err != nil {
+			t.Fatalf("get poll: %v", err)
+		}
+		if cleared := pollSettings(loaded); len(cleared.RequiredAttendees) != 0 || cleared.Quorum != 0 || cleared.TripLength != 0 ||
+			!cleared.ClosedAt.IsZero() || !cleared.Deadline.IsZero() || cleared.FinalDay != "" || cleared.FinalVenueID != "" || cleared.TimeZone != "" {
+			t.Fatalf("expected settings cleared, got %+v", loaded)
+		}
+		if err := storage.UpdatePollSettings(ctx, "missing", 0, settings); !errors.Is(err, errNotFound) {
+			t.Fatalf("expected errNotFound, got %v", err)
+		}
+	})
+
+	t.Run("UpdatePollCredentials", func(t *testing.T) {
+		storage := newStorage(t)
+		poll := seedPoll(t, storage, "poll-1")
+		creator := Response{ID: "resp-1", Name: "Alex", Days: poll.Days, UserToken: poll.CreatorToken, CreatedAt: base}
+		if err := storage.AddResponse(ctx, poll.ID, creator); err != nil {
+			t.Fatalf("add response: %v", err)
+		}
+		_, responses, err := storage.GetPoll(ctx, poll.ID)
+		if err != nil {
+			t.Fatalf("get poll: %v", err)
+		}
+		moved := responses[0]
+		moved.UserToken = "creator-2"
+		credentials := PollCredentials{CreatorToken: "creator-2", AdminToken: "admin-2", RecoveryHash: hashRecoveryCode("code")}
+		if err := storage.UpdatePollCredentials(ctx, poll.ID, poll.Version, credentials, &moved); err != nil {
+			t.Fatalf("update credentials: %v", err)
+		}
+		loaded, responses, err := storage.GetPoll(ctx, poll.ID)
+		if err != nil {
+			t.Fatalf("get poll: %v", err)
+		}
+		if loaded.CreatorToken != "creator-2" || loaded.AdminToken != "admin-2" || loaded.RecoveryHash != credentials.RecoveryHash || loaded.Version != poll.Version+1 {
+			t.Fatalf("unexpected poll after update: %+v", loaded)
+		}
+		if len(responses) != 1 || responses[0].UserToken != "creator-2" || !equalDays(responses[0].Days, poll.Days) {
+			t.Fatalf("expected creator response moved, got %+v", responses)
+		}
+		if err := storage.UpdatePollCredentials(ctx, poll.ID, poll.Version, credentials, nil); !errors.Is(err, errConflict) {
+			t.Fatalf("expected errConflict for stale poll version, got %v", err)
+		}
+		if err := storage.UpdatePollCredentials(ctx, poll.ID, loaded.Version, PollCredentials{CreatorToken: "creator-3", AdminToken: "admin-3"}, &moved); !errors.Is(err, errConflict) {
+			t.Fatalf("expected errConflict for stale response version, got %v", err)
+		}
+		loaded, _, err = storage.GetPoll(ctx, poll.ID)
+		if err != nil {
+			t.Fatalf("get poll: %v", err)
+		}
+		if loaded.CreatorToken != "creator-2" {
+			t.Fatalf("expected credentials untouched after failed update, got %+v", loaded)
+		}
+		if err := storage.UpdatePollCredentials(ctx, "missing", 0, credentials, nil); !errors.Is(err, errNotFound) {
+			t.Fatalf("expected errNotFound, got %v", err)
+		}
+	})
+
+	t.Run("ArchivePoll", func(t *testing.T) {
+		storage := newStorage(t)
+		poll := seedPoll(t, storage, "poll-1")
+		for i := 1; i <= 3; i++ {
+			response := Response{ID: fmt.Sprintf("resp-%d", i), Name: "Name", Days: poll.Days, UserToken: fmt.Sprintf("token-%d", i), CreatedAt: base}
+			if err := storage.AddResponse(ctx, poll.ID, response); err != nil {
+				t.Fatalf("add response: %v", err)
+			}
+		}
+		archivedAt := base.Add(72 * time.Hour)
+		if err := storage.ArchivePoll(ctx, poll.ID, poll.Version, archivedAt); err != nil {
+			t.Fatalf("archive: %v", err)
+		}
+		loaded, responses, err := storage.GetPoll(ctx, poll.ID)
+		if err != nil {
+			t.Fatalf("get poll: %v", err)
+		}
+		if !loaded.ArchivedAt.Equal(archivedAt) || loaded.Version != poll.Version+1 || len(responses) != 3 {
+			t.Fatalf("unexpected poll after archiving: %+v", loaded)
+		}
+		if err := storage.ArchivePoll(ctx, poll.ID, poll.Version, archivedAt); !errors.Is(err, errConflict) {
+			t.Fatalf("expected errConflict for stale version, got %v", err)
+		}
+		if err := storage.ArchivePoll(ctx, "missing", 0, archivedAt); !errors.Is(err, errNotFound) {
+			t.Fatalf("expected errNotFound, got %v", err)
+		}
+	})
+
+	t.Run("PollExpiry", func(t *testing.T) {
+		storage := newStorage(t)
+		expiresAt := base.Add(60 * 24 * time.Hour)
+		poll := Poll{ID: "poll-1", Title: "Hang", Days: []string{"2024-01-01"}, CreatorToken: "creator", CreatedAt: base, ExpiresAt: expiresAt}
+		if err := storage.CreatePoll(ctx, poll); err != nil {
+			t.Fatalf("create poll: %v", err)
+		}
+		// More responses than fit in one transaction, so the expiry also reaches the later chunks.
+		const count = maxTransactionItems + 5
+		for i := 0; i < count; i++ {
+			response := Response{ID: fmt.Sprintf("resp-%03d", i), Name: "Name", Days: poll.Days, UserToken: fmt.Sprintf("token-%d", i), CreatedAt: base}
+			if err := storage.AddResponse(ctx, poll.ID, response); err != nil {
+				t.Fatalf("add response: %v", err)
+			}
+		}
+		writeIn := Response{ID: "resp-write-in", Name: "Sam", VenueVotes: []string{"arcade"}, UserToken: "sam", CreatedAt: base}
+		if err := storage.AddVenueWriteIn(ctx, poll.ID, Venue{ID: "arcade", Title: "Arcade"}, writeIn); err != nil {
+			t.Fatalf("add write-in: %v", err)
+		}
+		loaded, responses, err := storage.GetPoll(ctx, poll.ID)
+		if err != nil || !loaded.ExpiresAt.Equal(expiresAt) {
+			t.Fatalf("expected poll to keep its expiry, got %+v %v", loaded, err)
+		}
+		assertPartitionExpires(t, storage, poll.ID, expiresAt)
+
+		extended := expiresAt.Add(7 * 24 * time.Hour)
+		changed := responses[0]
+		changed.Days = []string{"2024-01-08"}
+		if err := storage.UpdatePollDays(ctx, poll.ID, loaded.Version, []string{"2024-01-01", "2024-01-08"}, nil, extended, []Response{changed}); err != nil {
+			t.Fatalf("update days: %v", err)
+		}
+		loaded, _, err = storage.GetPoll(ctx, poll.ID)
+		if err != nil || !loaded.ExpiresAt.Equal(extended) {
+			t.Fatalf("expected the date edit to move the expiry, got %+v %v", loaded, err)
+		}
+		assertPartitionExpires(t, storage, poll.ID, extended)
+
+		if err := storage.UpdatePollDays(ctx, poll.ID, loaded.Version, loaded.Days, nil, time.Time{}, nil); err != nil {
+			t.Fatalf("update days: %v", err)
+		}
+		loaded, _, err = storage.GetPoll(ctx, poll.ID)
+		if err != nil || !loaded.ExpiresAt.IsZero() {
+			t.Fatalf("expected the expiry cleared, got %+v %v", loaded, err)
+		}
+		assertPartitionExpires(t, storage, poll.ID, time.Time{})
+	})
+
+	t.Run("PollVersionConflicts", func(t *testing.T) {
+		storage := newStorage(t)
+		poll := seedPoll(t, storage, "poll-1")
+		if err := storage.UpdatePollVenues(ctx, poll.ID, poll.Version, []Venue{{ID: "movie", Title: "Movie"}}, nil); err != nil {
+			t.Fatalf("first update: %v", err)
+		}
+		if err := storage.UpdatePollVenues(ctx, poll.ID, poll.Version, []Venue{{ID: "arcade", Title: "Arcade"}}, nil); !errors.Is(err, errConflict) {
+			t.Fatalf("expected errConflict for stale venue update, got %v", err)
+		}
+		if err := storage.UpdatePollDays(ctx, poll.ID, poll.Version, []string{"2024-01-05"}, nil, time.Time{}, nil); !errors.Is(err, errConflict) {
+			t.Fatalf("expected errConflict for stale day update, got %v", err)
+		}
+		loaded, _, err := storage.GetPoll(ctx, poll.ID)
+		if err != nil {
+			t.Fatalf("get poll: %v", err)
+		}
+		if loaded.Version != poll.Version+1 || len(loaded.Venues) != 1 || loaded.Venues[0].ID != "movie" {
+			t.Fatalf("expected only the first update applied, got %+v", loaded)
+		}
+		if err := storage.UpdatePollDays(ctx, poll.ID, loaded.Version, []string{"2024-01-05"}, nil, time.Time{}, nil); err != nil {
+			t.Fatalf("update with fresh version: %v", err)
+		}
+	})
+
+	t.Run("ResponseVersionConflicts", func(t *testing.T) {
+		storage := newStorage(t)
+		poll := seedPoll(t, storage, "poll-1")
+		response := Response{ID: "resp-1", Name: "Alex", UserToken: "token", CreatedAt: base}
+		if err := storage.AddResponse(ctx, poll.ID, response); err != nil {
+			t.Fatalf("add response: %v", err)
+		}
+		if err := storage.AddResponse(ctx, poll.ID, response); !errors.Is(err, errConflict) {
+			t.Fatalf("expected errConflict for stale response write, got %v", err)
+		}
+		_, responses, err := storage.GetPoll(ctx, poll.ID)
+		if err != nil {
+			t.Fatalf("get poll: %v", err)
+		}
+		current := responses[0]
+		current.Name = "Alexandra"
+		if err := storage.AddResponse(ctx, poll.ID, current); err != nil {
+			t.Fatalf("update response: %v", err)
+		}
+		stale := current
+		stale.Name = "Stale"
+		if err := storage.AddResponse(ctx, poll.ID, stale); !errors.Is(err, errConflict) {
+			t.Fatalf("expected errConflict for reused version, got %v", err)
+		}
+	})
+
+	t.Run("AddVenueWriteIn", func(t *testing.T) {
+		storage := newStorage(t)
+		poll := seedPoll(t, storage, "poll-1")
+		venue := Venue{ID: "arcade", Title: "Arcade", URL: "https://example.com/arcade"}
+		response := Response{ID: "resp-1", Name: "Alex", Days: []string{"2024-01-01"}, VenueVotes: []string{"arcade"}, UserToken: "token", CreatedAt: base}
+		if err := storage.AddVenueWriteIn(ctx, poll.ID, venue, response); err != nil {
+			t.Fatalf("add write-in: %v", err)
+		}
+		loaded, responses, err := storage.GetPoll(ctx, poll.ID)
+		if err != nil {
+			t.Fatalf("get poll: %v", err)
+		}
+		if len(loaded.Venues) != 2 || loaded.Venues[0].ID != "park" || loaded.Venues[1] != venue {
+			t.Fatalf("expected write-in appended, got %+v", loaded.Venues)
+		}
+		if loaded.Version != poll.Version+1 {
+			t.Fatalf("expected write-in to bump poll version, got %d", loaded.Version)
+		}
+		if len(responses) != 1 || !equalDays(responses[0].VenueVotes, []string{"arcade"}) {
+			t.Fatalf("expected write-in vote recorded, got %+v", responses)
+		}
+		if err := storage.AddVenueWriteIn(ctx, "missing", venue, response); !errors.Is(err, errNotFound) {
+			t.Fatalf("expected errNotFound, got %v", err)
+		}
+	})
+
+	t.Run("AddVenueWriteInToPollWithoutVenues", func(t *testing.T) {
+		storage := newStorage(t)
+		poll := Poll{ID: "poll-1", Title: "Bare", Days: []string{"2024-01-01"}, CreatorToken: "creator", CreatedAt: base}
+		if err := storage.CreatePoll(ctx, poll); err != nil {
+			t.Fatalf("create poll: %v", err)
+		}
+		response := Response{ID: "resp-1", Name: "Alex", VenueVotes: []string{"arcade"}, UserToken: "token", CreatedAt: base}
+		if err := storage.AddVenueWriteIn(ctx, poll.ID, Venue{ID: "arcade", Title: "Arcade"}, response); err != nil {
+			t.Fatalf("add write-in: %v", err)
+		}
+		loaded, _, err := storage.GetPoll(ctx, poll.ID)
+		if err != nil {
+			t.Fatalf("get poll: %v", err)
+		}
+		if len(loaded.Venues) != 1 || loaded.Venues[0].ID != "arcade" {
+			t.Fatalf("expected write-in stored, got %+v", loaded.Venues)
+		}
+	})
+
+	t.Run("AddVenueWriteInIsAtomic", func(t *testing.T) {
+		storage := newStorage(t)
+		poll := seedPoll(t, storage, "poll-1")
+		response := Response{ID: "resp-1", Name: "Alex", UserToken: "token", CreatedAt: base}
+		if err := storage.AddResponse(ctx, poll.ID, response); err != nil {
+			t.Fatalf("add response: %v", err)
+		}
+		if err := storage.AddVenueWriteIn(ctx, poll.ID, Venue{ID: "arcade", Title: "Arcade"}, response); !errors.Is(err, errConflict) {
+			t.Fatalf("expected errConflict for stale response, got %v", err)
+		}
+		loaded, _, err := storage.GetPoll(ctx, poll.ID)
+		if err != nil {
+			t.Fatalf("get poll: %v", err)
+		}
+		if len(loaded.Venues) != 1 || loaded.Version != poll.Version {
+			t.Fatalf("expected venue not appended when response write fails, got %+v", loaded)
+		}
+	})
+
+	t.Run("ConcurrentWriteInsAllKept", func(t *testing.T) {
+		storage := newStorage(t)
+		poll := seedPoll(t, storage, "poll-1")
+		const writers = 5
+		var wg sync.WaitGroup
+		for i := 0; i < writers; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				venue := Venue{ID: fmt.Sprintf("venue-%d", i), Title: fmt.Sprintf("Venue %d", i)}
+				response := Response{ID: fmt.Sprintf("resp-%d", i), Name: "Alex", VenueVotes: []string{venue.ID}, UserToken: fmt.Sprintf("token-%d", i), CreatedAt: base}
+				if err := storage.AddVenueWriteIn(ctx, poll.ID, venue, response); err != nil {
+					t.Errorf("add write-in: %v", err)
+				}
+			}(i)
+		}
+		wg.Wait()
+		loaded, responses, err := storage.GetPoll(ctx, poll.ID)
+		if err != nil {
+			t.Fatalf("get poll: %v", err)
+		}
+		if len(loaded.Venues) != writers+1 || len(responses) != writers {
+			t.Fatalf("expected every write-in kept, got %d venues and %d responses", len(loaded.Venues), len(responses))
+		}
+	})
+
+	t.Run("GetStats", func(t *testing.T) {
+		storage := newStorage(t)
+		first := seedPoll(t, storage, "poll-1")
+		second := seedPoll(t, storage, "poll-2")
+		for i, pollID := range []string{first.ID, first.ID, second.ID} {
+			response := Response{ID: fmt.Sprintf("resp-%d", i), Name: "Alex", UserToken: fmt.Sprintf("token-%d", i), CreatedAt: base}
+			if err := storage.AddResponse(ctx, pollID, response); err != nil {
+				t.Fatalf("add response: %v", err)
+			}
+		}
+		resaved := Response{ID: "resp-1", Name: "Alex B", UserToken: "token-1", CreatedAt: base, Version: 1}
+		if err := storage.AddResponse(ctx, first.ID, resaved); err != nil {
+			t.Fatalf("re-save response: %v", err)
+		}
+		if err := storage.DeleteResponse(ctx, first.ID, "resp-0"); err != nil {
+			t.Fatalf("delete response: %v", err)
+		}
+		if err := storage.DeleteResponse(ctx, first.ID, "resp-0"); err != nil {
+			t.Fatalf("delete missing response: %v", err)
+		}
+		writeIn := Response{ID: "resp-3", Name: "Sam", VenueVotes: []string{"arcade"}, UserToken: "token-3", CreatedAt: base}
+		if err := storage.AddVenueWriteIn(ctx, second.ID, Venue{ID: "arcade", Title: "Arcade"}, writeIn); err != nil {
+			t.Fatalf("write-in: %v", err)
+		}
+		stats, err := storage.GetStats(ctx)
+		if err != nil {
+			t.Fatalf("stats: %v", err)
+		}
+		if stats.PollCount != 2 || stats.ResponseCount != 3 || stats.WriteInCount != 1 || stats.DeletionCount != 1 {
+			t.Fatalf("unexpected stats: %+v", stats)
+		}
+	})
+
+	t.Run("GetStatsSeries", func(t *testing.T) {
+		storage := newStorage(t)
+		seedPoll(t, storage, "poll-1")
+		bare := Poll{ID: "poll-2", Title: "Bare", Days: []string{"2024-01-02"}, CreatorToken: "creator", CreatedAt: base.AddDate(0, 0, 1)}
+		if err := storage.CreatePoll(ctx, bare); err != nil {
+			t.Fatalf("create poll: %v", err)
+		}
+		for i := 0; i < 3; i++ {
+			response := Response{ID: fmt.Sprintf("resp-%d", i), Name: "Alex", UserToken: fmt.Sprintf("token-%d", i), CreatedAt: base}
+			if err := storage.AddResponse(ctx, "poll-1", response); err != nil {
+				t.Fatalf("add response: %v", err)
+			}
+		}
+		writeIn := Response{ID: "resp-3", Name: "Sam", VenueVotes: []string{"arcade"}, UserToken: "token-3", CreatedAt: base.AddDate(0, 0, 1)}
+		if err := storage.AddVenueWriteIn(ctx, "poll-2", Venue{ID: "arcade", Title: "Arcade"}, writeIn); err != nil {
+			t.Fatalf("write-in: %v", err)
+		}
+
+		series, err := storage.GetStatsSeries(ctx, "2024-01-01", "2024-01-02")
+		if err != nil {
+			t.Fatalf("series: %v", err)
+		}
+		want := []StatsPeriod{
+			{Start: "2024-01-01", StatsCounters: StatsCounters{Polls: 1, VenuePolls: 1, Responses: 3}},
+			{Start: "2024-01-02", StatsCounters: StatsCounters{Polls: 1, Responses: 1}},
+		}
+		if !reflect.DeepEqual(series, want) {
+			t.Fatalf("expected %+v, got %+v", want, series)
+		}
+		today := time.Now().UTC().Format("2006-01-02")
+		series, err = storage.GetStatsSeries(ctx, today, today)
+		if err != nil {
+			t.Fatalf("series: %v", err)
+		}
+		if len(series) != 1 || series[0].WriteIns != 1 {
+			t.Fatalf("expected today's write-in, got %+v", series)
+		}
+		if err := storage.DeleteResponse(ctx, "poll-1", "resp-0"); err != nil {
+			t.Fatalf("delete response: %v", err)
+		}
+		series, err = storage.GetStatsSeries(ctx, today, today)
+		if err != nil {
+			t.Fatalf("series: %v", err)
+		}
+		if len(series) != 1 || series[0].WriteIns != 1 || series[0].Deletions != 1 || series[0].Start != today {
+			t.Fatalf("expected today's write-in and deletion, got %+v", series)
+		}
+		series, err = storage.GetStatsSeries(ctx, "2023-01-01", "2023-12-31")
+		if err != nil {
+			t.Fatalf("series: %v", err)
+		}
+		if len(series) != 0 {
+			t.Fatalf("expected empty series, got %+v", series)
+		}
+	})
+}
+
+func TestStatsShardKeysSortWithTheirDay(t *testing.T) {
+	sk := func(key map[string]types.AttributeValue) string {
+		return key["sk"].(*types.AttributeValueMemberS).Value
+	}
+	if sk(statsShardKey(0)) != "STATS" || sk(statsShardKey(3)) != "STATS#3" {
+		t.Fatalf("unexpected total shard keys %q %q", sk(statsShardKey(0)), sk(statsShardKey(3)))
+	}
+	keys := []string{sk(statsDayKey("2024-01-02", 0)), sk(statsDayKey("2024-01-02", statsShards-1)), "DAY#2024-01-02#~", sk(statsDayKey("2024-01-03", 0))}
+	if !sort.StringsAreSorted(keys) {
+		t.Fatalf("expected day shards between the day and the range end, got %v", keys)
+	}
+}
+
+func TestDynamoDBStatsUpdateAliasesAttributes(t *testing.T) {
+	storage := &DynamoDBStorage{Table: "bff-hang"}
+	items := storage.statsUpdates(statsChange{}.with(time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), StatsCounters{Responses: 1}))
+	if len(items) != 2 {
+		t.Fatalf("expected total and day updates, got %d", len(items))
+	}
+	day := items[1].Update
+	if !strings.HasPrefix(*day.UpdateExpression, "SET #t = :type, #day = :day ADD ") || day.ExpressionAttributeNames["#day"] != "day" {
+		t.Fatalf("expected the reserved word day to be aliased, got %q %v", *day.UpdateExpression, day.ExpressionAttributeNames)
+	}
+}
+
+// DynamoDB copies the poll's expires_at onto every item of its partition so the TTL removes them
+// together. Other backends keep the expiry on the poll only.
+func assertPartitionExpires(t *testing.T, storage Storage, pollID string, expiresAt time.Time) {
+	t.Helper()
+	dynamo, ok := storage.(*DynamoDBStorage)
+	if !ok {
+		return
+	}
+	out, err := dynamo.client.Query(context.Background(), &dynamodb.QueryInput{
+		TableName:                 &dynamo.Table,
+		KeyConditionExpression:    awsString("pk = :pk"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{":pk": &types.AttributeValueMemberS{Value: pollPartitionKey(pollID)}},
+		ConsistentRead:            awsBool(true),
+	})
+	if err != nil {
+		t.Fatalf("query partition: %v", err)
+	}
+	for _, item := range out.Items {
+		if got := itemExpiresAt(item); got != optionalUnix(expiresAt) {
+			t.Fatalf("expected %v to expire at %d, got %d", item["sk"], optionalUnix(expiresAt), got)
+		}
+	}
+}
+
+func TestMemoryStorageConformance(t *testing.T) {
+	runStorageConformance(t, func(t *testing.T) Storage {
+		return newMemoryStorage()
+	})
+}
+
+func TestFileStorageConformance(t *testing.T) {
+	runStorageConformance(t, func(t *testing.T) Storage {
+		storage, err := newFileStorage(filepath.Join(t.TempDir(), "bff-hang.json"))
+		if err != nil {
+			t.Fatalf("open file storage: %v", err)
+		}
+		return storage
+	})
+}
+
+// Runs against DynamoDB Local (or any compatible endpoint) when DYNAMODB_TEST_ENDPOINT is set.
+func TestDynamoDBStorageConformance(t *testing.T) {
+	endpoint := dynamoDBTestEndpoint(t)
+	runStorageConformance(t, func(t *testing.T) Storage {
+		return newTestDynamoDBStorage(t, endpoint)
+	})
+}
+
+func dynamoDBTestEndpoint(t *testing.T) string {
+	t.Helper()
+	endpoint := os.Getenv("DYNAMODB_TEST_ENDPOINT")
+	if endpoint == "" {
+		t.Skip("DYNAMODB_TEST_ENDPOINT not set")
+	}
+	for key, value := range map[string]string{
+		"AWS_REGION":            "us-east-1",
+		"AWS_ACCESS_KEY_ID":     "local",
+		"AWS_SECRET_ACCESS_KEY": "local",
+	} {
+		if os.Getenv(key) == "" {
+			t.Setenv(key, value)
+		}
+	}
+	return endpoint
+}
+
+func TestMemoryRateLimiterConformance(t *testing.T) {
+	runRateLimiterConformance(t, func(t *testing.T) RateLimiter {
+		return newMemoryRateLimiter()
+	})
+}
+
+func TestDynamoDBRateLimiterConformance(t *testing.T) {
+	endpoint := dynamoDBTestEndpoint(t)
+	runRateLimiterConformance(t, func(t *testing.T) RateLimiter {
+		storage := newTestDynamoDBStorage(t, endpoint)
+		return newRateLimiter(storage)
+	})
+}
+
+func runRateLimiterConformance(t *testing.T, newLimiter func(t *testing.T) RateLimiter) {
+	ctx := context.Background()
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	limit := RateLimit{Requests: 3, Per: time.Minute}
+
+	t.Run("TokenBucket", func(t *testing.T) {
+		limiter := newLimiter(t)
+		for i := 0; i < limit.Requests; i++ {
+			if allowed, _, err := limiter.Allow(ctx, "ip:a", limit, now); err != nil || !allowed {
+				t.Fatalf("request %d: expected allowed, got %v %v", i+1, allowed, err)
+			}
+		}
+		allowed, wait, err := limiter.Allow(ctx, "ip:a", limit, now)
+		if err != nil || allowed || wait != 20*time.Second {
+			t.Fatalf("expected empty bucket with 20s wait, got %v %v %v", allowed, wait, err)
+		}
+		if allowed, _, _ := limiter.Allow(ctx, "ip:b", limit, now); !allowed {
+			t.Fatalf("expected other keys unaffected")
+		}
+		if allowed, _, _ := limiter.Allow(ctx, "ip:a", limit, now.Add(20*time.Second)); !allowed {
+			t.Fatalf("expected one token after refill")
+		}
+		if allowed, _, _ := limiter.Allow(ctx, "ip:a", limit, now.Add(20*time.Second)); allowed {
+			t.Fatalf("expected only one token refilled")
+		}
+		for i := 0; i < limit.Requests; i++ {
+			if allowed, _, _ := limiter.Allow(ctx, "ip:a", limit, now.Add(time.Hour)); !allowed {
+				t.Fatalf("expected a full bucket after a long pause")
+			}
+		}
+	})
+
+	t.Run("Concurrent", func(t *testing.T) {
+		limiter := newLimiter(t)
+		var wg sync.WaitGroup
+		var mu sync.Mutex
+		allowedCount := 0
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				allowed, _, err := limiter.Allow(ctx, "poll:p", limit, now)
+				if err != nil {
+					t.Errorf("allow: %v", err)
+				}
+				if allowed {
+					mu.Lock()
+					allowedCount++
+					mu.Unlock()
+				}
+			}()
+		}
+		wg.Wait()
+		if allowedCount == 0 || allowedCount > limit.Requests {
+			t.Fatalf("expected between 1 and %d requests allowed, got %d", limit.Requests, allowedCount)
+		}
+	})
+}
+
+func TestParseRateLimits(t *testing.T) {
+	limits, err := parseRateLimits("")
+	if err != nil || !reflect.DeepEqual(limits, defaultRateLimits) {
+		t.Fatalf("expected defaults, got %v %v", limits, err)
+	}
+	limits, err = parseRateLimits(" create-poll.ip=5/30m, respond.poll=off ")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if limits["create-poll.ip"] != (RateLimit{Requests: 5, Per: 30 * time.Minute}) {
+		t.Fatalf("unexpected create-poll limit %+v", limits["create-poll.ip"])
+	}
+	if _, ok := limits["respond.poll"]; ok {
+		t.Fatalf("expected respond.poll disabled")
+	}
+	if limits["respond.ip"] != defaultRateLimits["respond.ip"] || defaultRateLimits["respond.poll"].Requests == 0 {
+		t.Fatalf("expected other limits and the defaults untouched")
+	}
+	for _, spec := range []string{"bogus.ip=1/1m", "respond.ip", "respond.ip=0/1m", "respond.ip=5", "respond.ip=5/soon"} {
+		if _, err := parseRateLimits(spec); err == nil {
+			t.Fatalf("expected %q rejected", spec)
+		}
+	}
+}
+
+func TestParseRetention(t *testing.T) {
+	cases := map[string]time.Duration{
+		"":     0,
+		"90d":  90 * 24 * time.Hour,
+		"720h": 720 * time.Hour,
+	}
+	for value, want := range cases {
+		if got, err := parseRetention(value); err != nil || got != want {
+			t.Fatalf("retention %q: expected %v, got %v %v", value, want, got, err)
+		}
+	}
+	for _, value := range []string{"0d", "-1h", "soon", "d"} {
+		if _, err := parseRetention(value); err == nil {
+			t.Fatalf("expected %q rejected", value)
+		}
+	}
+}
+
+func TestPollExpiry(t *testing.T) {
+	retention := 30 * 24 * time.Hour
+	want := time.Date(2024, 3, 12, 0, 0, 0, 0, time.UTC).Add(retention)
+	if got := pollExpiry([]string{"2024-03-10", "2024-03-01"}, retention); !got.Equal(want) {
+		t.Fatalf("expected expiry two days after the last day plus retention, got %v", got)
+	}
+	if got := pollExpiry(nil, retention); !got.IsZero() {
+		t.Fatalf("expected polls without days never to expire, got %v", got)
+	}
+	if got := pollExpiry([]string{"2024-03-10"}, 0); !got.IsZero() {
+		t.Fatalf("expected no expiry without a retention, got %v", got)
+	}
+}
+
+func TestLimitRate(t *testing.T) {
+	app, _ := newTestApp(t)
+	app.limiter = newMemoryRateLimiter()
+	app.rateLimits = map[string]RateLimit{
+		"create-poll.ip": {Requests: 2, Per: time.Hour},
+		"respond.poll":   {Requests: 3, Per: time.Hour},
+	}
+	handler := app.limitRate(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	send := func(method, path, ip string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.RemoteAddr = ip + ":1234"
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	for i := 0; i < 2; i++ {
+		if w := send(http.MethodPost, "/polls", "203.0.113.7"); w.Code != http.StatusNoContent {
+			t.Fatalf("request %d: expected allowed, got %d", i+1, w.Code)
+		}
+	}
+	w := send(http.MethodPost, "/polls", "203.0.113.7")
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") == "" {
+		t.Fatalf("expected 429 with Retry-After, got %d", w.Code)
+	}
+	if w := send(http.MethodPost, "/polls", "198.51.100.1"); w.Code != http.StatusNoContent {
+		t.Fatalf("expected other clients unaffected, got %d", w.Code)
+	}
+	if w := send(http.MethodGet, "/", "203.0.113.7"); w.Code != http.StatusNoContent {
+		t.Fatalf("expected reads never limited, got %d", w.Code)
+	}
+
+	for i, ip := range []string{"192.0.2.1", "192.0.2.2", "192.0.2.3"} {
+		if w := send(http.MethodPost, "/poll/poll-1/u/token-"+ip, ip); w.Code != http.StatusNoContent {
+			t.Fatalf("response %d: expected allowed, got %d", i+1, w.Code)
+		}
+	}
+	if w := send(http.MethodPost, "/poll/poll-1/u/fresh", "192.0.2.4"); w.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected per-poll limit across clients, got %d", w.Code)
+	}
+	if w := send(http.MethodPost, "/poll/poll-2/u/fresh", "192.0.2.4"); w.Code != http.StatusNoContent {
+		t.Fatalf("expected other polls unaffected, got %d", w.Code)
+	}
+}
+
+func TestClientIPForwardedFor(t *testing.T) {
+	app, _ := newTestApp(t)
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "10.0.0.1:4321"
+	req.Header.Add("X-Forwarded-For", "1.1.1.1, 203.0.113.7")
+	if ip := app.clientIP(req); ip != "10.0.0.1" {
+		t.Fatalf("expected X-Forwarded-For ignored by default, got %q", ip)
+	}
+	app.trustForwarded = true
+	if ip := app.clientIP(req); ip != "203.0.113.7" {
+		t.Fatalf("expected the address the proxy saw, got %q", ip)
+	}
+	req.Header.Del("X-Forwarded-For")
+	if ip := app.clientIP(req); ip != "10.0.0.1" {
+		t.Fatalf("expected RemoteAddr without the header, got %q", ip)
+	}
+}
+
+func newTestDynamoDBStorage(t *testing.T, endpoint string) *DynamoDBStorage {
+	t.Helper()
+	ctx := context.Background()
+	storage, err := newDynamoDBStorage(ctx, "bff-hang-test-"+strings.ToLower(randomID()), endpoint)
+	if err != nil {
+		t.Fatalf("create dynamodb client: %v", err)
+	}
+	_, err = storage.client.CreateTable(ctx, &dynamodb.CreateTableInput{
+		TableName:   &storage.Table,
+		BillingMode: types.BillingModePayPerRequest,
+		AttributeDefinitions: []types.AttributeDefinition{
+			{AttributeName: awsString("pk"), AttributeType: types.ScalarAttributeTypeS},
+			{AttributeName: awsString("sk"), AttributeType: types.ScalarAttributeTypeS},
+		},
+		KeySchema: []types.KeySchemaElement{
+			{AttributeName: awsString("pk"), KeyType: types.KeyTypeHash},
+			{AttributeName: awsString("sk"), KeyType: types.KeyTypeRange},
+		},
+	})
+	if err != nil {
+		t.Fatalf("create table: %v", err)
+	}
+	t.Cleanup(func() {
+		storage.client.DeleteTable(context.Background(), &dynamodb.DeleteTableInput{TableName: &storage.Table})
+	})
+	waiter := dynamodb.NewTableExistsWaiter(storage.client)
+	if err := waiter.Wait(ctx, &dynamodb.DescribeTableInput{TableName: &storage.Table}, time.Minute); err != nil {
+		t.Fatalf("wait for table: %v", err)
+	}
+	return storage
+}
+
+func TestHandleHome(t *testing.T) {
+	app, _ := newTestApp(t)
+	req := httptest.NewRequest(http.MethodGet, "/?invalid=1", nil)
+	w := httptest.NewRecorder()
+	app.handleHome(w, req)
+	res := w.Result()
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", res.StatusCode)
+	}
+	body, _ := io.ReadAll(res.Body)
+	if !strings.Contains(string(body), "That link was invalid") {
+		t.Fatalf("expected invalid message")
+	}
+}
+
+func TestHandleCreatePollValidation(t *testing.T) {
+	app, _ := newTestApp(t)
+	req := newFormRequest(http.MethodPost, "/polls", url.Values{})
+	w := httptest.NewRecorder()
+	app.handleCreatePoll(w, req)
+	if w.Result().StatusCode != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422 for invalid form")
+	}
+}
+
+func TestHandleCreatePollFieldErrors(t *testing.T) {
+	app, storage := newTestApp(t)
+	form := url.Values{}
+	form.Set("title", strings.Repeat("x", maxTitleLength+1))
+	form.Set("creator", "Sam")
+	form.Add("days", "garbage")
+	form.Add("venue_title", "Arcade")
+	form.Add("venue_url", "javascript:alert(1)")
+	w := httptest.NewRecorder()
+	app.handleCreatePoll(w, newFormRequest(http.MethodPost, "/polls", form))
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422, got %d", w.Code)
+	}
+	body := w.Body.String()
+	for _, field := range []string{"title=", "days=", "venues="} {
+		if !strings.Contains(body, field) {
+			t.Fatalf("expected %s error in %q", field, body)
+		}
+	}
+	if strings.Contains(body, "creator=") {
+		t.Fatalf("expected no error for a valid name, got %q", body)
+	}
+	if len(storage.polls) != 0 {
+		t.Fatalf("expected nothing stored")
+	}
+}
+
+func TestHandleCreatePollSuccess(t *testing.T) {
+	app, storage := newTestApp(t)
+	app.retention = 30 * 24 * time.Hour
+	form := url.Values{}
+	form.Set("title", "Dinner")
+	form.Set("creator", "Sam")
+	form.Add("days", daysFromToday(2))
+	form.Add("days", daysFromToday(1))
+	req := newFormRequest(http.MethodPost, "/polls", form)
+	w := httptest.NewRecorder()
+	app.handleCreatePoll(w, req)
+	res := w.Result()
+	if res.StatusCode != http.StatusSeeOther {
+		t.Fatalf("expected redirect, got %d", res.StatusCode)
+	}
+	if len(storage.polls) != 1 {
+		t.Fatalf("expected 1 poll stored")
+	}
+	var poll Poll
+	for _, stored := range storage.polls {
+		poll = stored
+	}
+	if poll.Title != "Dinner" {
+		t.Fatalf("expected poll title stored")
+	}
+	if want := pollExpiry(poll.Days, app.retention); want.IsZero() || !poll.ExpiresAt.Equal(want) {
+		t.Fatalf("expected the poll to expire at %v, got %v", want, poll.ExpiresAt)
+	}
+	if poll.AdminToken == "" || poll.AdminToken == poll.CreatorToken {
+		t.Fatalf("expected a separate admin token, got %q", poll.AdminToken)
+	}
+	if location := res.Header.Get("Location"); location != "/poll/"+poll.ID+"/manage/"+poll.AdminToken {
+		t.Fatalf("expected redirect to the management URL, got %s", location)
+	}
+	responses := storage.responses[poll.ID]
+	if len(responses) != 1 {
+		t.Fatalf("expected creator response stored")
+	}
+	if responses[0].UserToken != poll.CreatorToken {
+		t.Fatalf("expected creator token to match")
+	}
+	if !equalDays(responses[0].Days, []string{daysFromToday(1), daysFromToday(2)}) {
+		t.Fatalf("unexpected days: %v", responses[0].Days)
+	}
+}
+
+func TestHandleCreatePollDateRange(t *testing.T) {
+	app, storage := newTestApp(t)
+	start := time.Now().UTC().AddDate(0, 0, 1)
+	end := start.AddDate(0, 0, 13)
+	form := url.Values{
+		"title":         {"Trip"},
+		"creator":       {"Sam"},
+		"days":          {daysFromToday(0)},
+		"range_start":   {start.Format("2006-01-02")},
+		"range_end":     {end.Format("2006-01-02")},
+		"range_weekday": {"fri", "sat"},
+	}
+	w := httptest.NewRecorder()
+	app.handleCreatePoll(w, newFormRequest(http.MethodPost, "/polls", form))
+	if w.Code != http.StatusSeeOther {
+		t.Fatalf("expected redirect, got %d %q", w.Code, w.Body.String())
+	}
+	var poll Poll
+	for _, stored := range storage.polls {
+		poll = stored
+	}
+	want := []string{daysFromToday(0)}
+	for day := start; !day.After(end); day = day.AddDate(0, 0, 1) {
+		if day.Weekday() == time.Friday || day.Weekday() == time.Saturday {
+			want = append(want, day.Format("2006-01-02"))
+		}
+	}
+	if !equalDays(poll.Days, normalizeDays(want)) {
+		t.Fatalf("expected checked days plus Fridays and Saturdays, got %v", poll.Days)
+	}
+
+	form.Set("range_end", start.AddDate(0, 0, -1).Format("2006-01-02"))
+	w = httptest.NewRecorder()
+	app.handleCreatePoll(w, newFormRequest(http.MethodPost, "/polls", form))
+	if w.Code != http.StatusUnprocessableEntity || !strings.Contains(w.Body.String(), "range=") {
+		t.Fatalf("expected a backwards range rejected, got %d %q", w.Code, w.Body.String())
+	}
+
+	form = url.Values{
+		"title":       {"Trip"},
+		"creator":     {"Sam"},
+		"range_start": {daysFromToday(0)},
+		"range_end":   {daysFromToday(maxPollDays)},
+	}
+	w = httptest.NewRecorder()
+	app.handleCreatePoll(w, newFormRequest(http.MethodPost, "/polls", form))
+	if w.Code != http.StatusUnprocessableEntity || !strings.Contains(w.Body.String(), fmt.Sprintf("days=Pick at most %d days.", maxPollDays)) {
+		t.Fatalf("expected an oversized range rejected, got %d %q", w.Code, w.Body.String())
+	}
+}
+
+func TestHandleCreatePollWithVenues(t *testing.T) {
+	app, storage := newTestApp(t)
+	form := url.Values{}
+	form.Set("title", "Dinner")
+	form.Set("creator", "Sam")
+	form.Add("days", daysFromToday(0))
+	form.Add("venue_title", "Sushi place")
+	form.Add("venue_url", "https://example.com/sushi")
+	form.Add("venue_description", "Close to downtown")
+	req := newFormRequest(http.MethodPost, "/polls", form)
+	w := httptest.NewRecorder()
+	app.handleCreatePoll(w, req)
+	if w.Result().StatusCode != http.StatusSeeOther {
+		t.Fatalf("expected redirect, got %d", w.Result().StatusCode)
+	}
+	var poll Poll
+	for _, stored := range storage.polls {
+		poll = stored
+	}
+	if len(poll.Venues) != 1 {
+		t.Fatalf("expected one venue option, got %d", len(poll.Venues))
+	}
+	if poll.Venues[0].Title != "Sushi place" {
+		t.Fatalf("unexpected venue title: %s", poll.Venues[0].Title)
+	}
+}
+
+func TestHandlePollGetRedirect(t *testing.T) {
+	app, storage := newTestApp(t)
+	poll := Poll{ID: "poll-1", Title: "Hang", Days: []string{"2024-01-01"}, CreatorToken: "creator"}
+	storage.polls[poll.ID] = poll
+	req := httptest.NewRequest(http.MethodGet, "/poll/"+poll.ID, nil)
+	w := httptest.NewRecorder()
+	app.handlePoll(w, req)
+	res := w.Result()
+	if res.StatusCode != http.StatusSeeOther {
+		t.Fatalf("expected redirect, got %d", res.StatusCode)
+	}
+	if !strings.Contains(res.Header.Get("Location"), "/poll/"+poll.ID+"/u/") {
+		t.Fatalf("unexpected location: %s", res.Header.Get("Location"))
+	}
+}
+
+func TestHandlePollGetNotFound(t *testing.T) {
+	app, _ := newTestApp(t)
+	req := httptest.NewRequest(http.MethodGet, "/poll/missing/u/token", nil)
+	w := httptest.NewRecorder()
+	app.handlePoll(w, req)
+	if w.Result().StatusCode != http.StatusSeeOther {
+		t.Fatalf("expected redirect, got %d", w.Result().StatusCode)
+	}
+	if w.Result().Header.Get("Location") != "/?invalid=1" {
+		t.Fatalf("expected invalid redirect")
+	}
+}
+
+func TestHandlePollPostHTMXValidation(t *testing.T) {
+	app, storage := newTestApp(t)
+	poll := Poll{ID: "poll-1", Title: "Hang", Days: []string{"2024-01-01"}, CreatorToken: "creator"}
+	storage.polls[poll.ID] = poll
+	storage.responses[poll.ID] = []Response{{ID: "resp-1", Name: "Creator", Days: poll.Days, UserToken: poll.CreatorToken}}
+	form := url.Values{}
+	form.Set("name", "")
+	form.Add("days", "2024-01-01")
+	req := newFormRequest(http.MethodPost, "/poll/"+poll.ID+"/u/"+poll.CreatorToken, form)
+	req.Header.Set("HX-Request", "true")
+	w := httptest.NewRecorder()
+	app.handlePoll(w, req)
+	res := w.Result()
+	if res.StatusCode != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422, got %d", res.StatusCode)
+	}
+	if res.Header.Get("HX-Retarget") != "#poll-layout" || res.Header.Get("HX-Reselect") != "#poll-layout" {
+		t.Fatalf("expected HTMX pointed at the page layout, got %v", res.Header)
+	}
+	body, _ := io.ReadAll(res.Body)
+	if !strings.Contains(string(body), "poll Hang") || !strings.Contains(string(body), "name=Your name is required.") {
+		t.Fatalf("expected poll page with field error, got %q", body)
+	}
+}
+
+func TestHandlePollPostAddResponse(t *testing.T) {
+	app, storage := newTestApp(t)
+	poll := Poll{
+		ID:           "poll-1",
+		Title:        "Hang",
+		Days:         []string{"2024-01-01", "2024-01-02"},
+		Venues:       []Venue{{ID: "park", Title: "Park"}, {ID: "movie", Title: "Movie"}},
+		CreatorToken: "creator",
+	}
+	storage.polls[poll.ID] = poll
+	storage.responses[poll.ID] = []Response{{ID: "resp-1", Name: "Creator", Days: poll.Days, UserToken: poll.CreatorToken}}
+	form := url.Values{}
+	form.Set("name", "Jamie")
+	form.Add("days", "2024-01-02")
+	form.Add("venues", "movie")
+	req := newFormRequest(http.MethodPost, "/poll/"+poll.ID+"/u/user-token", form)
+	w := httptest.NewRecorder()
+	app.handlePoll(w, req)
+	if w.Result().StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Result().StatusCode)
+	}
+	responses := storage.responses[poll.ID]
+	if len(responses) != 2 {
+		t.Fatalf("expected response saved")
+	}
+	var names []string
+	for _, response := range responses {
+		names = append(names, response.Name)
+	}
+	sort.Strings(names)
+	if strings.Join(names, ",") != "Creator,Jamie" {
+		t.Fatalf("unexpected responses: %v", names)
+	}
+	var jamie Response
+	for _, response := range responses {
+		if response.Name == "Jamie" {
+			jamie = response
+			break
+		}
+	}
+	if !equalDays(jamie.VenueVotes, []string{"movie"}) {
+		t.Fatalf("unexpected venue votes: %v", jamie.VenueVotes)
+	}
+}
+
+func TestHandlePollPostDayPreferences(t *testing.T) {
+	app, storage := newTestApp(t)
+	days := []string{daysFromToday(1), daysFromToday(2), daysFromToday(3)}
+	poll := Poll{ID: "poll-1", Title: "Hang", Days: days, CreatorToken: "creator"}
+	storage.polls[poll.ID] = poll
+	form := url.Values{
+		"name":           {"Jamie"},
+		"day_" + days[0]: {"unavailable"},
+		"day_" + days[1]: {"if-need-be"},
+		"day_" + days[2]: {"available"},
+	}
+	w := httptest.NewRecorder()
+	app.handlePoll(w, newFormRequest(http.MethodPost, "/poll/poll-1/u/jamie", form))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	saved := storage.responses[poll.ID][0]
+	if !equalDays(saved.Days, days[2:]) || !equalDays(saved.IfNeedBeDays, days[1:2]) {
+		t.Fatalf("unexpected saved preferences %+v", saved)
+	}
+
+	form = url.Values{"name": {"Jamie"}, "day_" + days[0]: {"unavailable"}}
+	w = httptest.NewRecorder()
+	app.handlePoll(w, newFormRequest(http.MethodPost, "/poll/poll-1/u/jamie", form))
+	if w.Code != http.StatusUnprocessableEntity || !strings.Contains(w.Body.String(), "days=") {
+		t.Fatalf("expected a response without any possible day rejected, got %d %q", w.Code, w.Body.String())
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/poll/poll-1/u/jamie", nil)
+	view := app.buildPollView(req, storage.polls[poll.ID], storage.responses[poll.ID], "", "jamie", "")
+	if !view.SelectedDays[days[2]] || !view.SelectedIfNeedBeDays[days[1]] || view.SelectedDays[days[0]] {
+		t.Fatalf("expected saved preferences prefilled, got %v %v", view.SelectedDays, view.SelectedIfNeedBeDays)
+	}
+	if !view.AllAvailableDays[days[2]] || !view.AllIfNeedBeDays[days[1]] {
+		t.Fatalf("expected group highlights, got %v %v", view.AllAvailableDays, view.AllIfNeedBeDays)
+	}
+}
+
+func TestHandlePollPostWriteInVenue(t *testing.T) {
+	app, storage := newTestApp(t)
+	poll := Poll{
+		ID:           "poll-1",
+		Title:        "Hang",
+		Days:         []string{"2024-01-01"},
+		CreatorToken: "creator",
+	}
+	storage.polls[poll.ID] = poll
+	storage.responses[poll.ID] = []Response{{ID: "resp-1", Name: "Creator", Days: poll.Days, UserToken: poll.CreatorToken}}
+	form := url.Values{}
+	form.Set("name", "Jamie")
+	form.Add("days", "2024-01-01")
+	form.Set("write_in_venue_title", "Arcade")
+	form.Set("write_in_venue_url", "https://example.com/arcade")
+	form.Set("write_in_venue_description", "Open late")
+	req := newFormRequest(http.MethodPost, "/poll/"+poll.ID+"/u/user-token", form)
+	w := httptest.NewRecorder()
+	app.handlePoll(w, req)
+	if w.Result().StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Result().StatusCode)
+	}
+	updatedPoll := storage.polls[poll.ID]
+	if len(updatedPoll.Venues) != 1 {
+		t.Fatalf("expected write-in venue stored, got %+v", updatedPoll.Venues)
+	}
+	venue := updatedPoll.Venues[0]
+	if venue.Title != "Arcade" || venue.URL != "https://example.com/arcade" || venue.Description != "Open late" {
+		t.Fatalf("unexpected write-in venue: %+v", venue)
+	}
+	var jamie Response
+	for _, response := range storage.responses[poll.ID] {
+		if response.Name == "Jamie" {
+			jamie = response
+			break
+		}
+	}
+	if !equalDays(jamie.VenueVotes, []string{venue.ID}) {
+		t.Fatalf("expected write-in venue vote, got %v", jamie.VenueVotes)
+	}
+}
+
+func TestHandlePollPostWriteInVenueRequiresTitle(t *testing.T) {
+	app, storage := newTestApp(t)
+	poll := Poll{
+		ID:           "poll-1",
+		Title:        "Hang",
+		Days:         []string{"2024-01-01"},
+		CreatorToken: "creator",
+	}
+	storage.polls[poll.ID] = poll
+	form := url.Values{}
+	form.Set("name", "Jamie")
+	form.Add("days", "2024-01-01")
+	form.Set("write_in_venue_url", "https://example.com/arcade")
+	req := newFormRequest(http.MethodPost, "/poll/"+poll.ID+"/u/user-token", form)
+	w := httptest.NewRecorder()
+	app.handlePoll(w, req)
+	if w.Result().StatusCode != http.StatusUnprocessableEntity {
+		t.Fatalf("expected rendered validation response, got %d", w.Result().StatusCode)
+	}
+	if len(storage.polls[poll.ID].Venues) != 0 {
+		t.Fatalf("expected no venue stored")
+	}
+	if len(storage.responses[poll.ID]) != 0 {
+		t.Fatalf("expected no response stored")
+	}
+}
+
+func TestHandlePollPostResponseLimit(t *testing.T) {
+	app, storage := newTestApp(t)
+	poll := Poll{ID: "poll-1", Title: "Hang", Days: []string{"2024-01-01"}, CreatorToken: "creator"}
+	storage.polls[poll.ID] = poll
+	for i := 0; i < maxPollResponses; i++ {
+		storage.responses[poll.ID] = append(storage.responses[poll.ID], Response{ID: fmt.Sprint(i), Name: "Friend", Days: poll.Days, UserToken: fmt.Sprintf("user-%d", i)})
+	}
+	form := url.Values{"name": {"Late"}, "days": {"2024-01-01"}}
+	w := httptest.NewRecorder()
+	app.handlePoll(w, newFormRequest(http.MethodPost, "/poll/poll-1/u/late", form))
+	if w.Code != http.StatusUnprocessableEntity || len(storage.responses[poll.ID]) != maxPollResponses {
+		t.Fatalf("expected new response rejected on a full poll, got %d", w.Code)
+	}
+	form.Set("name", "Friend again")
+	w = httptest.NewRecorder()
+	app.handlePoll(w, newFormRequest(http.MethodPost, "/poll/poll-1/u/user-0", form))
+	if w.Code != http.StatusOK || storage.responses[poll.ID][0].Name != "Friend again" {
+		t.Fatalf("expected existing responders to keep editing, got %d", w.Code)
+	}
+}
+
+func TestHandlePollPostUpdateDatesValidation(t *testing.T) {
+	app, storage := newTestApp(t)
+	poll := Poll{ID: "poll-1", Title: "Hang", Days: []string{"2024-01-01"}, CreatorToken: "creator", AdminToken: "secret"}
+	storage.polls[poll.ID] = poll
+	form := url.Values{"action": {"update-dates"}, "days": {"2024-01-01", "not-a-day"}}
+	w := httptest.NewRecorder()
+	app.handlePoll(w, newFormRequest(http.MethodPost, "/poll/poll-1/manage/secret", form))
+	if w.Code != http.StatusUnprocessableEntity || !strings.Contains(w.Body.String(), "edit_days=") {
+		t.Fatalf("expected edit_days field error, got %d %q", w.Code, w.Body.String())
+	}
+	if !equalDays(storage.polls[poll.ID].Days, poll.Days) {
+		t.Fatalf("expected days unchanged, got %v", storage.polls[poll.ID].Days)
+	}
+}
+
+func TestHandlePollPostUpdateDates(t *testing.T) {
+	app, storage := newTestApp(t)
+	app.retention = 30 * 24 * time.Hour
+	poll := Poll{ID: "poll-1", Title: "Hang", Days: []string{"2024-01-01"}, CreatorToken: "creator"}
+	storage.polls[poll.ID] = poll
+	storage.responses[poll.ID] = []Response{{ID: "resp-1", Name: "Creator", Days: []string{"2024-01-01"}, UserToken: poll.CreatorToken}}
+	form := url.Values{}
+	form.Set("action", "update-dates")
+	form.Add("days", "2024-01-01")
+	form.Add("days", daysFromToday(1))
+	req := newFormRequest(http.MethodPost, "/poll/"+poll.ID+"/u/"+poll.CreatorToken, form)
+	w := httptest.NewRecorder()
+	app.handlePoll(w, req)
+	res := w.Result()
+	if res.StatusCode != http.StatusSeeOther {
+		t.Fatalf("expected redirect, got %d", res.StatusCode)
+	}
+	updated := storage.polls[poll.ID]
+	if !equalDays(updated.Days, []string{"2024-01-01", daysFromToday(1)}) {
+		t.Fatalf("expected updated days, got %v", updated.Days)
+	}
+	if want := pollExpiry(updated.Days, app.retention); want.IsZero() || !updated.ExpiresAt.Equal(want) {
+		t.Fatalf("expected the expiry moved to %v, got %v", want, updated.ExpiresAt)
+	}
+	responses := storage.responses[poll.ID]
+	if len(responses) != 1 {
+		t.Fatalf("expected creator response")
+	}
+	if !equalDays(responses[0].Days, []string{"2024-01-01", daysFromToday(1)}) {
+		t.Fatalf("expected creator auto-marked, got %v", responses[0].Days)
+	}
+}
+
+func TestHandlePollPostUpdateDatesRange(t *testing.T) {
+	app, storage := newTestApp(t)
+	poll := Poll{ID: "poll-1", Title: "Hang", Days: []string{daysFromToday(1)}, CreatorToken: "creator"}
+	storage.polls[poll.ID] = poll
+	form := url.Values{
+		"action":      {"update-dates"},
+		"days":        {daysFromToday(1)},
+		"range_start": {daysFromToday(5)},
+		"range_end":   {daysFromToday(7)},
+	}
+	w := httptest.NewRecorder()
+	app.handlePoll(w, newFormRequest(http.MethodPost, "/poll/poll-1/u/creator", form))
+	if w.Code != http.StatusSeeOther {
+		t.Fatalf("expected redirect, got %d %q", w.Code, w.Body.String())
+	}
+	if want := []string{daysFromToday(1), daysFromToday(5), daysFromToday(6), daysFromToday(7)}; !equalDays(storage.polls[poll.ID].Days, want) {
+		t.Fatalf("expected range merged into the checked days, got %v", storage.polls[poll.ID].Days)
+	}
+
+	form.Set("range_end", "")
+	w = httptest.NewRecorder()
+	app.handlePoll(w, newFormRequest(http.MethodPost, "/poll/poll-1/u/creator", form))
+	if w.Code != http.StatusUnprocessableEntity || !strings.Contains(w.Body.String(), "edit_range=") {
+		t.Fatalf("expected an incomplete range rejected, got %d %q", w.Code, w.Body.String())
+	}
+}
+
+func TestHandlePollPostUpdateDatesSlots(t *testing.T) {
+	app, storage := newTestApp(t)
+	poll := Poll{ID: "poll-1", Title: "Hang", Days: []string{"2024-01-01"}, CreatorToken: "creator"}
+	storage.polls[poll.ID] = poll
+	storage.responses[poll.ID] = []Response{{ID: "resp-1", Name: "Creator", Days: []string{"2024-01-01"}, UserToken: poll.CreatorToken}}
+	form := url.Values{
+		"action":     {"update-dates"},
+		"days":       {"2024-01-01"},
+		"slot_id":    {"", ""},
+		"slot_name":  {"Brunch", "Evening"},
+		"slot_start": {"", "18:00"},
+		"slot_end":   {"", "22:00"},
+	}
+	w := httptest.NewRecorder()
+	app.handlePoll(w, newFormRequest(http.MethodPost, "/poll/poll-1/u/creator", form))
+	if w.Code != http.StatusSeeOther {
+		t.Fatalf("expected redirect, got %d %q", w.Code, w.Body.String())
+	}
+	updated := storage.polls[poll.ID]
+	if len(updated.Slots) != 2 || updated.Slots[1].Label() != "Evening (18:00–22:00)" {
+		t.Fatalf("expected slots saved, got %+v", updated.Slots)
+	}
+	options := pollOptions(updated.Days, updated.Slots)
+	if !equalDays(storage.responses[poll.ID][0].Days, options) {
+		t.Fatalf("expected creator day expanded to slots, got %v", storage.responses[poll.ID][0].Days)
+	}
+
+	form = url.Values{"name": {"Jamie"}, "day_" + options[0]: {"if-need-be"}, "day_" + options[1]: {"available"}}
+	w = httptest.NewRecorder()
+	app.handlePoll(w, newFormRequest(http.MethodPost, "/poll/poll-1/u/jamie", form))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	saved := storage.responses[poll.ID][1]
+	if !equalDays(saved.Days, options[1:]) || !equalDays(saved.IfNeedBeDays, options[:1]) {
+		t.Fatalf("unexpected slot preferences %+v", saved)
+	}
+
+	form = url.Values{"action": {"update-dates"}, "days": {"2024-01-01"}}
+	w = httptest.NewRecorder()
+	app.handlePoll(w, newFormRequest(http.MethodPost, "/poll/poll-1/u/creator", form))
+	if w.Code != http.StatusSeeOther || len(storage.polls[poll.ID].Slots) != 2 {
+		t.Fatalf("expected slots kept without slot fields, got %d %+v", w.Code, storage.polls[poll.ID].Slots)
+	}
+
+	form = url.Values{"action": {"update-dates"}, "days": {"2024-01-01"}, "slot_name": {""}}
+	w = httptest.NewRecorder()
+	app.handlePoll(w, newFormRequest(http.MethodPost, "/poll/poll-1/u/creator", form))
+	if w.Code != http.StatusSeeOther || len(storage.polls[poll.ID].Slots) != 0 {
+		t.Fatalf("expected slots removed, got %d %+v", w.Code, storage.polls[poll.ID].Slots)
+	}
+	if !equalDays(storage.responses[poll.ID][1].Days, []string{"2024-01-01"}) {
+		t.Fatalf("expected slot answers collapsed to the day, got %+v", storage.responses[poll.ID][1])
+	}
+
+	form = url.Values{"action": {"update-dates"}, "days": {"2024-01-01"}, "slot_name": {"Late"}, "slot_start": {"22:00"}, "slot_end": {"18:00"}}
+	w = httptest.NewRecorder()
+	app.handlePoll(w, newFormRequest(http.MethodPost, "/poll/poll-1/u/creator", form))
+	if w.Code != http.StatusUnprocessableEntity || !strings.Contains(w.Body.String(), "edit_slots=") {
+		t.Fatalf("expected slot validation error, got %d %q", w.Code, w.Body.String())
+	}
+}
+
+func TestHandlePollPostUpdateVenuesFiltersVotes(t *testing.T) {
+	app, storage := newTestApp(t)
+	poll := Poll{
+		ID:           "poll-1",
+		Title:        "Hang",
+		Days:         []string{"2024-01-01"},
+		Venues:       []Venue{{ID: "park", Title: "Park"}, {ID: "movie", Title: "Movie"}},
+		CreatorToken: "creator",
+	}
+	storage.polls[poll.ID] = poll
+	storage.responses[poll.ID] = []Response{
+		{ID: "resp-1", Name: "Creator", Days: []string{"2024-01-01"}, VenueVotes: []string{"park"}, UserToken: poll.CreatorToken},
+		{ID: "resp-2", Name: "Sam", Days: []string{"2024-01-01"}, VenueVotes: []string{"park", "movie"}, UserToken: "user"},
+	}
+	form := url.Values{}
+	form.Set("action", "update-venues")
+	form.Add("venue_id", "movie")
+	form.Add("venue_title", "Movie")
+	form.Add("venue_url", "")
+	form.Add("venue_description", "")
+	req := newFormRequest(http.MethodPost, "/poll/"+poll.ID+"/u/"+poll.CreatorToken, form)
+	w := httptest.NewRecorder()
+	app.handlePoll(w, req)
+	if w.Result().StatusCode != http.StatusSeeOther {
+		t.Fatalf("expected redirect, got %d", w.Result().StatusCode)
+	}
+	updated := storage.polls[poll.ID]
+	if len(updated.Venues) != 1 || updated.Venues[0].ID != "movie" {
+		t.Fatalf("expected only movie venue, got %+v", updated.Venues)
+	}
+	for _, response := range storage.responses[poll.ID] {
+		if response.Name == "Creator" && len(response.VenueVotes) != 0 {
+			t.Fatalf("expected creator votes cleared, got %v", response.VenueVotes)
+		}
+		if response.Name == "Sam" && !equalDays(response.VenueVotes, []string{"movie"}) {
+			t.Fatalf("expected sam votes filtered, got %v", response.VenueVotes)
+		}
+	}
+}
+
+func TestHandlePollPostDeleteResponse(t *testing.T) {
+	app, storage := newTestApp(t)
+	poll := Poll{ID: "poll-1", Title: "Hang", Days: []string{"2024-01-01"}, CreatorToken: "creator"}
+	storage.polls[poll.ID] = poll
+	storage.responses[poll.ID] = []Response{{ID: "resp-1", Name: "Creator", Days: []string{"2024-01-01"}, UserToken: poll.CreatorToken}}
+	form := url.Values{}
+	form.Set("action", "delete-response")
+	form.Set("response_id", "resp-1")
+	req := newFormRequest(http.MethodPost, "/poll/"+poll.ID+"/u/"+poll.CreatorToken, form)
+	w := httptest.NewRecorder()
+	app.handlePoll(w, req)
+	if w.Result().StatusCode != http.StatusSeeOther {
+		t.Fatalf("expected redirect, got %d", w.Result().StatusCode)
+	}
+	if len(storage.responses[poll.ID]) != 0 {
+		t.Fatalf("expected response deleted")
+	}
+}
+
+func TestHandlePollPostDuplicatePoll(t *testing.T) {
+	app, storage := newTestApp(t)
+	original := Poll{
+		ID:           "poll-1",
+		Title:        "Hang",
+		Days:         []string{"2024-01-01", "2024-01-02"},
+		Venues:       []Venue{{ID: "park", Title: "Park", URL: "https://example.com/park", Description: "Picnic tables"}},
+		TripLength:   3,
+		CreatorToken: "creator",
+	}
+	storage.polls[original.ID] = original
+	storage.responses[original.ID] = []Response{
+		{ID: "resp-1", Name: "Creator", Days: original.Days, UserToken: original.CreatorToken},
+		{ID: "resp-2", Name: "Sam", Days: []string{"2024-01-02"}, UserToken: "user"},
+	}
+
+	form := url.Values{}
+	form.Set("action", "duplicate-poll")
+	req := newFormRequest(http.MethodPost, "/poll/"+original.ID+"/u/"+original.CreatorToken, form)
+	w := httptest.NewRecorder()
+
+	app.handlePoll(w, req)
+
+	res := w.Result()
+	if res.StatusCode != http.StatusSeeOther {
+		t.Fatalf("expected redirect, got %d", res.StatusCode)
+	}
+	location := res.Header.Get("Location")
+
+	if len(storage.polls) != 2 {
+		t.Fatalf("expected 2 polls after duplication, got %d", len(storage.polls))
+	}
+
+	var duplicated Poll
+	for _, poll := range storage.polls {
+		if poll.ID != original.ID {
+			duplicated = poll
+			break
+		}
+	}
+	if duplicated.ID == "" {
+		t.Fatalf("expected duplicated poll to be stored")
+	}
+	if duplicated.Title != original.Title {
+		t.Fatalf("expected title copied, got %q", duplicated.Title)
+	}
+	if len(duplicated.Days) != 0 {
+		t.Fatalf("expected duplicated poll to start with no dates, got %v", duplicated.Days)
+	}
+	if duplicated.CreatorToken == "" || duplicated.CreatorToken == original.CreatorToken {
+		t.Fatalf("expected a fresh creator token, got %q", duplicated.CreatorToken)
+	}
+	if len(duplicated.Venues) != 1 || duplicated.Venues[0] != original.Venues[0] {
+		t.Fatalf("expected venues copied, got %+v", duplicated.Venues)
+	}
+	if duplicated.TripLength != original.TripLength {
+		t.Fatalf("expected trip length copied, got %d", duplicated.TripLength)
+	}
+	if len(storage.responses[duplicated.ID]) != 0 {
+		t.Fatalf("expected duplicated poll to have no responses")
+	}
+	if duplicated.AdminToken == "" || duplicated.AdminToken == duplicated.CreatorToken {
+		t.Fatalf("expected a fresh admin token, got %q", duplicated.AdminToken)
+	}
+	if location != "/poll/"+duplicated.ID+"/manage/"+duplicated.AdminToken {
+		t.Fatalf("expected redirect to duplicated poll's management URL, got %s", location)
+	}
+}
+
+func TestHandlePollCreatorLinkCannotManage(t *testing.T) {
+	app, storage := newTestApp(t)
+	poll := Poll{ID: "poll-1", Title: "Hang", Days: []string{"2024-01-01"}, CreatorToken: "creator", AdminToken: "secret"}
+	storage.polls[poll.ID] = poll
+	storage.responses[poll.ID] = []Response{{ID: "resp-1", Name: "Sam", Days: poll.Days, UserToken: "user"}}
+
+	form := url.Values{}
+	form.Set("action", "delete-response")
+	form.Set("response_id", "resp-1")
+	w := httptest.NewRecorder()
+	app.handlePoll(w, newFormRequest(http.MethodPost, "/poll/poll-1/u/creator", form))
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("expected personal link to be forbidden, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	app.handlePoll(w, newFormRequest(http.MethodPost, "/poll/poll-1/manage/wrong", form))
+	if w.Code != http.StatusSeeOther || w.Header().Get("Location") != "/?invalid=1" {
+		t.Fatalf("expected wrong secret to be rejected, got %d %q", w.Code, w.Header().Get("Location"))
+	}
+	if len(storage.responses[poll.ID]) != 1 {
+		t.Fatalf("expected response kept")
+	}
+
+	w = httptest.NewRecorder()
+	app.handlePoll(w, newFormRequest(http.MethodPost, "/poll/poll-1/manage/secret", form))
+	if w.Code != http.StatusSeeOther || w.Header().Get("Location") != "/poll/poll-1/manage/secret" {
+		t.Fatalf("expected redirect back to management URL, got %d %q", w.Code, w.Header().Get("Location"))
+	}
+	if len(storage.responses[poll.ID]) != 0 {
+		t.Fatalf("expected response deleted via management URL")
+	}
+}
+
+func TestHandlePollManageActsAsCreator(t *testing.T) {
+	app, storage := newTestApp(t)
+	days := []string{daysFromToday(1), daysFromToday(2)}
+	poll := Poll{ID: "poll-1", Title: "Hang", Days: days, CreatorToken: "creator", AdminToken: "secret"}
+	storage.polls[poll.ID] = poll
+	storage.responses[poll.ID] = []Response{{ID: "resp-1", Name: "Creator", Days: days[:1], UserToken: "creator", Version: 1}}
+
+	w := httptest.NewRecorder()
+	app.handlePoll(w, httptest.NewRequest(http.MethodGet, "/poll/poll-1/manage/secret", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected management page, got %d", w.Code)
+	}
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Value != "creator" {
+		t.Fatalf("expected the creator's personal token in the cookie, got %+v", cookies)
+	}
+
+	form := url.Values{}
+	form.Set("name", "Creator")
+	form.Add("days", days[1])
+	w = httptest.NewRecorder()
+	app.handlePoll(w, newFormRequest(http.MethodPost, "/poll/poll-1/manage/secret", form))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected response saved, got %d", w.Code)
+	}
+	responses := storage.responses[poll.ID]
+	if len(responses) != 1 || !equalDays(responses[0].Days, days[1:]) {
+		t.Fatalf("expected creator response updated in place, got %+v", responses)
+	}
+}
+
+func TestHandlePollCoOrganizers(t *testing.T) {
+	app, storage := newTestApp(t)
+	poll := Poll{ID: "poll-1", Title: "Hang", Days: []string{daysFromToday(1), daysFromToday(2)}, CreatorToken: "creator", AdminToken: "secret"}
+	storage.polls[poll.ID] = poll
+	storage.responses[poll.ID] = []Response{
+		{ID: "resp-creator", Name: "Creator", Days: poll.Days, UserToken: "creator", Version: 1},
+		{ID: "resp-jo", Name: "Jo", Days: poll.Days, UserToken: "jo", Version: 1},
+		{ID: "resp-sam", Name: "Sam", Days: poll.Days, UserToken: "sam", Version: 1},
+	}
+	post := func(path string, values map[string]string) int {
+		form := url.Values{}
+		for key, value := range values {
+			form.Set(key, value)
+		}
+		w := httptest.NewRecorder()
+		app.handlePoll(w, newFormRequest(http.MethodPost, path, form))
+		return w.Code
+	}
+
+	if code := post("/poll/poll-1/u/jo", map[string]string{"action": "update-dates", "days": poll.Days[0]}); code != http.StatusForbidden {
+		t.Fatalf("expected responder to be forbidden, got %d", code)
+	}
+	if code := post("/poll/poll-1/u/jo", map[string]string{"action": "promote-organizer", "response_id": "resp-jo"}); code != http.StatusForbidden {
+		t.Fatalf("expected responder unable to promote themselves, got %d", code)
+	}
+	if code := post("/poll/poll-1/manage/secret", map[string]string{"action": "promote-organizer", "response_id": "resp-jo"}); code != http.StatusSeeOther {
+		t.Fatalf("expected promotion, got %d", code)
+	}
+	jo := findResponseByID(storage.responses[poll.ID], "resp-jo")
+	if jo == nil || jo.OrganizerToken == "" || jo.OrganizerToken == jo.UserToken {
+		t.Fatalf("expected jo to get their own organizer secret, got %+v", jo)
+	}
+	organizerPath := "/poll/poll-1/manage/" + jo.OrganizerToken
+	w := httptest.NewRecorder()
+	app.handlePoll(w, httptest.NewRequest(http.MethodGet, organizerPath, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected the organizer link to open the poll, got %d", w.Code)
+	}
+
+	if code := post("/poll/poll-1/u/jo", map[string]string{"action": "update-dates", "days": poll.Days[0]}); code != http.StatusForbidden {
+		t.Fatalf("expected the personal link to stay responder-only, got %d", code)
+	}
+	if code := post(organizerPath, map[string]string{"action": "update-dates", "days": poll.Days[0]}); code != http.StatusSeeOther {
+		t.Fatalf("expected co-organizer to edit dates, got %d", code)
+	}
+	if !equalDays(storage.polls[poll.ID].Days, poll.Days[:1]) {
+		t.Fatalf("expected dates updated, got %v", storage.polls[poll.ID].Days)
+	}
+	if code := post(organizerPath, map[string]string{"name": "Jo", "days": poll.Days[0]}); code != http.StatusOK {
+		t.Fatalf("expected co-organizer to save their availability, got %d", code)
+	}
+	if jo := findResponseByID(storage.responses[poll.ID], "resp-jo"); jo.Name != "Jo" || !equalDays(jo.Days, poll.Days[:1]) || jo.OrganizerToken == "" {
+		t.Fatalf("expected jo's own response updated and still organizer, got %+v", jo)
+	}
+	for _, action := range []string{"duplicate-poll", "promote-organizer", "revoke-organizer"} {
+		if code := post(organizerPath, map[string]string{"action": action, "response_id": "resp-sam"}); code != http.StatusForbidden {
+			t.Fatalf("expected co-organizer forbidden from %s, got %d", action, code)
+		}
+	}
+	if code := post(organizerPath, map[string]string{"action": "delete-response", "response_id": "resp-creator"}); code != http.StatusForbidden {
+		t.Fatalf("expected co-organizer unable to delete the creator, got %d", code)
+	}
+	if code := post(organizerPath, map[string]string{"action": "delete-response", "response_id": "resp-sam"}); code != http.StatusSeeOther {
+		t.Fatalf("expected co-organizer to delete responses, got %d", code)
+	}
+
+	if code := post("/poll/poll-1/manage/secret", map[string]string{"action": "revoke-organizer", "response_id": "resp-jo"}); code != http.StatusSeeOther {
+		t.Fatalf("expected revoke, got %d", code)
+	}
+	if len(organizerResponses(storage.responses[poll.ID])) != 0 {
+		t.Fatalf("expected no organizers, got %+v", storage.responses[poll.ID])
+	}
+	post(organizerPath, map[string]string{"action": "update-dates", "days": poll.Days[1]})
+	if !equalDays(storage.polls[poll.ID].Days, poll.Days[:1]) {
+		t.Fatalf("expected the revoked link to stop working, got %v", storage.polls[poll.ID].Days)
+	}
+	if code := post("/poll/poll-1/manage/secret", map[string]string{"action": "promote-organizer", "response_id": "resp-creator"}); code != http.StatusBadRequest {
+		t.Fatalf("expected creator promotion rejected, got %d", code)
+	}
+}
+
+func TestHandlePollAttendanceRules(t *testing.T) {
+	app, storage := newTestApp(t)
+	poll := Poll{ID: "poll-1", Title: "Hang", Days: []string{daysFromToday(1), daysFromToday(2)}, CreatorToken: "creator", AdminToken: "secret"}
+	storage.polls[poll.ID] = poll
+	storage.responses[poll.ID] = []Response{
+		{ID: "resp-creator", Name: "Creator", Days: poll.Days, UserToken: "creator", Version: 1},
+		{ID: "resp-jo", Name: "Jo", Days: poll.Days[1:], UserToken: "jo", OrganizerToken: "jo-manage", Version: 1},
+	}
+	post := func(path string, values map[string]string) *httptest.ResponseRecorder {
+		form := url.Values{}
+		for key, value := range values {
+			form.Set(key, value)
+		}
+		w := httptest.NewRecorder()
+		app.handlePoll(w, newFormRequest(http.MethodPost, path, form))
+		return w
+	}
+
+	for _, action := range []string{"require-attendee", "set-quorum"} {
+		if w := post("/poll/poll-1/manage/jo-manage", map[string]string{"action": action, "response_id": "resp-jo", "quorum": "2"}); w.Code != http.StatusForbidden {
+			t.Fatalf("expected co-organizer forbidden from %s, got %d", action, w.Code)
+		}
+	}
+	if w := post("/poll/poll-1/manage/secret", map[string]string{"action": "require-attendee", "response_id": "resp-jo"}); w.Code != http.StatusSeeOther {
+		t.Fatalf("expected require redirect, got %d", w.Code)
+	}
+	if !equalDays(storage.polls[poll.ID].RequiredAttendees, []string{"resp-jo"}) {
+		t.Fatalf("expected Jo required, got %v", storage.polls[poll.ID].RequiredAttendees)
+	}
+	if w := post("/poll/poll-1/manage/secret", map[string]string{"action": "require-attendee", "response_id": "resp-missing"}); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected unknown responder rejected, got %d", w.Code)
+	}
+	if w := post("/poll/poll-1/manage/secret", map[string]string{"action": "set-quorum", "quorum": "lots"}); w.Code != http.StatusUnprocessableEntity || !strings.Contains(w.Body.String(), "whole number") {
+		t.Fatalf("expected quorum validation error, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := post("/poll/poll-1/manage/secret", map[string]string{"action": "set-quorum", "quorum": "2"}); w.Code != http.StatusSeeOther {
+		t.Fatalf("expected quorum redirect, got %d", w.Code)
+	}
+	if storage.polls[poll.ID].Quorum != 2 {
+		t.Fatalf("expected quorum stored, got %d", storage.polls[poll.ID].Quorum)
+	}
+
+	view := app.buildPollView(httptest.NewRequest(http.MethodGet, "/poll/poll-1/u/jo", nil), storage.polls[poll.ID], storage.responses[poll.ID], "", "jo", "")
+	if len(view.Recommendations) != 2 || view.Recommendations[0].Key != poll.Days[1] || view.SuggestedDay != poll.Days[1] {
+		t.Fatalf("expected the day Jo can make recommended first, got %+v", view.Recommendations)
+	}
+	if !view.RequiredAttendees["resp-jo"] || view.Quorum != 2 {
+		t.Fatalf("expected attendance rules in view, got %v %d", view.RequiredAttendees, view.Quorum)
+	}
+
+	if w := post("/poll/poll-1/manage/secret", map[string]string{"action": "unrequire-attendee", "response_id": "resp-jo"}); w.Code != http.StatusSeeOther {
+		t.Fatalf("expected unrequire redirect, got %d", w.Code)
+	}
+	if w := post("/poll/poll-1/manage/secret", map[string]string{"action": "set-quorum", "quorum": ""}); w.Code != http.StatusSeeOther {
+		t.Fatalf("expected quorum cleared, got %d", w.Code)
+	}
+	if loaded := storage.polls[poll.ID]; len(loaded.RequiredAttendees) != 0 || loaded.Quorum != 0 {
+		t.Fatalf("expected attendance rules cleared, got %+v", loaded)
+	}
+}
+
+func TestHandlePollClosing(t *testing.T) {
+	app, storage := newTestApp(t)
+	poll := Poll{ID: "poll-1", Title: "Hang", Days: []string{"2024-01-01", "2024-01-02"}, CreatorToken: "creator", AdminToken: "secret"}
+	storage.polls[poll.ID] = poll
+	storage.responses[poll.ID] = []Response{
+		{ID: "resp-creator", Name: "Creator", Days: poll.Days, UserToken: "creator", Version: 1},
+		{ID: "resp-jo", Name: "Jo", Days: poll.Days, UserToken: "jo", OrganizerToken: "jo-manage", Version: 1},
+		{ID: "resp-sam", Name: "Sam", Days: poll.Days, UserToken: "sam", Version: 1},
+	}
+	post := func(path string, values map[string]string) *httptest.ResponseRecorder {
+		form := url.Values{}
+		for key, value := range values {
+			form.Set(key, value)
+		}
+		w := httptest.NewRecorder()
+		app.handlePoll(w, newFormRequest(http.MethodPost, path, form))
+		return w
+	}
+
+	if w := post("/poll/poll-1/manage/jo-manage", map[string]string{"action": "close-poll"}); w.Code != http.StatusForbidden {
+		t.Fatalf("expected co-organizer unable to close, got %d", w.Code)
+	}
+	if w := post("/poll/poll-1/manage/secret", map[string]string{"action": "close-poll"}); w.Code != http.StatusSeeOther {
+		t.Fatalf("expected close redirect, got %d", w.Code)
+	}
+	if storage.polls[poll.ID].ClosedAt.IsZero() {
+		t.Fatalf("expected poll closed")
+	}
+
+	if w := post("/poll/poll-1/u/sam", map[string]string{"name": "Sam", "days": "2024-01-01"}); w.Code != http.StatusForbidden {
+		t.Fatalf("expected closed poll to reject responses, got %d", w.Code)
+	}
+	if w := post("/poll/poll-1/manage/jo-manage", map[string]string{"action": "update-dates", "days": "2024-01-01"}); w.Code != http.StatusForbidden {
+		t.Fatalf("expected co-organizer locked out of a closed poll, got %d", w.Code)
+	}
+	if !equalDays(storage.responses[poll.ID][2].Days, poll.Days) || !equalDays(storage.polls[poll.ID].Days, poll.Days) {
+		t.Fatalf("expected closed poll untouched, got %+v", storage.responses[poll.ID])
+	}
+	if w := post("/poll/poll-1/manage/secret", map[string]string{"name": "Creator", "days": "2024-01-02"}); w.Code != http.StatusOK {
+		t.Fatalf("expected creator to keep editing a closed poll, got %d", w.Code)
+	}
+
+	if w := post("/poll/poll-1/manage/secret", map[string]string{"action": "reopen-poll"}); w.Code != http.StatusSeeOther {
+		t.Fatalf("expected reopen redirect, got %d", w.Code)
+	}
+	if w := post("/poll/poll-1/u/sam", map[string]string{"name": "Sam", "days": "2024-01-01"}); w.Code != http.StatusOK {
+		t.Fatalf("expected reopened poll to accept responses, got %d", w.Code)
+	}
+	if !equalDays(storage.responses[poll.ID][2].Days, []string{"2024-01-01"}) {
+		t.Fatalf("expected response saved after reopening, got %+v", storage.responses[poll.ID][2])
+	}
+}
+
+func TestHandlePollDeadline(t *testing.T) {
+	app, storage := newTestApp(t)
+	poll := Poll{ID: "poll-1", Title: "Hang", Days: []string{"2024-01-01"}, CreatorToken: "creator", AdminToken: "secret"}
+	storage.polls[poll.ID] = poll
+	post := func(path string, values map[string]string) *httptest.ResponseRecorder {
+		form := url.Values{}
+		for key, value := range values {
+			form.Set(key, value)
+		}
+		w := httptest.NewRecorder()
+		app.handlePoll(w, newFormRequest(http.MethodPost, path, form))
+		return w
+	}
+
+	for _, value := range []string{"tomorrow", time.Now().UTC().AddDate(2, 0, 0).Format("2006-01-02T15:04")} {
+		w := post("/poll/poll-1/manage/secret", map[string]string{"action": "set-deadline", "deadline": value})
+		if w.Code != http.StatusUnprocessableEntity || !strings.Contains(w.Body.String(), "deadline=") {
+			t.Fatalf("expected deadline %q rejected, got %d %q", value, w.Code, w.Body.String())
+		}
+	}
+
+	future := time.Now().UTC().Add(24 * time.Hour).Truncate(time.Minute)
+	if w := post("/poll/poll-1/manage/secret", map[string]string{"action": "set-deadline", "deadline": future.Format("2006-01-02T15:04")}); w.Code != http.StatusSeeOther {
+		t.Fatalf("expected deadline saved, got %d", w.Code)
+	}
+	if !storage.polls[poll.ID].Deadline.Equal(future) {
+		t.Fatalf("expected deadline %v, got %v", future, storage.polls[poll.ID].Deadline)
+	}
+	if w := post("/poll/poll-1/u/sam", map[string]string{"name": "Sam", "days": "2024-01-01"}); w.Code != http.StatusOK {
+		t.Fatalf("expected responses before the deadline, got %d", w.Code)
+	}
+
+	passed := storage.polls[poll.ID]
+	passed.Deadline = time.Now().UTC().Add(-time.Minute)
+	storage.polls[poll.ID] = passed
+	if w := post("/poll/poll-1/u/kim", map[string]string{"name": "Kim", "days": "2024-01-01"}); w.Code != http.StatusForbidden {
+		t.Fatalf("expected passed deadline to reject responses, got %d", w.Code)
+	}
+	if len(storage.responses[poll.ID]) != 1 {
+		t.Fatalf("expected no new response, got %+v", storage.responses[poll.ID])
+	}
+
+	if w := post("/poll/poll-1/manage/secret", map[string]string{"action": "reopen-poll"}); w.Code != http.StatusSeeOther {
+		t.Fatalf("expected reopen redirect, got %d", w.Code)
+	}
+	if !storage.polls[poll.ID].Deadline.IsZero() {
+		t.Fatalf("expected passed deadline cleared on reopen, got %v", storage.polls[poll.ID].Deadline)
+	}
+
+	if w := post("/poll/poll-1/manage/secret", map[string]string{"action": "set-deadline", "deadline": future.Format("2006-01-02T15:04")}); w.Code != http.StatusSeeOther {
+		t.Fatalf("expected deadline saved, got %d", w.Code)
+	}
+	if w := post("/poll/poll-1/manage/secret", map[string]string{"action": "set-deadline", "deadline": ""}); w.Code != http.StatusSeeOther {
+		t.Fatalf("expected deadline cleared, got %d", w.Code)
+	}
+	if !storage.polls[poll.ID].Deadline.IsZero() || pollClosed(storage.polls[poll.ID], time.Now()) {
+		t.Fatalf("expected open poll without deadline, got %+v", storage.polls[poll.ID])
+	}
+}
+
+func TestHandlePollTimeZone(t *testing.T) {
+	app, storage := newTestApp(t)
+	poll := Poll{ID: "poll-1", Title: "Hang", Days: []string{"2024-01-01"}, CreatorToken: "creator", AdminToken: "secret"}
+	storage.polls[poll.ID] = poll
+	post := func(path string, timeZone string) *httptest.ResponseRecorder {
+		form := url.Values{"action": {"update-time-zone"}, "time_zone": {timeZone}}
+		w := httptest.NewRecorder()
+		app.handlePoll(w, newFormRequest(http.MethodPost, path, form))
+		return w
+	}
+
+	if w := post("/poll/poll-1/u/sam", "America/Los_Angeles"); w.Code != http.StatusForbidden {
+		t.Fatalf("expected responders forbidden, got %d", w.Code)
+	}
+	if w := post("/poll/poll-1/manage/secret", "Mars/Olympus_Mons"); w.Code != http.StatusUnprocessableEntity || !strings.Contains(w.Body.String(), "time_zone=") {
+		t.Fatalf("expected unknown time zone rejected, got %d %q", w.Code, w.Body.String())
+	}
+	if w := post("/poll/poll-1/manage/secret", "America/Los_Angeles"); w.Code != http.StatusSeeOther {
+		t.Fatalf("expected time zone saved, got %d", w.Code)
+	}
+	updated := storage.polls[poll.ID]
+	if updated.TimeZone != "America/Los_Angeles" {
+		t.Fatalf("expected time zone stored, got %q", updated.TimeZone)
+	}
+
+	deadline := time.Now().In(pollLocation(updated)).Add(48 * time.Hour).Truncate(time.Minute)
+	form := url.Values{"action": {"set-deadline"}, "deadline": {deadline.Format("2006-01-02T15:04")}}
+	w := httptest.NewRecorder()
+	app.handlePoll(w, newFormRequest(http.MethodPost, "/poll/poll-1/manage/secret", form))
+	if w.Code != http.StatusSeeOther || !storage.polls[poll.ID].Deadline.Equal(deadline) {
+		t.Fatalf("expected deadline read in the poll's time zone, got %d %v", w.Code, storage.polls[poll.ID].Deadline)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/poll/poll-1/manage/secret", nil)
+	view := app.buildPollView(req, storage.polls[poll.ID], nil, "", "", "secret")
+	if view.TimeZone != "America/Los_Angeles" || view.DeadlineInput != deadline.Format("2006-01-02T15:04") {
+		t.Fatalf("expected view in the poll's time zone, got %q %q", view.TimeZone, view.DeadlineInput)
+	}
+	// The poll's own past days stay on the calendar; new ones can only start today.
+	var addable []string
+	for _, day := range calendarEnabledDays(view.EditMonths) {
+		if !view.PollDaySet[day] {
+			addable = append(addable, day)
+		}
+	}
+	if today := time.Now().In(pollLocation(updated)).Format("2006-01-02"); addable[0] != today {
+		t.Fatalf("expected edit days to start on %s, got %v", today, addable[:1])
+	}
+
+	if w := post("/poll/poll-1/manage/secret", ""); w.Code != http.StatusSeeOther || storage.polls[poll.ID].TimeZone != "" {
+		t.Fatalf("expected time zone reset to UTC, got %d %q", w.Code, storage.polls[poll.ID].TimeZone)
+	}
+}
+
+func TestHandleCreatePollTimeZone(t *testing.T) {
+	app, storage := newTestApp(t)
+	for timeZone, want := range map[string]string{"Pacific/Kiritimati": "Pacific/Kiritimati", "Mars/Olympus_Mons": ""} {
+		today := time.Now().UTC()
+		if want != "" {
+			today = time.Now().In(mustLoadLocation(t, timeZone))
+		}
+		form := url.Values{"title": {"Dinner"}, "creator": {"Sam"}, "days": {today.Format("2006-01-02")}, "time_zone": {timeZone}}
+		w := httptest.NewRecorder()
+		app.handleCreatePoll(w, newFormRequest(http.MethodPost, "/polls", form))
+		if w.Code != http.StatusSeeOther {
+			t.Fatalf("%s: expected redirect, got %d %q", timeZone, w.Code, w.Body.String())
+		}
+		pollID, _ := parsePollPath(strings.Replace(w.Header().Get("Location"), "/manage/", "/u/", 1))
+		if got := storage.polls[pollID].TimeZone; got != want {
+			t.Fatalf("%s: expected stored time zone %q, got %q", timeZone, want, got)
+		}
+	}
+}
+
+func TestTimeZoneCookie(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(&http.Cookie{Name: timeZoneCookieName, Value: "America/Los_Angeles"})
+	if got := timeZoneCookie(req); got != "America/Los_Angeles" {
+		t.Fatalf("expected cookie time zone, got %q", got)
+	}
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(&http.Cookie{Name: timeZoneCookieName, Value: "Local"})
+	if got := timeZoneCookie(req); got != "" {
+		t.Fatalf("expected invalid cookie ignored, got %q", got)
+	}
+}
+
+func TestHandlePollFinalize(t *testing.T) {
 	app, storage := newTestApp(t)
-	poll := Poll{ID: "poll-1", Title: "Hang", Days: []string{"2024-01-01"}, CreatorToken: "creator"}
+	poll := Poll{
+		ID:           "poll-1",
+		Title:        "Hang",
+		Days:         []string{"2024-01-01", "2024-01-02"},
+		Venues:       []Venue{{ID: "movie", Title: "Movie"}, {ID: "arcade", Title: "Arcade"}},
+		CreatorToken: "creator",
+		AdminToken:   "secret",
+	}
 	storage.polls[poll.ID] = poll
-	req := httptest.NewRequest(http.MethodGet, "/poll/"+poll.ID, nil)
-	w := httptest.NewRecorder()
-	app.handlePoll(w, req)
-	res := w.Result()
-	if res.StatusCode != http.StatusSeeOther {
-		t.Fatalf("expected redirect, got %d", res.StatusCode)
+	storage.responses[poll.ID] = []Response{
+		{ID: "resp-creator", Name: "Creator", Days: poll.Days, UserToken: "creator", Version: 1},
+		{ID: "resp-jo", Name: "Jo", Days: []string{"2024-01-02"}, UserToken: "jo", OrganizerToken: "jo-manage", Version: 1},
 	}
-	if !strings.Contains(res.Header.Get("Location"), "/poll/"+poll.ID+"/u/") {
-		t.Fatalf("unexpected location: %s", res.Header.Get("Location"))
+	post := func(path string, values map[string]string) *httptest.ResponseRecorder {
+		form := url.Values{}
+		for key, value := range values {
+			form.Set(key, value)
+		}
+		w := httptest.NewRecorder()
+		app.handlePoll(w, newFormRequest(http.MethodPost, path, form))
+		return w
+	}
+
+	if w := post("/poll/poll-1/u/jo", map[string]string{"action": "finalize-poll", "final_day": "2024-01-02"}); w.Code != http.StatusForbidden {
+		t.Fatalf("expected the personal link unable to finalize, got %d", w.Code)
+	}
+	if w := post("/poll/poll-1/manage/jo-manage", map[string]string{"action": "finalize-poll", "final_day": "2024-01-02"}); w.Code != http.StatusSeeOther {
+		t.Fatalf("expected co-organizer to finalize, got %d", w.Code)
+	}
+	if got := storage.polls[poll.ID]; got.FinalDay != "2024-01-02" {
+		t.Fatalf("expected the co-organizer's choice stored, got %+v", got)
+	}
+	if w := post("/poll/poll-1/manage/jo-manage", map[string]string{"name": "Jo", "days": "2024-01-01"}); w.Code != http.StatusForbidden {
+		t.Fatalf("expected the finalized poll to lock the co-organizer's response, got %d", w.Code)
+	}
+	if w := post("/poll/poll-1/manage/jo-manage", map[string]string{"action": "unfinalize-poll"}); w.Code != http.StatusSeeOther || storage.polls[poll.ID].FinalDay != "" {
+		t.Fatalf("expected co-organizer to un-finalize, got %d %+v", w.Code, storage.polls[poll.ID])
+	}
+	w := post("/poll/poll-1/manage/secret", map[string]string{"action": "finalize-poll", "final_day": "2024-01-03", "final_venue": "bowling"})
+	if w.Code != http.StatusUnprocessableEntity || !strings.Contains(w.Body.String(), "final_day=") || !strings.Contains(w.Body.String(), "final_venue=") {
+		t.Fatalf("expected unknown day and venue rejected, got %d %q", w.Code, w.Body.String())
+	}
+	if w := post("/poll/poll-1/manage/secret", map[string]string{"action": "finalize-poll", "final_day": "2024-01-02", "final_venue": "arcade"}); w.Code != http.StatusSeeOther {
+		t.Fatalf("expected finalize redirect, got %d", w.Code)
+	}
+	if got := storage.polls[poll.ID]; got.FinalDay != "2024-01-02" || got.FinalVenueID != "arcade" {
+		t.Fatalf("expected final choice stored, got %+v", got)
+	}
+
+	if w := post("/poll/poll-1/u/jo", map[string]string{"name": "Jo", "days": "2024-01-01"}); w.Code != http.StatusForbidden {
+		t.Fatalf("expected finalized poll to reject responses, got %d", w.Code)
+	}
+	w = post("/poll/poll-1/manage/secret", map[string]string{"action": "update-dates", "days": "2024-01-01"})
+	if w.Code != http.StatusUnprocessableEntity || !strings.Contains(w.Body.String(), "edit_days=") {
+		t.Fatalf("expected removing the final day rejected, got %d %q", w.Code, w.Body.String())
+	}
+	form := url.Values{"action": {"update-venues"}, "venue_id": {"movie"}, "venue_title": {"Movie"}, "venue_url": {""}, "venue_description": {""}}
+	w = httptest.NewRecorder()
+	app.handlePoll(w, newFormRequest(http.MethodPost, "/poll/poll-1/manage/secret", form))
+	if w.Code != http.StatusUnprocessableEntity || !strings.Contains(w.Body.String(), "venues=") {
+		t.Fatalf("expected removing the final venue rejected, got %d %q", w.Code, w.Body.String())
+	}
+
+	if w := post("/poll/poll-1/manage/secret", map[string]string{"action": "unfinalize-poll"}); w.Code != http.StatusSeeOther {
+		t.Fatalf("expected unfinalize redirect, got %d", w.Code)
+	}
+	if got := storage.polls[poll.ID]; got.FinalDay != "" || got.FinalVenueID != "" || pollClosed(got, time.Now()) {
+		t.Fatalf("expected poll open again, got %+v", got)
+	}
+	if w := post("/poll/poll-1/u/jo", map[string]string{"name": "Jo", "days": "2024-01-01"}); w.Code != http.StatusOK {
+		t.Fatalf("expected responses after unfinalizing, got %d", w.Code)
 	}
 }
 
-func TestHandlePollGetNotFound(t *testing.T) {
+func TestBuildPollViewFinal(t *testing.T) {
 	app, _ := newTestApp(t)
-	req := httptest.NewRequest(http.MethodGet, "/poll/missing/u/token", nil)
+	poll := Poll{
+		ID:           "poll-1",
+		Days:         []string{"2024-01-01", "2024-01-02", "2024-01-03"},
+		Venues:       []Venue{{ID: "movie", Title: "Movie"}, {ID: "arcade", Title: "Arcade"}},
+		CreatorToken: "creator",
+		AdminToken:   "secret",
+	}
+	responses := []Response{
+		{ID: "1", Name: "Ana", Days: []string{"2024-01-02", "2024-01-03"}, VenueVotes: []string{"arcade"}, UserToken: "creator"},
+		{ID: "2", Name: "Ben", Days: []string{"2024-01-01", "2024-01-03"}, VenueVotes: []string{"arcade", "movie"}},
+		{ID: "3", Name: "Cy", Days: []string{"2024-01-03"}, IfNeedBeDays: []string{"2024-01-02"}},
+	}
+	req := httptest.NewRequest(http.MethodGet, "/poll/poll-1/manage/secret", nil)
+
+	view := app.buildPollView(req, poll, responses, "", "", "secret")
+	if view.Final != nil || view.SuggestedDay != "2024-01-03" || view.SuggestedVenueID != "arcade" {
+		t.Fatalf("expected everyone's day and top venue suggested, got %+v %q %q", view.Final, view.SuggestedDay, view.SuggestedVenueID)
+	}
+
+	poll.FinalDay = "2024-01-02"
+	poll.FinalVenueID = "movie"
+	view = app.buildPollView(req, poll, responses, "", "", "secret")
+	if view.Final == nil || view.Final.Venue == nil || view.Final.Venue.Title != "Movie" || view.SuggestedDay != "2024-01-02" || view.SuggestedVenueID != "movie" {
+		t.Fatalf("expected final choice in view, got %+v", view.Final)
+	}
+	if !equalDays(view.Final.Names, []string{"Ana"}) || !equalDays(view.Final.IfNeedBeNames, []string{"Cy"}) || !equalDays(view.Final.Missing, []string{"Ben"}) {
+		t.Fatalf("unexpected final attendance %v if need be %v missing %v", view.Final.Names, view.Final.IfNeedBeNames, view.Final.Missing)
+	}
+	if !view.Closed || !view.CanManage {
+		t.Fatalf("expected finalized poll closed but manageable by the creator")
+	}
+}
+
+func TestSuggestedDay(t *testing.T) {
+	summaries := []DaySummary{
+		{Date: "2024-01-01", Key: "2024-01-01", Score: 3},
+		{Date: "2024-01-02", Key: "2024-01-02", Score: 4},
+		{Date: "2024-01-03", Key: "2024-01-03", Score: 4},
+		{Date: "2024-01-04", Key: "2024-01-04", Score: 2},
+		{Date: "2024-01-05", Key: "2024-01-05", Score: 2},
+	}
+	if got := suggestedDay(summaries); got != "2024-01-02" {
+		t.Fatalf("expected earliest day with the best score, got %q", got)
+	}
+	summaries[3].AllIfNeedBe = true
+	if got := suggestedDay(summaries); got != "2024-01-04" {
+		t.Fatalf("expected day everyone can stretch to, got %q", got)
+	}
+	summaries[4].AllAvailable = true
+	if got := suggestedDay(summaries); got != "2024-01-05" {
+		t.Fatalf("expected day that works for everyone, got %q", got)
+	}
+	summaries[4].Elapsed = true
+	if got := suggestedDay(summaries); got != "2024-01-04" {
+		t.Fatalf("expected elapsed days passed over, got %q", got)
+	}
+	if got := suggestedDay(nil); got != "" {
+		t.Fatalf("expected no suggestion without days, got %q", got)
+	}
+	if got := suggestedVenueID([]VenueSummary{{Venue: Venue{ID: "movie"}}}); got != "" {
+		t.Fatalf("expected no venue suggestion without votes, got %q", got)
+	}
+}
+
+func TestRecommendDays(t *testing.T) {
+	days := []string{"2024-01-01", "2024-01-02", "2024-01-03", "2024-01-04"}
+	responses := []Response{
+		{ID: "resp-jim", Name: "Jim", Days: []string{"2024-01-01", "2024-01-03"}},
+		{ID: "resp-judy", Name: "Judy", Days: []string{"2024-01-01", "2024-01-02"}, IfNeedBeDays: []string{"2024-01-04"}},
+		{ID: "resp-sam", Name: "Sam", Days: []string{"2024-01-02", "2024-01-03", "2024-01-04"}},
+	}
+	keys := func(recommendations []DayRecommendation) []string {
+		var out []string
+		for _, recommendation := range recommendations {
+			out = append(out, recommendation.Key)
+		}
+		return out
+	}
+	summaries := summarizeAvailability(days, nil, responses, "")
+
+	got := recommendDays(Poll{}, summaries, responses)
+	if want := []string{"2024-01-01", "2024-01-02", "2024-01-03", "2024-01-04"}; !equalDays(keys(got), want) {
+		t.Fatalf("expected ties broken by firm answers then date, got %v", keys(got))
+	}
+	if got[3].Explanation != "2 of 3 (1 if need be); missing Jim" {
+		t.Fatalf("unexpected explanation %q", got[3].Explanation)
+	}
+
+	got = recommendDays(Poll{RequiredAttendees: []string{"resp-jim"}}, summaries, responses)
+	if want := []string{"2024-01-01", "2024-01-03", "2024-01-02", "2024-01-04"}; !equalDays(keys(got), want) {
+		t.Fatalf("expected days missing Jim ranked last, got %v", keys(got))
+	}
+	if got[2].Explanation != "2 of 3; missing Jim (must attend)" || !equalDays(got[2].MissingRequired, []string{"Jim"}) {
+		t.Fatalf("unexpected recommendation %+v", got[2])
+	}
+
+	got = recommendDays(Poll{Quorum: 3}, summaries, responses)
+	if got[0].MeetsQuorum || got[0].Explanation != "2 of 3; missing Sam; short of the minimum of 3" {
+		t.Fatalf("unexpected quorum explanation %+v", got[0])
+	}
+
+	responses = append(responses, Response{ID: "resp-kim", Name: "Kim", Days: []string{"2024-01-04"}})
+	summaries = summarizeAvailability(days, nil, responses, "2024-01-03")
+	got = recommendDays(Poll{Quorum: 3}, summaries, responses)
+	if want := []string{"2024-01-04", "2024-01-03"}; !equalDays(keys(got), want) {
+		t.Fatalf("expected elapsed days skipped and quorum first, got %v", keys(got))
+	}
+	if got[0].Explanation != "3 of 4 (1 if need be); missing Jim" {
+		t.Fatalf("unexpected explanation %q", got[0].Explanation)
+	}
+	if got := recommendDays(Poll{}, summarizeAvailability(days, nil, nil, ""), nil); got != nil {
+		t.Fatalf("expected no recommendations without responses, got %v", got)
+	}
+}
+
+func TestSummarizeWindows(t *testing.T) {
+	days := []string{"2024-01-05", "2024-01-06", "2024-01-07", "2024-01-09", "2024-01-10"}
+	responses := []Response{
+		{Name: "Jim", Days: []string{"2024-01-05", "2024-01-06", "2024-01-07"}},
+		{Name: "Judy", Days: []string{"2024-01-06", "2024-01-09", "2024-01-10"}, IfNeedBeDays: []string{"2024-01-07"}},
+		{Name: "Sam", Days: []string{"2024-01-06", "2024-01-07", "2024-01-09", "2024-01-10"}},
+	}
+	windows := summarizeWindows(summarizeAvailability(days, nil, responses, ""), responses, 2)
+	var starts []string
+	for _, window := range windows {
+		starts = append(starts, window.Start)
+	}
+	if want := []string{"2024-01-06", "2024-01-09", "2024-01-05"}; !equalDays(starts, want) {
+		t.Fatalf("expected only consecutive runs ranked by people free, got %v", starts)
+	}
+	top := windows[0]
+	if top.End != "2024-01-07" || top.Label != "Sat, Jan 6 – Sun, Jan 7" || !top.AllFree {
+		t.Fatalf("unexpected top window %+v", top)
+	}
+	if !equalDays(top.Names, []string{"Jim", "Sam"}) || !equalDays(top.IfNeedBeNames, []string{"Judy"}) {
+		t.Fatalf("expected Judy free only if need be, got %+v", top)
+	}
+	if windows[1].Free != 2 || !equalDays(windows[1].Missing, []string{"Jim"}) {
+		t.Fatalf("unexpected second window %+v", windows[1])
+	}
+
+	windows = summarizeWindows(summarizeAvailability(days, nil, responses, ""), responses, 3)
+	if len(windows) != 1 || windows[0].Start != "2024-01-05" || windows[0].Free != 1 {
+		t.Fatalf("expected the single three-day run, got %+v", windows)
+	}
+	if windows = summarizeWindows(summarizeAvailability(days, nil, responses, "2024-01-08"), responses, 2); len(windows) != 1 || windows[0].Start != "2024-01-09" {
+		t.Fatalf("expected windows starting on elapsed days skipped, got %+v", windows)
+	}
+
+	slots := []TimeSlot{{ID: "day", Name: "Day"}, {ID: "night", Name: "Night"}}
+	slotted := []Response{
+		{Name: "Jim", Days: []string{"2024-01-05@day", "2024-01-05@night", "2024-01-06@day", "2024-01-06@night"}},
+		{Name: "Sam", Days: []string{"2024-01-05@day", "2024-01-05@night", "2024-01-06@day"}},
+	}
+	windows = summarizeWindows(summarizeAvailability(days[:2], slots, slotted, ""), slotted, 2)
+	if len(windows) != 1 || !equalDays(windows[0].Names, []string{"Jim"}) || !equalDays(windows[0].Missing, []string{"Sam"}) {
+		t.Fatalf("expected every slot required, got %+v", windows)
+	}
+	if got := summarizeWindows(summarizeAvailability(days, nil, responses, ""), responses, 0); got != nil {
+		t.Fatalf("expected no windows without a trip length, got %v", got)
+	}
+}
+
+func TestHandlePollTripLength(t *testing.T) {
+	app, storage := newTestApp(t)
+	poll := Poll{ID: "poll-1", Title: "Trip", Days: []string{daysFromToday(1), daysFromToday(2), daysFromToday(3)}, CreatorToken: "creator", AdminToken: "secret"}
+	storage.polls[poll.ID] = poll
+	storage.responses[poll.ID] = []Response{
+		{ID: "resp-creator", Name: "Creator", Days: poll.Days, UserToken: "creator", Version: 1},
+		{ID: "resp-jo", Name: "Jo", Days: poll.Days[1:], UserToken: "jo", OrganizerToken: "jo-manage", Version: 1},
+	}
+	post := func(path, tripLength string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		app.handlePoll(w, newFormRequest(http.MethodPost, path, url.Values{"action": {"set-trip-length"}, "trip_length": {tripLength}}))
+		return w
+	}
+
+	if w := post("/poll/poll-1/manage/jo-manage", "2"); w.Code != http.StatusForbidden {
+		t.Fatalf("expected co-organizer forbidden, got %d", w.Code)
+	}
+	for _, value := range []string{"1", "15", "two"} {
+		if w := post("/poll/poll-1/manage/secret", value); w.Code != http.StatusUnprocessableEntity || !strings.Contains(w.Body.String(), "from 2 to 14") {
+			t.Fatalf("expected %q rejected, got %d", value, w.Code)
+		}
+	}
+	if w := post("/poll/poll-1/manage/secret", "2"); w.Code != http.StatusSeeOther {
+		t.Fatalf("expected redirect, got %d", w.Code)
+	}
+	if storage.polls[poll.ID].TripLength != 2 {
+		t.Fatalf("expected trip length stored, got %d", storage.polls[poll.ID].TripLength)
+	}
+
+	view := app.buildPollView(httptest.NewRequest(http.MethodGet, "/poll/poll-1/u/jo", nil), storage.polls[poll.ID], storage.responses[poll.ID], "", "jo", "")
+	if len(view.Windows) != 2 || view.Windows[0].Start != poll.Days[1] || !view.Windows[0].AllFree {
+		t.Fatalf("expected the window everyone can make first, got %+v", view.Windows)
+	}
+
+	if w := post("/poll/poll-1/manage/secret", ""); w.Code != http.StatusSeeOther {
+		t.Fatalf("expected trip length cleared, got %d", w.Code)
+	}
+	if storage.polls[poll.ID].TripLength != 0 {
+		t.Fatalf("expected single days again, got %d", storage.polls[poll.ID].TripLength)
+	}
+}
+
+func TestHandlePollArchivesEndedPoll(t *testing.T) {
+	app, storage := newTestApp(t)
+	poll := Poll{ID: "poll-1", Title: "Hang", Days: []string{daysFromToday(-2), daysFromToday(-1)}, CreatorToken: "creator", AdminToken: "secret"}
+	storage.polls[poll.ID] = poll
+	storage.responses[poll.ID] = []Response{{ID: "resp-1", Name: "Creator", Days: poll.Days, UserToken: "creator", Version: 1}}
+
 	w := httptest.NewRecorder()
-	app.handlePoll(w, req)
-	if w.Result().StatusCode != http.StatusSeeOther {
-		t.Fatalf("expected redirect, got %d", w.Result().StatusCode)
+	app.handlePoll(w, httptest.NewRequest(http.MethodGet, "/poll/poll-1/u/sam", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected poll page, got %d", w.Code)
 	}
-	if w.Result().Header.Get("Location") != "/?invalid=1" {
-		t.Fatalf("expected invalid redirect")
+	archived := storage.polls[poll.ID]
+	if archived.ArchivedAt.IsZero() || archived.Version != poll.Version+1 {
+		t.Fatalf("expected ended poll archived, got %+v", archived)
+	}
+
+	post := func(path string, form url.Values) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		app.handlePoll(w, newFormRequest(http.MethodPost, path, form))
+		return w
+	}
+	if w := post("/poll/poll-1/manage/secret", url.Values{"name": {"Creator"}, "days": {poll.Days[0]}}); w.Code != http.StatusForbidden {
+		t.Fatalf("expected archived poll locked for the creator too, got %d", w.Code)
+	}
+	if w := post("/poll/poll-1/manage/secret", url.Values{"action": {"reopen-poll"}}); w.Code != http.StatusForbidden {
+		t.Fatalf("expected archived poll to stay archived, got %d", w.Code)
+	}
+	if w := post("/poll/poll-1/manage/secret", url.Values{"action": {"duplicate-poll"}}); w.Code != http.StatusSeeOther || len(storage.polls) != 2 {
+		t.Fatalf("expected archived poll duplicated, got %d", w.Code)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/poll/poll-1/manage/secret", nil)
+	view := app.buildPollView(req, storage.polls[poll.ID], storage.responses[poll.ID], "", "creator", "secret")
+	if !view.Archived || !view.ReadOnly || view.CanManage || !strings.Contains(view.ClosedMessage, "archived") {
+		t.Fatalf("expected read-only archived view, got %+v", view)
+	}
+	if !view.Summaries[0].Elapsed || !view.Summaries[1].Elapsed || len(view.AllAvailableDays) != 0 {
+		t.Fatalf("expected past days elapsed and not highlighted, got %+v", view.Summaries)
 	}
 }
 
-func TestHandlePollPostHTMXValidation(t *testing.T) {
+func TestBuildPollViewClosed(t *testing.T) {
+	app, _ := newTestApp(t)
+	poll := Poll{ID: "poll-1", CreatorToken: "creator", AdminToken: "secret", ClosedAt: time.Date(2024, 1, 5, 18, 30, 0, 0, time.UTC)}
+	responses := []Response{{ID: "resp-jo", UserToken: "jo", OrganizerToken: "jo-manage"}}
+	req := httptest.NewRequest(http.MethodGet, "/poll/poll-1/manage/jo-manage", nil)
+	view := app.buildPollView(req, poll, responses, "", "jo", "jo-manage")
+	if !view.Closed || !view.ReadOnly || view.CanManage || !strings.Contains(view.ClosedMessage, "Fri, Jan 5 at 18:30 UTC") {
+		t.Fatalf("expected read-only organizer view, got %+v", view)
+	}
+	view = app.buildPollView(req, poll, nil, "", "", "secret")
+	if !view.Closed || view.ReadOnly || !view.CanManage {
+		t.Fatalf("expected creator to keep managing a closed poll, got %+v", view)
+	}
+}
+
+func TestHandlePollDeletingOrganizerRevokesRights(t *testing.T) {
 	app, storage := newTestApp(t)
-	poll := Poll{ID: "poll-1", Title: "Hang", Days: []string{"2024-01-01"}, CreatorToken: "creator"}
+	poll := Poll{ID: "poll-1", Title: "Hang", Days: []string{"2024-01-01"}, CreatorToken: "creator", AdminToken: "secret"}
 	storage.polls[poll.ID] = poll
-	storage.responses[poll.ID] = []Response{{ID: "resp-1", Name: "Creator", Days: poll.Days, UserToken: poll.CreatorToken}}
+	storage.responses[poll.ID] = []Response{{ID: "resp-jo", Name: "Jo", Days: poll.Days, UserToken: "jo", OrganizerToken: "jo-manage", Version: 1}}
+
 	form := url.Values{}
-	form.Set("name", "")
-	form.Add("days", "2024-01-01")
-	req := newFormRequest(http.MethodPost, "/poll/"+poll.ID+"/u/"+poll.CreatorToken, form)
-	req.Header.Set("HX-Request", "true")
+	form.Set("action", "delete-response")
+	form.Set("response_id", "resp-jo")
 	w := httptest.NewRecorder()
-	app.handlePoll(w, req)
-	res := w.Result()
-	if res.StatusCode != http.StatusBadRequest {
-		t.Fatalf("expected 400, got %d", res.StatusCode)
+	app.handlePoll(w, newFormRequest(http.MethodPost, "/poll/poll-1/manage/secret", form))
+	if w.Code != http.StatusSeeOther {
+		t.Fatalf("expected redirect, got %d", w.Code)
 	}
-	body, _ := io.ReadAll(res.Body)
-	if !strings.Contains(string(body), "results") {
-		t.Fatalf("expected results template")
+	if len(storage.responses[poll.ID]) != 0 {
+		t.Fatalf("expected response deleted, got %+v", storage.responses[poll.ID])
+	}
+	w = httptest.NewRecorder()
+	app.handlePoll(w, httptest.NewRequest(http.MethodGet, "/poll/poll-1/manage/jo-manage", nil))
+	if w.Code != http.StatusSeeOther || w.Header().Get("Location") != "/?invalid=1" {
+		t.Fatalf("expected the deleted organizer's link rejected, got %d %q", w.Code, w.Header().Get("Location"))
 	}
 }
 
-func TestHandlePollPostAddResponse(t *testing.T) {
-	app, storage := newTestApp(t)
-	poll := Poll{
-		ID:           "poll-1",
-		Title:        "Hang",
-		Days:         []string{"2024-01-01", "2024-01-02"},
-		Venues:       []Venue{{ID: "park", Title: "Park"}, {ID: "movie", Title: "Movie"}},
-		CreatorToken: "creator",
+func TestBuildPollViewOrganizer(t *testing.T) {
+	app, _ := newTestApp(t)
+	poll := Poll{ID: "poll-1", CreatorToken: "creator", AdminToken: "secret"}
+	responses := []Response{{ID: "resp-jo", UserToken: "jo", OrganizerToken: "jo-manage"}, {ID: "resp-sam", UserToken: "sam"}}
+	req := httptest.NewRequest(http.MethodGet, "/poll/poll-1/manage/jo-manage", nil)
+	view := app.buildPollView(req, poll, responses, "", "jo", "jo-manage")
+	if !view.CanManage || view.IsCreator || !strings.HasSuffix(view.ManageURL, "/poll/poll-1/manage/jo-manage") || len(view.OrganizerLinks) != 0 {
+		t.Fatalf("expected organizer view, got %+v", view)
+	}
+	if !view.Organizers["resp-jo"] || view.Organizers["resp-sam"] {
+		t.Fatalf("unexpected organizer map %v", view.Organizers)
+	}
+	if view := app.buildPollView(req, poll, responses, "", "jo", ""); view.CanManage {
+		t.Fatalf("expected the organizer's personal link without management")
+	}
+	finalized := poll
+	finalized.FinalDay = "2024-01-02"
+	if view := app.buildPollView(req, finalized, responses, "", "jo", "jo-manage"); !view.CanManage || !view.ReadOnly {
+		t.Fatalf("expected the organizer to keep managing a finalized poll with their response locked, got %+v", view)
 	}
+	if view := app.buildPollView(req, poll, responses, "", "sam", "jo-manage"); view.CanManage {
+		t.Fatalf("expected someone else's organizer secret to grant nothing")
+	}
+	view = app.buildPollView(req, poll, responses, "", "creator", "secret")
+	if !strings.HasSuffix(view.OrganizerLinks["resp-jo"], "/poll/poll-1/manage/jo-manage") || len(view.OrganizerLinks) != 1 {
+		t.Fatalf("expected the creator to see co-organizer links, got %v", view.OrganizerLinks)
+	}
+}
+
+func TestHandlePollRotateCreatorLink(t *testing.T) {
+	app, storage := newTestApp(t)
+	poll := Poll{ID: "poll-1", Title: "Hang", Days: []string{"2024-01-01"}, CreatorToken: "creator", AdminToken: "secret", RecoveryHash: hashRecoveryCode("code")}
 	storage.polls[poll.ID] = poll
-	storage.responses[poll.ID] = []Response{{ID: "resp-1", Name: "Creator", Days: poll.Days, UserToken: poll.CreatorToken}}
+	storage.responses[poll.ID] = []Response{
+		{ID: "resp-creator", Name: "Creator", Days: poll.Days, UserToken: "creator", Version: 1},
+		{ID: "resp-sam", Name: "Sam", Days: poll.Days, UserToken: "sam", Version: 1},
+	}
+
 	form := url.Values{}
-	form.Set("name", "Jamie")
-	form.Add("days", "2024-01-02")
-	form.Add("venues", "movie")
-	req := newFormRequest(http.MethodPost, "/poll/"+poll.ID+"/u/user-token", form)
+	form.Set("action", "rotate-creator-link")
 	w := httptest.NewRecorder()
-	app.handlePoll(w, req)
-	if w.Result().StatusCode != http.StatusOK {
-		t.Fatalf("expected 200, got %d", w.Result().StatusCode)
+	app.handlePoll(w, newFormRequest(http.MethodPost, "/poll/poll-1/manage/secret", form))
+	if w.Code != http.StatusSeeOther {
+		t.Fatalf("expected redirect, got %d", w.Code)
+	}
+	rotated := storage.polls[poll.ID]
+	if rotated.CreatorToken == "creator" || rotated.AdminToken == "secret" || rotated.AdminToken == rotated.CreatorToken {
+		t.Fatalf("expected fresh creator and admin tokens, got %+v", rotated)
+	}
+	if rotated.RecoveryHash != poll.RecoveryHash {
+		t.Fatalf("expected recovery code kept on rotation")
+	}
+	if w.Header().Get("Location") != "/poll/poll-1/manage/"+rotated.AdminToken {
+		t.Fatalf("expected redirect to new management URL, got %q", w.Header().Get("Location"))
 	}
 	responses := storage.responses[poll.ID]
-	if len(responses) != 2 {
-		t.Fatalf("expected response saved")
+	if responses[0].UserToken != rotated.CreatorToken || responses[1].UserToken != "sam" {
+		t.Fatalf("expected only the creator response moved to the new token, got %+v", responses)
 	}
-	var names []string
-	for _, response := range responses {
-		names = append(names, response.Name)
+
+	w = httptest.NewRecorder()
+	app.handlePoll(w, newFormRequest(http.MethodPost, "/poll/poll-1/manage/secret", form))
+	if w.Header().Get("Location") != "/?invalid=1" {
+		t.Fatalf("expected old management URL rejected, got %d %q", w.Code, w.Header().Get("Location"))
 	}
-	sort.Strings(names)
-	if strings.Join(names, ",") != "Creator,Jamie" {
-		t.Fatalf("unexpected responses: %v", names)
+}
+
+func TestHandlePollRecover(t *testing.T) {
+	app, storage := newTestApp(t)
+	code := newRecoveryCode()
+	poll := Poll{ID: "poll-1", Title: "Hang", Days: []string{"2024-01-01"}, CreatorToken: "creator", AdminToken: "secret", RecoveryHash: hashRecoveryCode(code)}
+	storage.polls[poll.ID] = poll
+	storage.responses[poll.ID] = []Response{{ID: "resp-creator", Name: "Creator", Days: poll.Days, UserToken: "creator", Version: 1}}
+
+	w := httptest.NewRecorder()
+	app.handlePoll(w, newFormRequest(http.MethodPost, "/poll/poll-1/recover", url.Values{"code": {"WRONG-CODE"}}))
+	if w.Code != http.StatusUnauthorized || storage.polls[poll.ID].AdminToken != "secret" {
+		t.Fatalf("expected wrong code rejected, got %d", w.Code)
 	}
-	var jamie Response
-	for _, response := range responses {
-		if response.Name == "Jamie" {
-			jamie = response
-			break
+
+	typed := strings.ToLower(strings.ReplaceAll(code, "-", " "))
+	w = httptest.NewRecorder()
+	app.handlePoll(w, newFormRequest(http.MethodPost, "/poll/poll-1/recover", url.Values{"code": {typed}}))
+	if w.Code != http.StatusSeeOther {
+		t.Fatalf("expected recovery redirect, got %d", w.Code)
+	}
+	recovered := storage.polls[poll.ID]
+	if recovered.AdminToken == "secret" || recovered.RecoveryHash == poll.RecoveryHash {
+		t.Fatalf("expected admin token and recovery code replaced, got %+v", recovered)
+	}
+	if w.Header().Get("Location") != "/poll/poll-1/manage/"+recovered.AdminToken {
+		t.Fatalf("unexpected redirect %q", w.Header().Get("Location"))
+	}
+	var newCode *http.Cookie
+	for _, cookie := range w.Result().Cookies() {
+		if cookie.Name == recoveryCookieName(poll.ID) {
+			newCode = cookie
+		}
+	}
+	if newCode == nil || !matchesRecoveryCode(recovered, newCode.Value) {
+		t.Fatalf("expected the new recovery code handed over once, got %+v", newCode)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, w.Header().Get("Location"), nil)
+	req.AddCookie(newCode)
+	w = httptest.NewRecorder()
+	app.handlePoll(w, req)
+	if !strings.Contains(w.Body.String(), "recovery="+newCode.Value) {
+		t.Fatalf("expected management page to show the new code, got %q", w.Body.String())
+	}
+	for _, cookie := range w.Result().Cookies() {
+		if cookie.Name == recoveryCookieName(poll.ID) && cookie.MaxAge >= 0 {
+			t.Fatalf("expected recovery cookie cleared after display")
+		}
+	}
+
+	w = httptest.NewRecorder()
+	app.handlePoll(w, newFormRequest(http.MethodPost, "/poll/poll-1/recover", url.Values{"code": {code}}))
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected used recovery code rejected, got %d", w.Code)
+	}
+}
+
+func TestHandleCreatePollIssuesRecoveryCode(t *testing.T) {
+	app, storage := newTestApp(t)
+	form := url.Values{}
+	form.Set("title", "Dinner")
+	form.Set("creator", "Sam")
+	form.Add("days", daysFromToday(0))
+	w := httptest.NewRecorder()
+	app.handleCreatePoll(w, newFormRequest(http.MethodPost, "/polls", form))
+	var poll Poll
+	for _, stored := range storage.polls {
+		poll = stored
+	}
+	for _, cookie := range w.Result().Cookies() {
+		if cookie.Name == recoveryCookieName(poll.ID) {
+			if !matchesRecoveryCode(poll, cookie.Value) || poll.RecoveryHash == cookie.Value {
+				t.Fatalf("expected only the hash stored")
+			}
+			return
+		}
+	}
+	t.Fatalf("expected recovery code cookie")
+}
+
+func TestHandlePollSeparatesLegacyAdmin(t *testing.T) {
+	for _, path := range []string{"/poll/poll-1/u/creator", "/poll/poll-1/manage/creator"} {
+		app, storage := newTestApp(t)
+		storage.polls["poll-1"] = Poll{ID: "poll-1", Title: "Hang", Days: []string{daysFromToday(1)}, CreatorToken: "creator"}
+
+		w := httptest.NewRecorder()
+		app.handlePoll(w, httptest.NewRequest(http.MethodGet, path, nil))
+		stored := storage.polls["poll-1"]
+		if stored.AdminToken == "" || stored.AdminToken == stored.CreatorToken || stored.CreatorToken != "creator" {
+			t.Fatalf("%s: expected a separate admin token, got %+v", path, stored)
+		}
+		if w.Code != http.StatusSeeOther || w.Header().Get("Location") != "/poll/poll-1/manage/"+stored.AdminToken {
+			t.Fatalf("%s: expected redirect to the new management link, got %d %q", path, w.Code, w.Header().Get("Location"))
+		}
+		var code *http.Cookie
+		for _, cookie := range w.Result().Cookies() {
+			if cookie.Name == recoveryCookieName("poll-1") {
+				code = cookie
+			}
+		}
+		if code == nil || !matchesRecoveryCode(stored, code.Value) {
+			t.Fatalf("%s: expected a recovery code issued with the admin token, got %+v", path, code)
 		}
+		req := httptest.NewRequest(http.MethodGet, w.Header().Get("Location"), nil)
+		req.AddCookie(code)
+		w = httptest.NewRecorder()
+		app.handlePoll(w, req)
+		if !strings.Contains(w.Body.String(), "recovery="+code.Value) {
+			t.Fatalf("%s: expected management page to show the recovery code", path)
+		}
+
+		w = httptest.NewRecorder()
+		app.handlePoll(w, httptest.NewRequest(http.MethodGet, "/poll/poll-1/manage/creator", nil))
+		if w.Code != http.StatusSeeOther || w.Header().Get("Location") != "/?invalid=1" {
+			t.Fatalf("%s: expected the old secret to stop managing, got %d %q", path, w.Code, w.Header().Get("Location"))
+		}
+		w = httptest.NewRecorder()
+		app.handlePoll(w, httptest.NewRequest(http.MethodGet, "/poll/poll-1/u/creator", nil))
+		if w.Code != http.StatusOK || storage.polls["poll-1"].AdminToken != stored.AdminToken {
+			t.Fatalf("%s: expected the personal link to keep working, got %d", path, w.Code)
+		}
+	}
+}
+
+func TestManageTokenUntilLegacyPollMigrates(t *testing.T) {
+	legacy := Poll{ID: "poll-1", CreatorToken: "creator"}
+	if got := manageToken(legacy, "creator", ""); got != "creator" {
+		t.Fatalf("expected legacy creator link to manage, got %q", got)
+	}
+	if pollManagePath(legacy) != "/poll/poll-1/manage/creator" {
+		t.Fatalf("unexpected legacy management path %q", pollManagePath(legacy))
+	}
+	migrated := Poll{ID: "poll-1", CreatorToken: "creator", AdminToken: "secret"}
+	if got := manageToken(migrated, "creator", ""); got != "" {
+		t.Fatalf("expected personal link not to manage, got %q", got)
+	}
+	if got := manageToken(legacy, "someone", ""); got != "" {
+		t.Fatalf("expected other users not to manage, got %q", got)
+	}
+	for path, want := range map[string]string{
+		"/poll/poll-1/manage/secret":       "secret",
+		"/poll/poll-1/u/creator":           "",
+		"/poll/poll-1/manage/secret/extra": "",
+	} {
+		if got := pollManageToken(path); got != want {
+			t.Fatalf("pollManageToken(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestBuildPollViewManageURL(t *testing.T) {
+	app, _ := newTestApp(t)
+	poll := Poll{ID: "poll-1", CreatorToken: "creator", AdminToken: "secret"}
+	req := httptest.NewRequest(http.MethodGet, "http://example.com/poll/poll-1/u/creator", nil)
+
+	view := app.buildPollView(req, poll, nil, "", "creator", "")
+	if view.IsCreator || view.ManageURL != "" || view.FormURL != "/poll/poll-1/u/creator" {
+		t.Fatalf("expected personal view without management, got %+v", view)
 	}
-	if !equalDays(jamie.VenueVotes, []string{"movie"}) {
-		t.Fatalf("unexpected venue votes: %v", jamie.VenueVotes)
+	view = app.buildPollView(req, poll, nil, "", "creator", "secret")
+	if !view.IsCreator || view.ManageURL != "http://example.com/poll/poll-1/manage/secret" || view.FormURL != "/poll/poll-1/manage/secret" {
+		t.Fatalf("expected management view, got %+v", view)
 	}
 }
 
-func TestHandlePollPostWriteInVenue(t *testing.T) {
-	app, storage := newTestApp(t)
-	poll := Poll{
-		ID:           "poll-1",
-		Title:        "Hang",
-		Days:         []string{"2024-01-01"},
-		CreatorToken: "creator",
+type interferingStorage struct {
+	Storage
+	beforeWrite func()
+}
+
+func (s *interferingStorage) interfere() {
+	if s.beforeWrite != nil {
+		interfere := s.beforeWrite
+		s.beforeWrite = nil
+		interfere()
 	}
+}
+
+func (s *interferingStorage) AddResponse(ctx context.Context, pollID string, response Response) error {
+	s.interfere()
+	return s.Storage.AddResponse(ctx, pollID, response)
+}
+
+func (s *interferingStorage) UpdatePollVenues(ctx context.Context, pollID string, version int, venues []Venue, responses []Response) error {
+	s.interfere()
+	return s.Storage.UpdatePollVenues(ctx, pollID, version, venues, responses)
+}
+
+func (s *interferingStorage) AddVenueWriteIn(ctx context.Context, pollID string, venue Venue, response Response) error {
+	s.interfere()
+	return s.Storage.AddVenueWriteIn(ctx, pollID, venue, response)
+}
+
+func TestHandlePollPostConcurrentWriteInsKept(t *testing.T) {
+	app, storage := newTestApp(t)
+	poll := Poll{ID: "poll-1", Title: "Hang", Days: []string{"2024-01-01"}, CreatorToken: "creator"}
 	storage.polls[poll.ID] = poll
-	storage.responses[poll.ID] = []Response{{ID: "resp-1", Name: "Creator", Days: poll.Days, UserToken: poll.CreatorToken}}
+	app.storage = &interferingStorage{
+		Storage: storage,
+		beforeWrite: func() {
+			// Another responder's write-in lands between our read and write.
+			other := Response{ID: "resp-other", Name: "Sam", Days: poll.Days, VenueVotes: []string{"bowling"}, UserToken: "other"}
+			if err := storage.AddVenueWriteIn(context.Background(), poll.ID, Venue{ID: "bowling", Title: "Bowling"}, other); err != nil {
+				t.Fatalf("competing write-in: %v", err)
+			}
+		},
+	}
 	form := url.Values{}
 	form.Set("name", "Jamie")
 	form.Add("days", "2024-01-01")
 	form.Set("write_in_venue_title", "Arcade")
-	form.Set("write_in_venue_url", "https://example.com/arcade")
-	form.Set("write_in_venue_description", "Open late")
 	req := newFormRequest(http.MethodPost, "/poll/"+poll.ID+"/u/user-token", form)
 	w := httptest.NewRecorder()
 	app.handlePoll(w, req)
 	if w.Result().StatusCode != http.StatusOK {
 		t.Fatalf("expected 200, got %d", w.Result().StatusCode)
 	}
-	updatedPoll := storage.polls[poll.ID]
-	if len(updatedPoll.Venues) != 1 {
-		t.Fatalf("expected write-in venue stored, got %+v", updatedPoll.Venues)
-	}
-	venue := updatedPoll.Venues[0]
-	if venue.Title != "Arcade" || venue.URL != "https://example.com/arcade" || venue.Description != "Open late" {
-		t.Fatalf("unexpected write-in venue: %+v", venue)
+	venues := storage.polls[poll.ID].Venues
+	if len(venues) != 2 || venues[0].Title != "Bowling" || venues[1].Title != "Arcade" {
+		t.Fatalf("expected both write-ins kept, got %+v", venues)
 	}
 	var jamie Response
 	for _, response := range storage.responses[poll.ID] {
 		if response.Name == "Jamie" {
 			jamie = response
-			break
 		}
 	}
-	if !equalDays(jamie.VenueVotes, []string{venue.ID}) {
-		t.Fatalf("expected write-in venue vote, got %v", jamie.VenueVotes)
+	if !equalDays(jamie.VenueVotes, []string{venues[1].ID}) {
+		t.Fatalf("expected vote for own write-in, got %v", jamie.VenueVotes)
 	}
 }
 
-func TestHandlePollPostWriteInVenueRequiresTitle(t *testing.T) {
+func TestHandlePollPostRetriesResponseConflict(t *testing.T) {
 	app, storage := newTestApp(t)
-	poll := Poll{
-		ID:           "poll-1",
-		Title:        "Hang",
-		Days:         []string{"2024-01-01"},
-		CreatorToken: "creator",
-	}
+	poll := Poll{ID: "poll-1", Title: "Hang", Days: []string{"2024-01-01", "2024-01-02"}, CreatorToken: "creator"}
 	storage.polls[poll.ID] = poll
+	existing := Response{ID: "resp-1", Name: "Jamie", Days: []string{"2024-01-01"}, UserToken: "user-token"}
+	storage.responses[poll.ID] = []Response{existing}
+	app.storage = &interferingStorage{
+		Storage: storage,
+		beforeWrite: func() {
+			// The same person saves from another tab first.
+			if err := storage.AddResponse(context.Background(), poll.ID, existing); err != nil {
+				t.Fatalf("competing save: %v", err)
+			}
+		},
+	}
 	form := url.Values{}
 	form.Set("name", "Jamie")
-	form.Add("days", "2024-01-01")
-	form.Set("write_in_venue_url", "https://example.com/arcade")
+	form.Add("days", "2024-01-02")
 	req := newFormRequest(http.MethodPost, "/poll/"+poll.ID+"/u/user-token", form)
 	w := httptest.NewRecorder()
 	app.handlePoll(w, req)
 	if w.Result().StatusCode != http.StatusOK {
-		t.Fatalf("expected rendered validation response, got %d", w.Result().StatusCode)
-	}
-	if len(storage.polls[poll.ID].Venues) != 0 {
-		t.Fatalf("expected no venue stored")
+		t.Fatalf("expected 200, got %d", w.Result().StatusCode)
 	}
-	if len(storage.responses[poll.ID]) != 0 {
-		t.Fatalf("expected no response stored")
+	responses := storage.responses[poll.ID]
+	if len(responses) != 1 || !equalDays(responses[0].Days, []string{"2024-01-02"}) || responses[0].Version != 2 {
+		t.Fatalf("expected retried save applied on top of the competing one, got %+v", responses)
 	}
 }
 
-func TestHandlePollPostUpdateDates(t *testing.T) {
+func TestHandlePollPostUpdateVenuesConflict(t *testing.T) {
 	app, storage := newTestApp(t)
 	poll := Poll{ID: "poll-1", Title: "Hang", Days: []string{"2024-01-01"}, CreatorToken: "creator"}
 	storage.polls[poll.ID] = poll
-	storage.responses[poll.ID] = []Response{{ID: "resp-1", Name: "Creator", Days: []string{"2024-01-01"}, UserToken: poll.CreatorToken}}
+	app.storage = &interferingStorage{
+		Storage: storage,
+		beforeWrite: func() {
+			other := Response{ID: "resp-other", Name: "Sam", Days: poll.Days, UserToken: "other"}
+			if err := storage.AddVenueWriteIn(context.Background(), poll.ID, Venue{ID: "bowling", Title: "Bowling"}, other); err != nil {
+				t.Fatalf("competing write-in: %v", err)
+			}
+		},
+	}
 	form := url.Values{}
-	form.Set("action", "update-dates")
-	form.Add("days", "2024-01-01")
-	form.Add("days", "2024-01-02")
+	form.Set("action", "update-venues")
+	form.Add("venue_title", "Movie")
 	req := newFormRequest(http.MethodPost, "/poll/"+poll.ID+"/u/"+poll.CreatorToken, form)
 	w := httptest.NewRecorder()
 	app.handlePoll(w, req)
-	res := w.Result()
-	if res.StatusCode != http.StatusSeeOther {
-		t.Fatalf("expected redirect, got %d", res.StatusCode)
-	}
-	updated := storage.polls[poll.ID]
-	if !equalDays(updated.Days, []string{"2024-01-01", "2024-01-02"}) {
-		t.Fatalf("expected updated days, got %v", updated.Days)
-	}
-	responses := storage.responses[poll.ID]
-	if len(responses) != 1 {
-		t.Fatalf("expected creator response")
+	if w.Result().StatusCode != http.StatusConflict {
+		t.Fatalf("expected 409, got %d", w.Result().StatusCode)
 	}
-	if !equalDays(responses[0].Days, []string{"2024-01-01", "2024-01-02"}) {
-		t.Fatalf("expected creator auto-marked, got %v", responses[0].Days)
+	venues := storage.polls[poll.ID].Venues
+	if len(venues) != 1 || venues[0].ID != "bowling" {
+		t.Fatalf("expected competing write-in kept, got %+v", venues)
 	}
 }
 
-func TestHandlePollPostUpdateVenuesFiltersVotes(t *testing.T) {
+func TestHandlePollPostUpdateVenuesKeepsVotesOnConflict(t *testing.T) {
 	app, storage := newTestApp(t)
-	poll := Poll{
-		ID:           "poll-1",
-		Title:        "Hang",
-		Days:         []string{"2024-01-01"},
-		Venues:       []Venue{{ID: "park", Title: "Park"}, {ID: "movie", Title: "Movie"}},
-		CreatorToken: "creator",
-	}
+	poll := Poll{ID: "poll-1", Title: "Hang", Days: []string{"2024-01-01"}, Venues: []Venue{{ID: "park", Title: "Park"}}, CreatorToken: "creator"}
 	storage.polls[poll.ID] = poll
-	storage.responses[poll.ID] = []Response{
-		{ID: "resp-1", Name: "Creator", Days: []string{"2024-01-01"}, VenueVotes: []string{"park"}, UserToken: poll.CreatorToken},
-		{ID: "resp-2", Name: "Sam", Days: []string{"2024-01-01"}, VenueVotes: []string{"park", "movie"}, UserToken: "user"},
+	storage.responses[poll.ID] = []Response{{ID: "resp-1", Name: "Sam", Days: poll.Days, VenueVotes: []string{"park"}, UserToken: "sam", Version: 1}}
+	app.storage = &interferingStorage{
+		Storage: storage,
+		beforeWrite: func() {
+			saved := storage.responses[poll.ID][0]
+			saved.Name = "Samantha"
+			if err := storage.AddResponse(context.Background(), poll.ID, saved); err != nil {
+				t.Fatalf("competing save: %v", err)
+			}
+		},
 	}
-	form := url.Values{}
-	form.Set("action", "update-venues")
-	form.Add("venue_id", "movie")
-	form.Add("venue_title", "Movie")
-	form.Add("venue_url", "")
-	form.Add("venue_description", "")
-	req := newFormRequest(http.MethodPost, "/poll/"+poll.ID+"/u/"+poll.CreatorToken, form)
+	form := url.Values{"action": {"update-venues"}, "venue_id": {""}, "venue_title": {"Movie"}}
 	w := httptest.NewRecorder()
-	app.handlePoll(w, req)
-	if w.Result().StatusCode != http.StatusSeeOther {
-		t.Fatalf("expected redirect, got %d", w.Result().StatusCode)
+	app.handlePoll(w, newFormRequest(http.MethodPost, "/poll/"+poll.ID+"/u/"+poll.CreatorToken, form))
+	if w.Code != http.StatusConflict {
+		t.Fatalf("expected 409, got %d", w.Code)
 	}
-	updated := storage.polls[poll.ID]
-	if len(updated.Venues) != 1 || updated.Venues[0].ID != "movie" {
-		t.Fatalf("expected only movie venue, got %+v", updated.Venues)
+	if venues := storage.polls[poll.ID].Venues; len(venues) != 1 || venues[0].ID != "park" {
+		t.Fatalf("expected venues unchanged, got %+v", venues)
 	}
-	for _, response := range storage.responses[poll.ID] {
-		if response.Name == "Creator" && len(response.VenueVotes) != 0 {
-			t.Fatalf("expected creator votes cleared, got %v", response.VenueVotes)
-		}
-		if response.Name == "Sam" && !equalDays(response.VenueVotes, []string{"movie"}) {
-			t.Fatalf("expected sam votes filtered, got %v", response.VenueVotes)
-		}
+	if votes := storage.responses[poll.ID][0].VenueVotes; !equalDays(votes, []string{"park"}) {
+		t.Fatalf("expected the vote kept with its venue, got %v", votes)
 	}
 }
 
-func TestHandlePollPostDeleteResponse(t *testing.T) {
+func TestHandleStats(t *testing.T) {
 	app, storage := newTestApp(t)
-	poll := Poll{ID: "poll-1", Title: "Hang", Days: []string{"2024-01-01"}, CreatorToken: "creator"}
-	storage.polls[poll.ID] = poll
-	storage.responses[poll.ID] = []Response{{ID: "resp-1", Name: "Creator", Days: []string{"2024-01-01"}, UserToken: poll.CreatorToken}}
-	form := url.Values{}
-	form.Set("action", "delete-response")
-	form.Set("response_id", "resp-1")
-	req := newFormRequest(http.MethodPost, "/poll/"+poll.ID+"/u/"+poll.CreatorToken, form)
+	storage.polls["poll-1"] = Poll{ID: "poll-1"}
+	storage.responses["poll-1"] = []Response{{ID: "resp-1"}}
+	req := httptest.NewRequest(http.MethodGet, "/admin/stats", nil)
 	w := httptest.NewRecorder()
-	app.handlePoll(w, req)
-	if w.Result().StatusCode != http.StatusSeeOther {
-		t.Fatalf("expected redirect, got %d", w.Result().StatusCode)
+	app.handleStats(w, req)
+	res := w.Result()
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", res.StatusCode)
 	}
-	if len(storage.responses[poll.ID]) != 0 {
-		t.Fatalf("expected response deleted")
+	body, _ := io.ReadAll(res.Body)
+	if !strings.Contains(string(body), "stats") {
+		t.Fatalf("expected stats template")
 	}
 }
 
-func TestHandlePollPostDuplicatePoll(t *testing.T) {
-	app, storage := newTestApp(t)
-	original := Poll{
-		ID:           "poll-1",
-		Title:        "Hang",
-		Days:         []string{"2024-01-01", "2024-01-02"},
-		Venues:       []Venue{{ID: "park", Title: "Park", URL: "https://example.com/park", Description: "Picnic tables"}},
-		CreatorToken: "creator",
+func newAdminTestApp(t *testing.T) *App {
+	t.Helper()
+	app, _ := newTestApp(t)
+	hash, err := bcrypt.GenerateFromPassword([]byte("hunter2"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("hash: %v", err)
 	}
-	storage.polls[original.ID] = original
-	storage.responses[original.ID] = []Response{
-		{ID: "resp-1", Name: "Creator", Days: original.Days, UserToken: original.CreatorToken},
-		{ID: "resp-2", Name: "Sam", Days: []string{"2024-01-02"}, UserToken: "user"},
+	app.admin, err = newAdminAuth(string(hash), "test-secret")
+	if err != nil {
+		t.Fatalf("admin auth: %v", err)
 	}
+	return app
+}
 
-	form := url.Values{}
-	form.Set("action", "duplicate-poll")
-	req := newFormRequest(http.MethodPost, "/poll/"+original.ID+"/u/"+original.CreatorToken, form)
+func TestRequireAdmin(t *testing.T) {
+	app := newAdminTestApp(t)
+	handler := app.requireAdmin(app.adminRoutes())
+
+	cases := []struct {
+		name     string
+		target   string
+		password string
+		want     int
+	}{
+		{"no credentials redirects to login", "/admin/stats", "", http.StatusSeeOther},
+		{"no credentials on JSON", "/admin/stats.json", "", http.StatusUnauthorized},
+		{"wrong password", "/admin/stats", "nope", http.StatusUnauthorized},
+		{"basic auth", "/admin/stats", "hunter2", http.StatusOK},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tc.target, nil)
+			if tc.password != "" {
+				req.SetBasicAuth("admin", tc.password)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+			if w.Code != tc.want {
+				t.Fatalf("expected %d, got %d", tc.want, w.Code)
+			}
+			if tc.want == http.StatusSeeOther && w.Header().Get("Location") != "/admin/login?next=%2Fadmin%2Fstats" {
+				t.Fatalf("unexpected redirect %q", w.Header().Get("Location"))
+			}
+		})
+	}
+}
+
+func TestRequireAdminNotConfigured(t *testing.T) {
+	app, _ := newTestApp(t)
+	req := httptest.NewRequest(http.MethodGet, "/admin/stats", nil)
+	req.SetBasicAuth("admin", "anything")
 	w := httptest.NewRecorder()
+	app.requireAdmin(app.adminRoutes()).ServeHTTP(w, req)
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("expected 403, got %d", w.Code)
+	}
+}
 
-	app.handlePoll(w, req)
+func TestAdminLoginSession(t *testing.T) {
+	app := newAdminTestApp(t)
+	w := httptest.NewRecorder()
+	app.handleAdminLogin(w, newFormRequest(http.MethodPost, "/admin/login", url.Values{
+		"password": {"hunter2"},
+		"next":     {"/admin/stats.json"},
+	}))
+	if w.Code != http.StatusSeeOther || w.Header().Get("Location") != "/admin/stats.json" {
+		t.Fatalf("expected redirect to next, got %d %q", w.Code, w.Header().Get("Location"))
+	}
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != adminCookieName || !cookies[0].HttpOnly {
+		t.Fatalf("expected admin session cookie, got %+v", cookies)
+	}
 
-	res := w.Result()
-	if res.StatusCode != http.StatusSeeOther {
-		t.Fatalf("expected redirect, got %d", res.StatusCode)
+	req := httptest.NewRequest(http.MethodGet, "/admin/stats.json", nil)
+	req.AddCookie(cookies[0])
+	w = httptest.NewRecorder()
+	app.requireAdmin(app.adminRoutes()).ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected session to authorize, got %d", w.Code)
 	}
-	location := res.Header.Get("Location")
-	if !strings.Contains(location, "/poll/") || !strings.Contains(location, "/u/") {
-		t.Fatalf("unexpected redirect location: %s", location)
+
+	tampered := *cookies[0]
+	tampered.Value = strconv.FormatInt(time.Now().Add(48*time.Hour).Unix(), 10) + "." + strings.SplitN(cookies[0].Value, ".", 2)[1]
+	req = httptest.NewRequest(http.MethodGet, "/admin/stats.json", nil)
+	req.AddCookie(&tampered)
+	w = httptest.NewRecorder()
+	app.requireAdmin(app.adminRoutes()).ServeHTTP(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected tampered session to be rejected, got %d", w.Code)
 	}
 
-	if len(storage.polls) != 2 {
-		t.Fatalf("expected 2 polls after duplication, got %d", len(storage.polls))
+	req = httptest.NewRequest(http.MethodGet, "/admin/stats", nil)
+	req.AddCookie(&http.Cookie{Name: adminCookieName, Value: app.admin.sessionToken(time.Now().Add(-time.Minute))})
+	if app.admin.validSession(req, time.Now()) {
+		t.Fatalf("expected expired session to be rejected")
 	}
+}
 
-	var duplicated Poll
-	for _, poll := range storage.polls {
-		if poll.ID != original.ID {
-			duplicated = poll
-			break
+func TestAdminLoginRejectsOffsiteNext(t *testing.T) {
+	for _, next := range []string{"https://evil.example", "//evil.example", "/poll/abc", "/admin/login"} {
+		if got := adminRedirectTarget(next); got != "/admin/stats" {
+			t.Fatalf("expected %q to fall back to stats, got %q", next, got)
 		}
 	}
-	if duplicated.ID == "" {
-		t.Fatalf("expected duplicated poll to be stored")
+	if got := adminRedirectTarget("/admin/stats.json"); got != "/admin/stats.json" {
+		t.Fatalf("expected admin path kept, got %q", got)
 	}
-	if duplicated.Title != original.Title {
-		t.Fatalf("expected title copied, got %q", duplicated.Title)
+}
+
+func TestAdminLoginThrottlesFailures(t *testing.T) {
+	app := newAdminTestApp(t)
+	login := func(password string) *httptest.ResponseRecorder {
+		req := newFormRequest(http.MethodPost, "/admin/login", url.Values{"password": {password}})
+		req.RemoteAddr = "203.0.113.7:4321"
+		w := httptest.NewRecorder()
+		app.handleAdminLogin(w, req)
+		return w
 	}
-	if len(duplicated.Days) != 0 {
-		t.Fatalf("expected duplicated poll to start with no dates, got %v", duplicated.Days)
+	for i := 0; i < maxAdminLoginFailures; i++ {
+		if w := login("wrong"); w.Code != http.StatusUnauthorized {
+			t.Fatalf("attempt %d: expected 401, got %d", i+1, w.Code)
+		}
 	}
-	if duplicated.CreatorToken == "" || duplicated.CreatorToken == original.CreatorToken {
-		t.Fatalf("expected a fresh creator token, got %q", duplicated.CreatorToken)
+	w := login("hunter2")
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") == "" {
+		t.Fatalf("expected lockout with Retry-After, got %d", w.Code)
 	}
-	if len(duplicated.Venues) != 1 || duplicated.Venues[0] != original.Venues[0] {
-		t.Fatalf("expected venues copied, got %+v", duplicated.Venues)
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/stats", nil)
+	req.RemoteAddr = "203.0.113.7:4321"
+	req.SetBasicAuth("admin", "hunter2")
+	rec := httptest.NewRecorder()
+	app.requireAdmin(app.adminRoutes()).ServeHTTP(rec, req)
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected basic auth to share the lockout, got %d", rec.Code)
 	}
-	if len(storage.responses[duplicated.ID]) != 0 {
-		t.Fatalf("expected duplicated poll to have no responses")
+
+	req = newFormRequest(http.MethodPost, "/admin/login", url.Values{"password": {"hunter2"}})
+	req.RemoteAddr = "198.51.100.1:4321"
+	rec = httptest.NewRecorder()
+	app.handleAdminLogin(rec, req)
+	if rec.Code != http.StatusSeeOther {
+		t.Fatalf("expected other clients unaffected, got %d", rec.Code)
+	}
+}
+
+func TestProtectCSRF(t *testing.T) {
+	app, _ := newTestApp(t)
+	var seen string
+	handler := app.protectCSRF(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = csrfToken(r)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	cookies := w.Result().Cookies()
+	if w.Code != http.StatusNoContent || len(cookies) != 1 || cookies[0].Name != csrfCookieName || !cookies[0].HttpOnly {
+		t.Fatalf("expected a CSRF session cookie on first visit, got %d %+v", w.Code, cookies)
+	}
+	session := cookies[0]
+	token := app.csrfTokenFor(session.Value)
+	if seen != token {
+		t.Fatalf("expected token in request context, got %q", seen)
+	}
+
+	post := func(form url.Values, header string, cookie *http.Cookie) *httptest.ResponseRecorder {
+		req := newFormRequest(http.MethodPost, "/polls", form)
+		if header != "" {
+			req.Header.Set(csrfHeader, header)
+		}
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		seen = ""
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := post(url.Values{csrfFormField: {token}, "title": {"Dinner"}}, "", session); w.Code != http.StatusNoContent || seen != token {
+		t.Fatalf("expected form token accepted, got %d", w.Code)
+	}
+	if w := post(url.Values{}, token, session); w.Code != http.StatusNoContent {
+		t.Fatalf("expected header token accepted, got %d", w.Code)
+	}
+	if w := post(url.Values{"title": {"Dinner"}}, "", session); w.Code != http.StatusForbidden || !strings.Contains(w.Body.String(), "error ") || seen != "" {
+		t.Fatalf("expected missing token rejected with error page, got %d %q", w.Code, w.Body.String())
+	}
+	other := &http.Cookie{Name: csrfCookieName, Value: "other-session"}
+	if w := post(url.Values{csrfFormField: {token}}, "", other); w.Code != http.StatusForbidden {
+		t.Fatalf("expected token from another session rejected, got %d", w.Code)
 	}
-	if !strings.Contains(location, "/poll/"+duplicated.ID+"/u/"+duplicated.CreatorToken) {
-		t.Fatalf("expected redirect to duplicated poll, got %s", location)
+	w = post(url.Values{csrfFormField: {token}}, "", nil)
+	if w.Code != http.StatusForbidden || len(w.Result().Cookies()) != 1 {
+		t.Fatalf("expected rejection to start a new session, got %d %+v", w.Code, w.Result().Cookies())
+	}
+
+	req := newFormRequest(http.MethodPost, "/poll/poll-1", url.Values{})
+	req.Header.Set("HX-Request", "true")
+	req.AddCookie(session)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusForbidden || w.Header().Get("HX-Refresh") != "true" {
+		t.Fatalf("expected HTMX rejection to ask for a refresh, got %d %v", w.Code, w.Header())
 	}
 }
 
-func TestHandleStats(t *testing.T) {
+func TestTemplatesCarryCSRFToken(t *testing.T) {
 	app, storage := newTestApp(t)
-	storage.polls["poll-1"] = Poll{ID: "poll-1"}
-	storage.responses["poll-1"] = []Response{{ID: "resp-1"}}
-	req := httptest.NewRequest(http.MethodGet, "/admin/stats", nil)
+	templates, err := parseTemplates()
+	if err != nil {
+		t.Fatalf("parse templates: %v", err)
+	}
+	app.templates = templates
+	days := []string{daysFromToday(1), daysFromToday(2)}
+	storage.polls["poll-1"] = Poll{ID: "poll-1", Title: "Hang", Days: days, TripLength: 2, CreatorToken: "creator", AdminToken: "secret"}
+	storage.responses["poll-1"] = []Response{
+		{ID: "resp-creator", Name: "Creator", Days: days, UserToken: "creator", Version: 1},
+		{ID: "resp-jo", Name: "Jo", Days: days[:1], UserToken: "jo", Version: 1},
+	}
+	handler := app.protectCSRF(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" {
+			app.handleHome(w, r)
+			return
+		}
+		app.handlePoll(w, r)
+	}))
+
+	for _, path := range []string{"/", "/poll/poll-1/manage/secret", "/poll/poll-1/recover"} {
+		session := &http.Cookie{Name: csrfCookieName, Value: "session-1"}
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.AddCookie(session)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		body := w.Body.String()
+		field := `name="csrf_token" value="` + app.csrfTokenFor(session.Value) + `"`
+		if w.Code != http.StatusOK || strings.Count(body, "<form") != strings.Count(body, field) {
+			t.Fatalf("%s: expected every form to carry the CSRF token, got %d", path, w.Code)
+		}
+	}
+}
+
+func TestHandleStatsJSON(t *testing.T) {
+	app, storage := newTestApp(t)
+	now := time.Now().UTC()
+	if err := storage.CreatePoll(context.Background(), Poll{ID: "poll-1", Venues: []Venue{{ID: "park"}}, CreatedAt: now}); err != nil {
+		t.Fatalf("create poll: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := storage.AddResponse(context.Background(), "poll-1", Response{ID: fmt.Sprintf("resp-%d", i), CreatedAt: now}); err != nil {
+			t.Fatalf("add response: %v", err)
+		}
+	}
+	req := httptest.NewRequest(http.MethodGet, "/admin/stats.json", nil)
 	w := httptest.NewRecorder()
 	app.handleStats(w, req)
 	res := w.Result()
 	if res.StatusCode != http.StatusOK {
 		t.Fatalf("expected 200, got %d", res.StatusCode)
 	}
-	body, _ := io.ReadAll(res.Body)
-	if !strings.Contains(string(body), "stats") {
-		t.Fatalf("expected stats template")
+	if got := res.Header.Get("Content-Type"); got != "application/json" {
+		t.Fatalf("expected JSON content type, got %q", got)
+	}
+	var stats Stats
+	if err := json.NewDecoder(res.Body).Decode(&stats); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if stats.PollCount != 1 || stats.ResponseCount != 2 {
+		t.Fatalf("unexpected totals: %+v", stats)
+	}
+	if len(stats.Daily) != statsDays || len(stats.Weekly) != statsWeeks {
+		t.Fatalf("expected %d days and %d weeks, got %d and %d", statsDays, statsWeeks, len(stats.Daily), len(stats.Weekly))
+	}
+	today := stats.Daily[len(stats.Daily)-1]
+	if today.Start != now.Format("2006-01-02") || today.Polls != 1 || today.Responses != 2 {
+		t.Fatalf("unexpected today bucket: %+v", today)
+	}
+	if today.RespondentsPerPoll != 2 || today.VenueShare != 1 {
+		t.Fatalf("unexpected rates: %+v", today)
+	}
+	if week := stats.Weekly[len(stats.Weekly)-1]; week.Polls != 1 || week.Responses != 2 {
+		t.Fatalf("unexpected current week: %+v", week)
+	}
+}
+
+func TestWeeklyStatsSeries(t *testing.T) {
+	daily := []StatsPeriod{
+		{Start: "2024-01-01", StatsCounters: StatsCounters{Polls: 2, VenuePolls: 1, Responses: 4}},
+		{Start: "2024-01-07", StatsCounters: StatsCounters{Responses: 4, WriteIns: 2}},
+		{Start: "2024-01-08", StatsCounters: StatsCounters{Polls: 1}},
+	}
+	start := time.Date(2024, 1, 3, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC)
+	series := weeklyStatsSeries(daily, start, end)
+	if len(series) != 2 || series[0].Start != "2024-01-01" || series[1].Start != "2024-01-08" {
+		t.Fatalf("expected weeks starting on Mondays, got %+v", series)
+	}
+	first := series[0]
+	if first.Polls != 2 || first.Responses != 8 || first.WriteIns != 2 {
+		t.Fatalf("unexpected first week: %+v", first)
+	}
+	if first.RespondentsPerPoll != 4 || first.VenueShare != 0.5 || first.WriteInRate != 0.25 {
+		t.Fatalf("unexpected first week rates: %+v", first)
+	}
+	if series[1].Polls != 1 || series[1].WriteInRate != 0 {
+		t.Fatalf("unexpected second week: %+v", series[1])
+	}
+}
+
+func TestStatsChartScalesBars(t *testing.T) {
+	series := []StatsPeriod{
+		{Start: "2024-01-01", StatsCounters: StatsCounters{Polls: 1}},
+		{Start: "2024-01-02"},
+		{Start: "2024-01-03", StatsCounters: StatsCounters{Polls: 4}},
+	}
+	charts := statsCharts(series)
+	if len(charts) != 5 {
+		t.Fatalf("expected 5 charts, got %d", len(charts))
+	}
+	chart := charts[0]
+	if chart.Max != "4" || len(chart.Bars) != 3 {
+		t.Fatalf("unexpected chart: %+v", chart)
+	}
+	if chart.Bars[0].Height != 25 || chart.Bars[1].Height != 0 || chart.Bars[2].Height != 100 || chart.Bars[2].Y != 0 {
+		t.Fatalf("unexpected bar heights: %+v", chart.Bars)
+	}
+	if chart.Bars[2].Label != "Wed, Jan 3: 4" {
+		t.Fatalf("unexpected label %q", chart.Bars[2].Label)
 	}
 }
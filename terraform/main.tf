@@ -6,6 +6,10 @@ terraform {
       source  = "hashicorp/aws"
       version = "~> 5.30"
     }
+    random = {
+      source  = "hashicorp/random"
+      version = "~> 3.6"
+    }
   }
 }
 
@@ -13,8 +17,22 @@ provider "aws" {
   region = var.aws_region
 }
 
+# Signing secrets must be stable across Lambda instances, so unless one is passed in Terraform
+# generates it once and keeps it in state.
+resource "random_password" "admin_session_secret" {
+  length  = 48
+  special = false
+}
+
+resource "random_password" "csrf_secret" {
+  length  = 48
+  special = false
+}
+
 locals {
-  app_base_url = var.app_base_url != "" ? var.app_base_url : "https://${var.domain_name}"
+  app_base_url         = var.app_base_url != "" ? var.app_base_url : "https://${var.domain_name}"
+  admin_session_secret = var.admin_session_secret != "" ? var.admin_session_secret : random_password.admin_session_secret.result
+  csrf_secret          = var.csrf_secret != "" ? var.csrf_secret : random_password.csrf_secret.result
 }
 
 resource "aws_dynamodb_table" "polls" {
@@ -32,6 +50,11 @@ resource "aws_dynamodb_table" "polls" {
     name = "sk"
     type = "S"
   }
+
+  ttl {
+    attribute_name = "expires_at"
+    enabled        = true
+  }
 }
 
 resource "aws_iam_role" "lambda" {
@@ -80,11 +103,13 @@ resource "aws_iam_role_policy" "lambda_dynamodb" {
     Statement = [
       {
         Action = [
+          "dynamodb:GetItem",
           "dynamodb:PutItem",
           "dynamodb:Query",
           "dynamodb:Scan",
           "dynamodb:DeleteItem",
-          "dynamodb:UpdateItem"
+          "dynamodb:UpdateItem",
+          "dynamodb:ConditionCheckItem"
         ]
         Effect   = "Allow"
         Resource = aws_dynamodb_table.polls.arn
@@ -104,8 +129,13 @@ resource "aws_lambda_function" "app" {
 
   environment {
     variables = {
-      DYNAMODB_TABLE = aws_dynamodb_table.polls.name
-      APP_BASE_URL   = local.app_base_url
+      DYNAMODB_TABLE       = aws_dynamodb_table.polls.name
+      APP_BASE_URL         = local.app_base_url
+      ADMIN_PASSWORD_HASH  = var.admin_password_hash
+      ADMIN_SESSION_SECRET = local.admin_session_secret
+      CSRF_SECRET          = local.csrf_secret
+      RATE_LIMITS          = var.rate_limits
+      ARCHIVE_RETENTION    = var.archive_retention
     }
   }
 }
@@ -44,3 +44,36 @@ variable "route53_zone_id" {
   description = "Route53 hosted zone ID for the custom domain."
   default     = "Z0041753160CTNVWUAX4D"
 }
+
+variable "admin_password_hash" {
+  type        = string
+  description = "Bcrypt hash of the admin password. Leave empty to disable the /admin pages."
+  default     = ""
+  sensitive   = true
+}
+
+variable "admin_session_secret" {
+  type        = string
+  description = "Secret used to sign admin session cookies. Leave empty to have Terraform generate one."
+  default     = ""
+  sensitive   = true
+}
+
+variable "csrf_secret" {
+  type        = string
+  description = "Secret used to sign CSRF tokens. Leave empty to have Terraform generate one."
+  default     = ""
+  sensitive   = true
+}
+
+variable "rate_limits" {
+  type        = string
+  description = "Overrides for the built-in rate limits, e.g. \"create-poll.ip=5/1h,respond.poll=off\"."
+  default     = ""
+}
+
+variable "archive_retention" {
+  type        = string
+  description = "How long polls are kept after their last day before the table's TTL deletes them, e.g. \"90d\". Leave empty to keep them forever."
+  default     = ""
+}